@@ -0,0 +1,307 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// BoardGamePlay is a logged play of a board game
+type BoardGamePlay struct {
+	Game    string   `json:"Game" yaml:"-"`
+	Year    int      `json:"Year" yaml:"-"`
+	BggId   int      `json:"BggId" yaml:"-"`
+	Date    string   `json:"Date" yaml:"date"`
+	Players []string `json:"Players" yaml:"players,omitempty"`
+	Winners []string `json:"Winners" yaml:"winners,omitempty"`
+	Score   string   `json:"Score" yaml:"score,omitempty"`
+	Won     bool     `json:"Won" yaml:"won,omitempty"`
+}
+
+// bgStatsExport is the subset of the BG Stats app JSON export we use
+type bgStatsExport struct {
+	UserInfo struct {
+		MeRefId int `json:"meRefId"`
+	} `json:"userInfo"`
+	Players []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"players"`
+	Games []struct {
+		ID      int    `json:"id"`
+		Name    string `json:"name"`
+		BggId   int    `json:"bggId"`
+		BggYear int    `json:"bggYear"`
+	} `json:"games"`
+	Plays []struct {
+		PlayDate     string `json:"playDate"`
+		GameRefId    int    `json:"gameRefId"`
+		Ignored      bool   `json:"ignored"`
+		PlayerScores []struct {
+			PlayerRefId int    `json:"playerRefId"`
+			Score       string `json:"score"`
+			Winner      bool   `json:"winner"`
+		} `json:"playerScores"`
+	} `json:"plays"`
+}
+
+var bgstatsNotesDir string
+
+// bgstatsCmd represents the bgstats command
+var bgstatsCmd = &cobra.Command{
+	Use:   "bgstats [file]",
+	Short: "Parse BG Stats board game plays export",
+	Long: `Parse the JSON export of the BG Stats app and append each logged play (date, players,
+winners, your score and whether you won) to the plays list in the frontmatter of the matching
+board game note. The total_plays and win_rate fields of the note are updated from the list.
+
+Games without an existing note get a stub note in the notes directory.
+The file defaults to BGStatsExport.json.`,
+	ValidArgsFunction: completeInputFile("json"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing BG Stats export...")
+		parse_bgstats(inputFile(args, "BGStatsExport.json"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(bgstatsCmd)
+
+	bgstatsCmd.Flags().StringVarP(&bgstatsNotesDir, "notes-dir", "d", "", "Directory with board game notes (default <MarkdownOutputDir>/boardgames)")
+}
+
+func parse_bgstats(filename string) {
+	if bgstatsNotesDir == "" {
+		bgstatsNotesDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "boardgames")
+	}
+
+	input, err := openInput(filename)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	var plays []BoardGamePlay
+	if importJSONIn {
+		plays, err = readJSONLines[BoardGamePlay](input)
+	} else {
+		var export bgStatsExport
+		err = json.NewDecoder(input).Decode(&export)
+		plays = bgStatsPlays(export)
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(plays); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d plays\n", len(plays))
+		return
+	}
+
+	// Board game notes are matched by title and year like movie notes
+	index, err := indexMovieNotes(bgstatsNotesDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error reading notes from %s: %v\n", bgstatsNotesDir, err)
+		return
+	}
+
+	// Notes are read once and written after all plays of the game are added
+	notes := make(map[string]*Note)
+	var order []string
+	var added, created int
+	for _, play := range plays {
+		playLogger := log.WithFields(log.Fields{
+			"Game": play.Game,
+			"Date": play.Date,
+		})
+
+		path := index.find(play.Game, play.Year)
+		note, ok := notes[path]
+		if !ok {
+			if path == "" {
+				note = newBoardGameStub(bgstatsNotesDir, play)
+				path = note.Path
+				index.add(path, play.Game, play.Year)
+				created++
+				playLogger.Info("No matching note, creating stub")
+			} else {
+				note, err = readNote(path)
+				if err != nil {
+					playLogger.Errorf("Error reading %s: %v\n", path, err)
+					continue
+				}
+			}
+			notes[path] = note
+			order = append(order, path)
+		}
+
+		isNew, err := addPlay(note, play)
+		if err != nil {
+			playLogger.Errorf("Error adding play: %v\n", err)
+			continue
+		}
+		result := ""
+		if play.Won {
+			result = "(won)"
+		}
+		addDailyActivity(play.Date, "Played", path, result)
+		if isNew {
+			added++
+		}
+	}
+
+	for _, path := range order {
+		note := notes[path]
+		if err := updatePlayStats(note); err != nil {
+			log.Errorf("Error updating play stats of %s: %v\n", path, err)
+			continue
+		}
+		if err := note.Write(); err != nil {
+			log.Errorf("Error writing %s: %v\n", path, err)
+		}
+	}
+
+	summaryf("Processed %d plays of %d games: %d new plays, %d stubs created\n", len(plays), len(order), added, created)
+}
+
+// bgStatsPlays resolves the player and game references of the export's plays
+func bgStatsPlays(export bgStatsExport) []BoardGamePlay {
+	players := make(map[int]string)
+	for _, player := range export.Players {
+		players[player.ID] = player.Name
+	}
+
+	var plays []BoardGamePlay
+	for _, p := range export.Plays {
+		if p.Ignored {
+			continue
+		}
+
+		play := BoardGamePlay{
+			// playDate is "2006-01-02 15:04:05", seconds are always zero
+			Date: strings.TrimSuffix(p.PlayDate, ":00"),
+		}
+		for _, game := range export.Games {
+			if game.ID == p.GameRefId {
+				play.Game = game.Name
+				play.Year = game.BggYear
+				play.BggId = game.BggId
+				break
+			}
+		}
+		if play.Game == "" {
+			log.Warnf("Skipping play of unknown game %d on %s\n", p.GameRefId, p.PlayDate)
+			continue
+		}
+
+		for _, score := range p.PlayerScores {
+			name := players[score.PlayerRefId]
+			play.Players = append(play.Players, name)
+			if score.Winner {
+				play.Winners = append(play.Winners, name)
+			}
+			if score.PlayerRefId == export.UserInfo.MeRefId {
+				play.Score = score.Score
+				play.Won = score.Winner
+			}
+		}
+
+		plays = append(plays, play)
+	}
+
+	return plays
+}
+
+// addPlay appends the play to the note's plays list, returns false if it was already there
+func addPlay(note *Note, play BoardGamePlay) (bool, error) {
+	plays, err := notePlays(note)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range plays {
+		if existing.Date == play.Date {
+			return false, nil
+		}
+	}
+
+	plays = append(plays, play)
+	sort.SliceStable(plays, func(i, j int) bool {
+		return plays[i].Date < plays[j].Date
+	})
+
+	return true, note.Frontmatter.Set("plays", plays)
+}
+
+// updatePlayStats sets the total_plays and win_rate fields from the note's plays
+func updatePlayStats(note *Note) error {
+	plays, err := notePlays(note)
+	if err != nil {
+		return err
+	}
+
+	if err := note.Frontmatter.Set("total_plays", len(plays)); err != nil {
+		return err
+	}
+	if len(plays) == 0 {
+		return nil
+	}
+
+	var wins int
+	for _, play := range plays {
+		if play.Won {
+			wins++
+		}
+	}
+
+	winRate := float64(wins) / float64(len(plays))
+	return note.Frontmatter.Set("win_rate", math.Round(winRate*100)/100)
+}
+
+// notePlays returns the plays list of a note
+func notePlays(note *Note) ([]BoardGamePlay, error) {
+	var plays []BoardGamePlay
+	if note.Frontmatter.Has("plays") {
+		if err := note.Frontmatter.Decode("plays", &plays); err != nil {
+			return nil, err
+		}
+	}
+	return plays, nil
+}
+
+// newBoardGameStub creates an unsaved note for a game we have no note for
+func newBoardGameStub(directory string, play BoardGamePlay) *Note {
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", play.Game)
+	if play.Year > 0 {
+		frontmatter.Set("year", play.Year)
+	}
+	if play.BggId > 0 {
+		frontmatter.Set("bgg_id", play.BggId)
+		frontmatter.Set("url", "https://boardgamegeek.com/boardgame/"+strconv.Itoa(play.BggId))
+		setMediaIDs(frontmatter, "bgg_id")
+	}
+	frontmatter.Set("tags", []string{"boardgame", "boardgame/stub"})
+
+	return &Note{
+		Path:        filepath.Join(directory, sanitizeFilename(play.Game)+".md"),
+		Frontmatter: frontmatter,
+		Body:        "\n",
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// cachePath returns the file used to cache a response for the given source and key
+func cachePath(source, key string) string {
+	return filepath.Join(viper.GetString("CacheDir"), source, sanitizeFilename(key)+".json")
+}
+
+// readCache loads a cached API response into v, returns false if nothing is cached
+func readCache(source, key string, v interface{}) bool {
+	data, err := os.ReadFile(cachePath(source, key))
+	if err != nil {
+		return false
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Warnf("Ignoring corrupt cache entry %s/%s: %v\n", source, key, err)
+		return false
+	}
+
+	return true
+}
+
+// readFreshCache is readCache for responses that change over time, entries older than maxAge are
+// ignored so they are fetched again
+func readFreshCache(source, key string, maxAge time.Duration, v interface{}) bool {
+	info, err := os.Stat(cachePath(source, key))
+	if err != nil || time.Since(info.ModTime()) > maxAge {
+		return false
+	}
+	return readCache(source, key, v)
+}
+
+// writeCache stores an API response in the cache
+func writeCache(source, key string, v interface{}) error {
+	if importDryRun {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path := cachePath(source, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Written to a temporary file and renamed so a parallel run never reads a half written entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
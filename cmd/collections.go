@@ -0,0 +1,279 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Collection is a smart collection defined in the config
+type Collection struct {
+	Name string `mapstructure:"name"`
+	Rule string `mapstructure:"rule"`
+}
+
+// ruleTerm is a single condition in a collection rule, either a tag or a field comparison
+type ruleTerm struct {
+	Field  string
+	Op     string
+	Value  string
+	Negate bool
+}
+
+// collectionRule is a parsed rule, a list of alternatives (OR) each made of required terms (AND)
+type collectionRule [][]ruleTerm
+
+var collectionsDir string
+
+// collectionsCmd represents the collections command
+var collectionsCmd = &cobra.Command{
+	Use:   "collections",
+	Short: "Generate smart collection notes from rules in the config",
+	Long: `Generate or update collection notes with links to every note matching a rule.
+
+Collections are defined in the config file:
+
+  collections:
+    - name: Best of 1990s horror
+      rule: genre/Horror AND year>=1990 AND year<2000 AND my_rating>=8
+
+A term without an operator matches a tag (genre/Horror also matches genre/Horror/Slasher),
+other terms compare a frontmatter field with =, !=, >, >=, < or <=.
+Terms are combined with AND and OR (AND binds tighter), NOT negates a term.
+
+Links are written between hermes markers, anything else in the collection note is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateCollections()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(collectionsCmd)
+
+	collectionsCmd.Flags().StringVarP(&collectionsDir, "dir", "d", "", "Directory with notes to collect (default MarkdownOutputDir)")
+	collectionsCmd.MarkFlagDirname("dir")
+}
+
+func generateCollections() {
+	var collections []Collection
+	if err := viper.UnmarshalKey("collections", &collections); err != nil {
+		log.Errorf("Error reading collections from config: %v\n", err)
+		return
+	}
+	if len(collections) == 0 {
+		log.Warn("No collections defined in config")
+		return
+	}
+
+	if collectionsDir == "" {
+		collectionsDir = viper.GetString("MarkdownOutputDir")
+	}
+	outputDir := viper.GetString("CollectionsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "collections")
+	}
+
+	paths, err := findNotes(collectionsDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", collectionsDir, err)
+		return
+	}
+
+	var notes []*Note
+	for _, path := range paths {
+		// Don't collect the collection notes themselves
+		if isInDir(path, outputDir) {
+			continue
+		}
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	for _, collection := range collections {
+		collectionLogger := log.WithField("Collection", collection.Name)
+
+		rule, err := parseCollectionRule(collection.Rule)
+		if err != nil {
+			collectionLogger.Errorf("Invalid rule: %v\n", err)
+			continue
+		}
+
+		var matches []*Note
+		for _, note := range notes {
+			if rule.matches(note.Frontmatter) {
+				matches = append(matches, note)
+			}
+		}
+
+		if err := writeCollection(collection, matches, outputDir); err != nil {
+			collectionLogger.Errorf("Error writing collection: %v\n", err)
+			continue
+		}
+
+		collectionLogger.Infof("Collected %d notes\n", len(matches))
+	}
+}
+
+// writeCollection creates or updates the collection note with links to the matching notes
+func writeCollection(collection Collection, matches []*Note, directory string) error {
+	path := filepath.Join(directory, sanitizeFilename(collection.Name)+".md")
+
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i].Title()) < strings.ToLower(matches[j].Title())
+	})
+
+	var sb strings.Builder
+	for _, match := range matches {
+		sb.WriteString("- " + wikilink(match.Path) + "\n")
+	}
+
+	return updateNote(path, collection.Name, []string{"collection"}, func(note *Note) error {
+		note.Frontmatter.Set("rule", collection.Rule)
+		note.Frontmatter.Set("count", len(matches))
+		note.Body = replaceSection(note.Body, "collection", sb.String())
+		return nil
+	})
+}
+
+// parseCollectionRule parses a rule like "genre/Horror AND year>=1990 OR tag/favourite"
+func parseCollectionRule(rule string) (collectionRule, error) {
+	var parsed collectionRule
+
+	for _, alternative := range strings.Split(rule, " OR ") {
+		var terms []ruleTerm
+		for _, term := range strings.Split(alternative, " AND ") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+			terms = append(terms, parseRuleTerm(term))
+		}
+		if len(terms) > 0 {
+			parsed = append(parsed, terms)
+		}
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+
+	return parsed, nil
+}
+
+// parseRuleTerm parses a single tag or comparison term
+func parseRuleTerm(term string) ruleTerm {
+	var parsed ruleTerm
+	if strings.HasPrefix(term, "NOT ") {
+		parsed.Negate = true
+		term = strings.TrimSpace(strings.TrimPrefix(term, "NOT "))
+	}
+
+	// Two character operators first so >= isn't parsed as >
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if i := strings.Index(term, op); i > 0 {
+			parsed.Field = strings.TrimSpace(term[:i])
+			parsed.Op = op
+			parsed.Value = strings.TrimSpace(term[i+len(op):])
+			return parsed
+		}
+	}
+
+	parsed.Value = strings.TrimPrefix(term, "#")
+	return parsed
+}
+
+// matches returns true if the frontmatter satisfies any of the rule alternatives
+func (r collectionRule) matches(frontmatter *Frontmatter) bool {
+	for _, terms := range r {
+		matched := true
+		for _, term := range terms {
+			if term.matches(frontmatter) == term.Negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matches evaluates the term without negation
+func (t ruleTerm) matches(frontmatter *Frontmatter) bool {
+	if t.Op == "" {
+		return hasTag(frontmatter, t.Value)
+	}
+
+	values := frontmatter.GetStrings(t.Field)
+
+	switch t.Op {
+	case "=":
+		for _, value := range values {
+			if strings.EqualFold(value, t.Value) {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		for _, value := range values {
+			if strings.EqualFold(value, t.Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	expected, err := strconv.ParseFloat(t.Value, 64)
+	if err != nil {
+		return false
+	}
+	for _, value := range values {
+		actual, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case t.Op == ">" && actual > expected,
+			t.Op == ">=" && actual >= expected,
+			t.Op == "<" && actual < expected,
+			t.Op == "<=" && actual <= expected:
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag returns true if the note has the tag or a nested tag below it
+func hasTag(frontmatter *Frontmatter, tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, noteTag := range frontmatter.GetStrings("tags") {
+		noteTag = strings.ToLower(strings.TrimPrefix(noteTag, "#"))
+		if noteTag == tag || strings.HasPrefix(noteTag, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isInDir returns true if path is inside directory
+func isInDir(path, directory string) bool {
+	rel, err := filepath.Rel(directory, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,421 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const comicVineBaseURL = "https://comicvine.gamespot.com/api"
+
+// Comic represents a followed comic or manga series
+type Comic struct {
+	Title       string   `json:"Title"`
+	Status      string   `json:"Status"`
+	VolumesRead int      `json:"Volumes Read"`
+	ComicVineId string   `json:"ComicVine Id"`
+	MangaDexId  string   `json:"MangaDex Id"`
+	Publisher   string   `json:"Publisher"`
+	StartYear   int      `json:"Start Year"`
+	IssueCount  int      `json:"Issue Count"`
+	CoverURL    string   `json:"Cover URL"`
+	URL         string   `json:"URL"`
+	Authors     []string `json:"Authors"`
+}
+
+// comicVineVolume is the subset of the ComicVine volume resource we use
+type comicVineVolume struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	StartYear     string `json:"start_year"`
+	CountOfIssues int    `json:"count_of_issues"`
+	SiteDetailURL string `json:"site_detail_url"`
+	Publisher     *struct {
+		Name string `json:"name"`
+	} `json:"publisher"`
+	Image *struct {
+		OriginalURL string `json:"original_url"`
+	} `json:"image"`
+	PersonCredits []struct {
+		Name string `json:"name"`
+	} `json:"person_credits"`
+}
+
+// comicsCmd represents the comics command
+var comicsCmd = &cobra.Command{
+	Use:   "comics [file]",
+	Short: "Parse a ComicVine collection or MangaDex follow list export",
+	Long: `Parse a CSV export of followed comic or manga series and write one note per series.
+
+The file defaults to comics_export.csv. The CSV must have a header row, columns are matched by name:
+Title, Status, Volumes Read, ComicVine Id, MangaDex Id
+
+Series are enriched from the ComicVine API when ComicVineAPIKey is set in the config,
+API responses are cached in CacheDir.`,
+	ValidArgsFunction: completeInputFile("csv"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing comics export...")
+		parse_comics(inputFile(args, "comics_export.csv"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(comicsCmd)
+}
+
+func parse_comics(filename string) {
+	input, err := openInput(filename)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	var comics []Comic
+	if importJSONIn {
+		comics, err = readJSONLines[Comic](input)
+	} else {
+		comics, err = readComicsCSV(input)
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(comics); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d comics\n", len(comics))
+		return
+	}
+
+	if err := writeComicsToJson(comics); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err = writeComicsToMarkdown(comics)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d comics\n", len(comics))
+}
+
+// readComicsCSV reads and enriches the series from a follow list export
+func readComicsCSV(r io.Reader) ([]Comic, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // follow list exports vary in column count
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Map column names to their index, exports from different sites order them differently
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var comics []Comic
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		volumesRead, err := strconv.Atoi(field(record, "volumes read"))
+		if err != nil {
+			volumesRead = 0
+		}
+
+		comic := Comic{
+			Title:       field(record, "title"),
+			Status:      field(record, "status"),
+			VolumesRead: volumesRead,
+			ComicVineId: field(record, "comicvine id"),
+			MangaDexId:  field(record, "mangadex id"),
+		}
+
+		if comic.Title == "" && comic.ComicVineId == "" {
+			log.Warnf("Skipping row without title or ComicVine id: %v\n", record)
+			continue
+		}
+
+		err = withRetry(func() error { return enrichComic(&comic) })
+		if err != nil {
+			log.WithField("Title", comic.Title).Warnf("Error fetching ComicVine data: %v\n", err)
+		}
+		queueRetry("comics", comicKey(comic), comic, err)
+
+		comics = append(comics, comic)
+	}
+
+	return comics, nil
+}
+
+// comicKey identifies a series in the retry queue
+func comicKey(comic Comic) string {
+	if comic.ComicVineId != "" {
+		return comic.ComicVineId
+	}
+	return comic.Title
+}
+
+// retryComic re-enriches a queued series and rewrites its note
+func retryComic(data json.RawMessage) error {
+	var comic Comic
+	if err := json.Unmarshal(data, &comic); err != nil {
+		return err
+	}
+
+	if err := withRetry(func() error { return enrichComic(&comic) }); err != nil {
+		return err
+	}
+
+	_, err := writeComicToMarkdown(comic, newNoteRelocator("comicvine_id"))
+	return err
+}
+
+// enrichComic fills in series metadata from ComicVine, looking the series up by title if no id is known
+func enrichComic(comic *Comic) error {
+	apiKey := viper.GetString("ComicVineAPIKey")
+	if apiKey == "" {
+		return nil
+	}
+
+	var volume comicVineVolume
+	var err error
+	if comic.ComicVineId != "" {
+		volume, err = fetchComicVineVolume(apiKey, comic.ComicVineId)
+	} else {
+		volume, err = searchComicVineVolume(apiKey, comic.Title)
+	}
+	if err != nil {
+		return err
+	}
+
+	if volume.Id == 0 {
+		log.WithField("Title", comic.Title).Info("No ComicVine match found")
+		return nil
+	}
+
+	if comic.Title == "" {
+		comic.Title = volume.Name
+	}
+	comic.ComicVineId = strconv.Itoa(volume.Id)
+	comic.IssueCount = volume.CountOfIssues
+	comic.URL = volume.SiteDetailURL
+	if year, err := strconv.Atoi(volume.StartYear); err == nil {
+		comic.StartYear = year
+	}
+	if volume.Publisher != nil {
+		comic.Publisher = volume.Publisher.Name
+	}
+	if volume.Image != nil {
+		comic.CoverURL = volume.Image.OriginalURL
+	}
+	for _, person := range volume.PersonCredits {
+		comic.Authors = append(comic.Authors, person.Name)
+	}
+
+	return nil
+}
+
+// fetchComicVineVolume fetches a single volume by its ComicVine id
+func fetchComicVineVolume(apiKey, id string) (comicVineVolume, error) {
+	var volume comicVineVolume
+	if readCache("comicvine", "volume-"+id, &volume) {
+		return volume, nil
+	}
+
+	var response struct {
+		Error   string          `json:"error"`
+		Results comicVineVolume `json:"results"`
+	}
+	if err := getComicVine(fmt.Sprintf("/volume/4050-%s/", id), apiKey, url.Values{}, &response); err != nil {
+		return volume, err
+	}
+	if response.Error != "OK" {
+		return volume, fmt.Errorf("ComicVine error: %s", response.Error)
+	}
+
+	if err := writeCache("comicvine", "volume-"+id, response.Results); err != nil {
+		log.Warnf("Error caching ComicVine volume %s: %v\n", id, err)
+	}
+
+	return response.Results, nil
+}
+
+// searchComicVineVolume returns the best matching volume for a title
+func searchComicVineVolume(apiKey, title string) (comicVineVolume, error) {
+	var response struct {
+		Error   string            `json:"error"`
+		Results []comicVineVolume `json:"results"`
+	}
+
+	params := url.Values{}
+	params.Set("resources", "volume")
+	params.Set("query", title)
+	params.Set("limit", "1")
+	if err := getComicVine("/search/", apiKey, params, &response); err != nil {
+		return comicVineVolume{}, err
+	}
+	if response.Error != "OK" {
+		return comicVineVolume{}, fmt.Errorf("ComicVine error: %s", response.Error)
+	}
+	if len(response.Results) == 0 {
+		return comicVineVolume{}, nil
+	}
+
+	// Search results don't include credits, fetch the full volume
+	return fetchComicVineVolume(apiKey, strconv.Itoa(response.Results[0].Id))
+}
+
+// getComicVine performs a GET request against the ComicVine API and decodes the JSON response
+func getComicVine(path, apiKey string, params url.Values, v interface{}) error {
+	params.Set("api_key", apiKey)
+	params.Set("format", "json")
+
+	req, err := http.NewRequest(http.MethodGet, comicVineBaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// ComicVine rejects requests without a user agent
+	req.Header.Set("User-Agent", "hermes")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func writeComicsToJson(comics []Comic) error {
+	jsonData, err := json.Marshal(comics)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("comics.json", jsonData)
+}
+
+// writeComicToMarkdown writes series info to a markdown file
+func writeComicToMarkdown(comic Comic, relocator *noteRelocator) (string, error) {
+	author := ""
+	if len(comic.Authors) > 0 {
+		author = comic.Authors[0]
+	}
+
+	filePath, err := notePath("comics", map[string]string{
+		"title":     comic.Title,
+		"year":      yearString(comic.StartYear),
+		"decade":    decade(comic.StartYear),
+		"publisher": comic.Publisher,
+		"status":    comic.Status,
+		"author":    author,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(comic.ComicVineId, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{"comics"}
+	if comic.Status != "" {
+		tags = append(tags, "comics/"+slugify(comic.Status))
+	}
+	for _, author := range comic.Authors {
+		tags = append(tags, "author/"+slugify(author))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	sb.WriteString(fmt.Sprintf("title: %s\n", sanitizeTitle(comic.Title)))
+	if comic.StartYear > 0 {
+		sb.WriteString(fmt.Sprintf("year: %d\n", comic.StartYear))
+	}
+	if comic.Publisher != "" {
+		sb.WriteString(fmt.Sprintf("publisher: %s\n", sanitizeTitle(comic.Publisher)))
+	}
+	sb.WriteString(fmt.Sprintf("status: %s\n", comic.Status))
+	sb.WriteString(fmt.Sprintf("volumes_read: %d\n", comic.VolumesRead))
+	if comic.ComicVineId != "" {
+		sb.WriteString(fmt.Sprintf("comicvine_id: %s\n", comic.ComicVineId))
+		sb.WriteString(fmt.Sprintf("ids:\n  comicvine: %s\n", comic.ComicVineId))
+	}
+	if comic.IssueCount > 0 {
+		sb.WriteString(fmt.Sprintf("issues: %d\n", comic.IssueCount))
+	}
+	if comic.URL != "" {
+		sb.WriteString(fmt.Sprintf("url: %s\n", comic.URL))
+	}
+	if comic.CoverURL != "" {
+		sb.WriteString(fmt.Sprintf("cover: %s\n", comic.CoverURL))
+	}
+	if len(comic.Authors) > 0 {
+		sb.WriteString("authors:\n  - " + strings.Join(comic.Authors, "\n  - ") + "\n")
+		sb.WriteString(fmt.Sprintf("author_sort: %s\n", sortName(comic.Authors[0])))
+	}
+	sb.WriteString("tags:\n  - " + strings.Join(tags, "\n  - ") + "\n")
+	sb.WriteString("---\n\n")
+
+	if comic.CoverURL != "" {
+		sb.WriteString(fmt.Sprintf("![](%s)\n", comic.CoverURL))
+	}
+
+	_, err = writeNoteFile(filePath, relocator.restoreContent(comic.ComicVineId, filePath, sb.String()))
+	return filePath, err
+}
+
+// writeComicsToMarkdown writes a list of series to markdown files
+func writeComicsToMarkdown(comics []Comic) error {
+	relocator := newNoteRelocator("comicvine_id")
+	var entries []indexEntry
+	for _, comic := range comics {
+		path, err := writeComicToMarkdown(comic, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: comic.Title, Year: comic.StartYear})
+	}
+	return writeIndexNote("comics", entries)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// completeFiles completes file arguments with the given extensions, cobra adds the completion
+// command itself (hermes completion bash|zsh|fish|powershell)
+func completeFiles(exts ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return exts, cobra.ShellCompDirectiveFilterFileExt
+	}
+}
+
+// completeInputFile completes the single export file argument of an importer
+func completeInputFile(exts ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return exts, cobra.ShellCompDirectiveFilterFileExt
+	}
+}
+
+// completeDirs completes directory arguments
+func completeDirs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeSources completes comma separated source names, the sources with a path template
+func completeSources(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	sources := make(map[string]bool)
+	for source := range defaultPathTemplates {
+		sources[source] = true
+	}
+	for source := range viper.GetStringMapString("PathTemplates") {
+		sources[source] = true
+	}
+
+	// Sources already typed before the last comma aren't suggested again
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+		for _, source := range strings.Split(toComplete[:i], ",") {
+			delete(sources, source)
+		}
+	}
+
+	var completions []string
+	for source := range sources {
+		completions = append(completions, prefix+source)
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
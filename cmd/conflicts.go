@@ -0,0 +1,183 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	conflictsDir     string
+	conflictsResolve bool
+)
+
+// syncConflictPatterns match the names of the copies sync tools make of notes changed on two
+// devices, the first group is the name of the original note
+var syncConflictPatterns = []*regexp.Regexp{
+	// Dropbox and Nextcloud: "Heat (1995) (conflicted copy 2024-01-02).md", "Heat (Bob's conflicted copy 2024-01-02).md"
+	regexp.MustCompile(`^(.+?) \([^()]*conflicted copy[^()]*\)\.md$`),
+	// Syncthing: "Heat (1995).sync-conflict-20240102-150405-ABCDEFG.md"
+	regexp.MustCompile(`^(.+?)\.sync-conflict-\d{8}-\d{6}(?:-[A-Z0-9]+)?\.md$`),
+}
+
+// syncConflicts are the conflict copies findNotes skipped during the run
+var syncConflicts []string
+
+// conflictsCmd represents the conflicts command
+var conflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "List and merge the conflict copies of notes made by sync tools",
+	Long: `Sync tools like Dropbox and Syncthing keep a copy of a note changed on two devices, e.g.
+"Heat (1995) (conflicted copy 2024-01-02).md". Every command skips these copies, this command
+lists them with the frontmatter fields that differ from the original note.
+
+With --resolve-conflicts the copy is merged into the original and removed: fields missing from
+the original are added, lists get the missing values and the original wins for other fields
+unless it's empty. Copies with a different body are left for you to merge by hand.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listSyncConflicts()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(conflictsCmd)
+
+	conflictsCmd.Flags().StringVarP(&conflictsDir, "dir", "d", "", "Vault directory (default MarkdownOutputDir)")
+	conflictsCmd.MarkFlagDirname("dir")
+	conflictsCmd.Flags().BoolVar(&conflictsResolve, "resolve-conflicts", false, "Merge the copies into the original notes and remove them")
+}
+
+// syncConflictOriginal returns the path of the note a conflict copy was made of, false if the
+// path isn't a conflict copy
+func syncConflictOriginal(path string) (string, bool) {
+	for _, pattern := range syncConflictPatterns {
+		if match := pattern.FindStringSubmatch(filepath.Base(path)); match != nil {
+			return filepath.Join(filepath.Dir(path), match[1]+".md"), true
+		}
+	}
+	return "", false
+}
+
+// skipSyncConflict records a conflict copy found when listing notes, each is warned about once
+func skipSyncConflict(path string) {
+	if containsString(syncConflicts, path) {
+		return
+	}
+	syncConflicts = append(syncConflicts, path)
+	log.WithField("Path", path).Warn("Skipping sync conflict copy")
+}
+
+// reportSyncConflicts adds the number of skipped conflict copies to the summary
+func reportSyncConflicts() {
+	if len(syncConflicts) > 0 {
+		summaryf("Skipped %d sync conflict copies, see hermes conflicts\n", len(syncConflicts))
+	}
+}
+
+func listSyncConflicts() {
+	if conflictsDir == "" {
+		conflictsDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	if _, err := findNotes(conflictsDir); err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", conflictsDir, err)
+		return
+	}
+	sort.Strings(syncConflicts)
+
+	resolved := 0
+	for _, path := range syncConflicts {
+		original, _ := syncConflictOriginal(path)
+		merged, differences, err := mergeSyncConflict(original, path)
+		if err != nil {
+			log.WithField("Path", path).Warnf("Error comparing with %s: %v\n", original, err)
+			continue
+		}
+
+		fmt.Printf("%s\n  original: %s\n", path, original)
+		for _, difference := range differences {
+			fmt.Printf("  %s\n", difference)
+		}
+		if merged == nil {
+			fmt.Println("  body differs, merge by hand")
+			continue
+		}
+		if !conflictsResolve {
+			continue
+		}
+
+		if err := merged.Write(); err != nil {
+			log.WithField("Path", original).Errorf("Error writing merged note: %v\n", err)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.WithField("Path", path).Errorf("Error removing conflict copy: %v\n", err)
+			continue
+		}
+		resolved++
+	}
+
+	summaryf("Found %d sync conflict copies, resolved %d\n", len(syncConflicts), resolved)
+}
+
+// mergeSyncConflict merges the frontmatter of a conflict copy into its original note and describes
+// the differences. The merged note is nil if the bodies differ so it can't be merged automatically.
+func mergeSyncConflict(originalPath, conflictPath string) (*Note, []string, error) {
+	original, err := readNote(originalPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	conflict, err := readNote(conflictPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var differences []string
+	for _, key := range conflict.Frontmatter.Keys() {
+		value := conflict.Frontmatter.Get(key)
+		existing := original.Frontmatter.Get(key)
+		switch {
+		case existing == nil:
+			differences = append(differences, "added "+key)
+			original.Frontmatter.Set(key, value)
+		case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			values := original.Frontmatter.GetStrings(key)
+			merged := appendMissing(values, conflict.Frontmatter.GetStrings(key)...)
+			if len(merged) > len(values) {
+				differences = append(differences, fmt.Sprintf("added %s: %s", key, strings.Join(merged[len(values):], ", ")))
+				original.Frontmatter.Set(key, merged)
+			}
+		case existing.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && existing.Value != value.Value:
+			if existing.Value == "" {
+				differences = append(differences, fmt.Sprintf("set %s: %s", key, value.Value))
+				original.Frontmatter.Set(key, value)
+			} else {
+				differences = append(differences, fmt.Sprintf("kept %s: %s (copy has %s)", key, existing.Value, value.Value))
+			}
+		}
+	}
+
+	// Only the copy with more in its body can be taken as is
+	originalBody, conflictBody := strings.TrimSpace(original.Body), strings.TrimSpace(conflict.Body)
+	switch {
+	case strings.Contains(originalBody, conflictBody):
+	case strings.Contains(conflictBody, originalBody):
+		differences = append(differences, "body from the copy")
+		original.Body = conflict.Body
+	default:
+		return nil, differences, nil
+	}
+
+	return original, differences, nil
+}
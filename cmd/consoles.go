@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ConsoleGame is a game from a console play activity export, source is nintendo or psn
+type ConsoleGame struct {
+	Source          string   `json:"Source"`
+	TitleID         string   `json:"Title Id"`
+	Title           string   `json:"Title"`
+	Platform        string   `json:"Platform"` // switch, ps4, ps5
+	PlaytimeMinutes int      `json:"Playtime (mins)"`
+	FirstPlayed     string   `json:"First Played"`
+	LastPlayed      string   `json:"Last Played"`
+	IGDBId          int      `json:"IGDB Id"`
+	Year            int      `json:"Year"`
+	Developers      []string `json:"Developers"`
+	Publishers      []string `json:"Publishers"`
+	Genres          []string `json:"Genres"`
+	Cover           string   `json:"Cover"`
+	Description     string   `json:"Description"`
+}
+
+// igdbPlatforms maps the console platforms to IGDB platform ids
+var igdbPlatforms = map[string]int{
+	"switch": 130,
+	"ps4":    48,
+	"ps5":    167,
+}
+
+// igdbGame is the subset of the IGDB game object we use
+type igdbGame struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	FirstReleaseDate int64  `json:"first_release_date"`
+	Summary          string `json:"summary"`
+	Genres           []struct {
+		Name string `json:"name"`
+	} `json:"genres"`
+	InvolvedCompanies []struct {
+		Developer bool `json:"developer"`
+		Publisher bool `json:"publisher"`
+		Company   struct {
+			Name string `json:"name"`
+		} `json:"company"`
+	} `json:"involved_companies"`
+	Cover struct {
+		ImageID string `json:"image_id"`
+	} `json:"cover"`
+}
+
+// consoleDate converts an export timestamp to a date in the Timezone, the exports use RFC 3339 timestamps
+func consoleDate(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return ""
+	}
+	return localDate(t)
+}
+
+// consoleKey identifies a game in the retry queue and the IGDB cache
+func consoleKey(game ConsoleGame) string {
+	return game.Source + "-" + game.TitleID
+}
+
+// enrichConsoleGames fills in metadata from IGDB, queueing games that failed with a transient error
+func enrichConsoleGames(games []ConsoleGame) {
+	if viper.GetString("IGDBClientID") == "" || viper.GetString("IGDBClientSecret") == "" {
+		log.Warn("IGDBClientID and IGDBClientSecret not set, skipping IGDB metadata")
+		return
+	}
+
+	for i := range games {
+		err := withRetry(func() error { return enrichConsoleGame(&games[i]) })
+		if err != nil {
+			log.WithField("Title", games[i].Title).Warnf("Error fetching IGDB data: %v\n", err)
+		}
+		queueRetry("console", consoleKey(games[i]), games[i], err)
+	}
+}
+
+// retryConsoleGame re-enriches a queued game and rewrites its note
+func retryConsoleGame(data json.RawMessage) error {
+	var game ConsoleGame
+	if err := json.Unmarshal(data, &game); err != nil {
+		return err
+	}
+
+	if err := withRetry(func() error { return enrichConsoleGame(&game) }); err != nil {
+		return err
+	}
+
+	_, err := writeConsoleGameToMarkdown(game, newNoteRelocator(consoleIdField(game.Source)))
+	return err
+}
+
+// enrichConsoleGame finds the game on IGDB by title and platform
+func enrichConsoleGame(game *ConsoleGame) error {
+	var found igdbGame
+	key := consoleKey(*game)
+	if !readCache("igdb", key, &found) {
+		result, err := searchIGDBGame(game.Title, igdbPlatforms[game.Platform])
+		if err != nil {
+			return err
+		}
+		// Misses are cached as an empty game so they aren't looked up on every run
+		if result != nil {
+			found = *result
+		}
+		if err := writeCache("igdb", key, found); err != nil {
+			log.Warnf("Error caching IGDB game %s: %v\n", key, err)
+		}
+	}
+	if found.ID == 0 {
+		log.WithField("Title", game.Title).Debug("Game not found on IGDB")
+		return nil
+	}
+
+	game.IGDBId = found.ID
+	if found.FirstReleaseDate > 0 {
+		game.Year = time.Unix(found.FirstReleaseDate, 0).UTC().Year()
+	}
+	game.Description = found.Summary
+	game.Genres = nil
+	for _, genre := range found.Genres {
+		game.Genres = append(game.Genres, genre.Name)
+	}
+	game.Developers, game.Publishers = nil, nil
+	for _, company := range found.InvolvedCompanies {
+		if company.Developer {
+			game.Developers = append(game.Developers, company.Company.Name)
+		}
+		if company.Publisher {
+			game.Publishers = append(game.Publishers, company.Company.Name)
+		}
+	}
+	if found.Cover.ImageID != "" {
+		game.Cover = "https://images.igdb.com/igdb/image/upload/t_cover_big/" + found.Cover.ImageID + ".jpg"
+	}
+
+	return nil
+}
+
+// igdbTrademarks are stripped from titles before searching, the console stores keep them in the names
+var igdbTrademarks = strings.NewReplacer("™", "", "®", "", "©", "")
+
+// searchIGDBGame returns the best IGDB match for a title on a platform, nil if there's no match
+func searchIGDBGame(title string, platform int) (*igdbGame, error) {
+	token, err := igdbAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	search := strings.ReplaceAll(igdbTrademarks.Replace(title), `"`, `\"`)
+	query := fmt.Sprintf(`search "%s"; fields name,first_release_date,summary,genres.name,`+
+		`involved_companies.developer,involved_companies.publisher,involved_companies.company.name,cover.image_id;`, search)
+	if platform > 0 {
+		query += fmt.Sprintf(" where platforms = (%d);", platform)
+	}
+	query += " limit 1;"
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.igdb.com/v4/games", strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Client-ID", viper.GetString("IGDBClientID"))
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var games []igdbGame
+	if err := json.NewDecoder(resp.Body).Decode(&games); err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		return nil, nil
+	}
+	return &games[0], nil
+}
+
+// igdbToken is the app access token of the run, fetched on first use
+var igdbToken string
+
+// igdbAccessToken returns an app access token from Twitch, which handles IGDB authentication
+func igdbAccessToken() (string, error) {
+	if igdbToken != "" {
+		return igdbToken, nil
+	}
+
+	params := url.Values{}
+	params.Set("client_id", viper.GetString("IGDBClientID"))
+	params.Set("client_secret", viper.GetString("IGDBClientSecret"))
+	params.Set("grant_type", "client_credentials")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm("https://id.twitch.tv/oauth2/token", params)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	igdbToken = response.AccessToken
+	return igdbToken, nil
+}
+
+func writeConsoleGamesToJson(source string, games []ConsoleGame) error {
+	jsonData, err := json.Marshal(games)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(source+".json", jsonData)
+}
+
+// consoleIdField is the frontmatter field with the title id of a source, e.g. nintendo_title_id
+func consoleIdField(source string) string {
+	return source + "_title_id"
+}
+
+// writeConsoleGameToMarkdown writes a console game to a markdown file
+func writeConsoleGameToMarkdown(game ConsoleGame, relocator *noteRelocator) (string, error) {
+	developer := ""
+	if len(game.Developers) > 0 {
+		developer = game.Developers[0]
+	}
+
+	filePath, err := notePath(game.Source, map[string]string{
+		"title":     game.Title,
+		"year":      yearString(game.Year),
+		"decade":    decade(game.Year),
+		"developer": developer,
+		"platform":  game.Platform,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(game.TitleID, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{game.Source + "/game", "platform/" + game.Platform}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", game.Title)
+	if aliases := noteAliases(filePath, game.Title); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set(consoleIdField(game.Source), game.TitleID)
+	if game.IGDBId > 0 {
+		frontmatter.Set("igdb_id", strconv.Itoa(game.IGDBId))
+	}
+	frontmatter.Set("platform", game.Platform)
+	if game.Year > 0 {
+		frontmatter.Set("year", game.Year)
+	}
+	frontmatter.Set("playtime_hours", float64(game.PlaytimeMinutes/6)/10)
+	if game.FirstPlayed != "" {
+		frontmatter.Set("first_played", game.FirstPlayed)
+	}
+	if game.LastPlayed != "" {
+		frontmatter.Set("last_played", game.LastPlayed)
+	}
+	if len(game.Developers) > 0 {
+		frontmatter.Set("developers", game.Developers)
+	}
+	if len(game.Publishers) > 0 {
+		frontmatter.Set("publishers", game.Publishers)
+	}
+	if len(game.Genres) > 0 {
+		frontmatter.Set("genres", game.Genres)
+	}
+	if game.Cover != "" {
+		frontmatter.Set("cover", game.Cover)
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if game.Cover != "" {
+		body += fmt.Sprintf("![](%s)\n\n", game.Cover)
+	}
+	if game.Description != "" {
+		body += game.Description + "\n"
+	}
+	if game.IGDBId == 0 && viper.GetString("IGDBClientID") != "" {
+		body += todoTasks("find IGDB match")
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(game.TitleID, note)
+	return filePath, note.Write()
+}
+
+// writeConsoleGamesToMarkdown writes the games of a source to markdown files
+func writeConsoleGamesToMarkdown(source string, games []ConsoleGame) error {
+	relocator := newNoteRelocator(consoleIdField(source))
+	var entries []indexEntry
+	for _, game := range games {
+		path, err := writeConsoleGameToMarkdown(game, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: game.Title, Year: game.Year})
+	}
+	return writeIndexNote(source, entries)
+}
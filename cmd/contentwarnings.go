@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lepinkainen/hermes/internal/titlematch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Values of ContentWarnings.Provider
+const (
+	warningsTMDB          = "tmdb"
+	warningsDoesTheDogDie = "doesthedogdie"
+)
+
+// tmdbWarningKeywords maps the TMDB keywords that are content warnings to a topic. Most keywords
+// describe the plot, so only these and the ones in ContentWarnings.Keywords are used.
+var tmdbWarningKeywords = map[string]string{
+	"animal cruelty":    "animal cruelty",
+	"animal death":      "animal death",
+	"death of a pet":    "animal death",
+	"dog death":         "animal death",
+	"child abuse":       "child abuse",
+	"death of a child":  "child death",
+	"domestic violence": "domestic violence",
+	"drug addiction":    "drug use",
+	"drug use":          "drug use",
+	"alcoholism":        "alcohol abuse",
+	"gore":              "gore",
+	"splatter":          "gore",
+	"body horror":       "gore",
+	"torture":           "torture",
+	"rape":              "sexual violence",
+	"sexual abuse":      "sexual violence",
+	"suicide":           "suicide",
+	"self harm":         "self harm",
+	"eating disorder":   "eating disorder",
+	"nudity":            "nudity",
+	"female nudity":     "nudity",
+	"male nudity":       "nudity",
+	"sex scene":         "sex",
+	"racism":            "racism",
+	"homophobia":        "homophobia",
+	"cannibalism":       "cannibalism",
+	"school shooting":   "gun violence",
+	"mass shooting":     "gun violence",
+	"kidnapping":        "kidnapping",
+	"miscarriage":       "pregnancy loss",
+	"terminal illness":  "terminal illness",
+	"jump scare":        "jump scares",
+}
+
+// dddMatchScore is the title similarity a DoesTheDogDie search result needs to be the searched title
+const dddMatchScore = 0.93
+
+// dddMinVotes is how many more DoesTheDogDie users must say yes than no for a topic to count
+const dddMinVotes = 1
+
+// warnedWarningsProvider is set once an unknown ContentWarnings.Provider has been warned about
+var warnedWarningsProvider bool
+
+// contentWarningsEnabled returns true if content warnings are fetched
+func contentWarningsEnabled() bool {
+	switch provider := viper.GetString("ContentWarnings.Provider"); provider {
+	case "":
+		return false
+	case warningsTMDB, warningsDoesTheDogDie:
+		return true
+	default:
+		if !warnedWarningsProvider {
+			log.Warnf("Unknown ContentWarnings.Provider %q, use tmdb or doesthedogdie\n", provider)
+			warnedWarningsProvider = true
+		}
+		return false
+	}
+}
+
+// fetchContentWarnings returns the content warning topics of a movie or TV show from the
+// ContentWarnings.Provider, sorted. TMDB needs the TMDB id, DoesTheDogDie finds titles by the
+// IMDb id when known and by the title and year otherwise. The warnings of a title rarely change,
+// they are cached per provider.
+func fetchContentWarnings(mediaType string, tmdbID int, imdbID, title string, year int) ([]string, error) {
+	provider := viper.GetString("ContentWarnings.Provider")
+
+	var key string
+	switch {
+	case provider == warningsTMDB && tmdbID != 0:
+		key = mediaType + "-" + strconv.Itoa(tmdbID)
+	case provider == warningsDoesTheDogDie && imdbID != "":
+		key = imdbID
+	case provider == warningsDoesTheDogDie && title != "":
+		key = fmt.Sprintf("%s-%s (%d)", mediaType, title, year)
+	default:
+		return nil, nil
+	}
+
+	var warnings []string
+	if readCache("contentwarnings/"+provider, key, &warnings) {
+		return warnings, nil
+	}
+
+	err := withRetry(func() error {
+		var err error
+		if provider == warningsTMDB {
+			warnings, err = fetchTMDBWarnings(viper.GetString("TMDBAccessToken"), mediaType, tmdbID)
+		} else {
+			warnings, err = fetchDoesTheDogDieWarnings(imdbID, title, year)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(warnings)
+	if warnings == nil {
+		// Cached as an empty list so titles without warnings aren't fetched again
+		warnings = []string{}
+	}
+
+	if err := writeCache("contentwarnings/"+provider, key, warnings); err != nil {
+		log.Warnf("Error caching content warnings %s: %v\n", key, err)
+	}
+	return warnings, nil
+}
+
+// fetchTMDBWarnings returns the topics of the content warning keywords of a title
+func fetchTMDBWarnings(token, mediaType string, id int) ([]string, error) {
+	type keyword struct {
+		Name string `json:"name"`
+	}
+	var response struct {
+		// Movies have keywords, TV shows results
+		Keywords []keyword `json:"keywords"`
+		Results  []keyword `json:"results"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/keywords", mediaType, id)
+	if err := tmdbRequest(http.MethodGet, url, token, nil, &response); err != nil {
+		return nil, err
+	}
+
+	// Keys are lowercased by the config
+	custom := viper.GetStringMapString("ContentWarnings.Keywords")
+	var warnings []string
+	for _, keyword := range append(response.Keywords, response.Results...) {
+		name := strings.ToLower(keyword.Name)
+		if topic, ok := custom[name]; ok {
+			warnings = appendMissing(warnings, topic)
+		} else if topic, ok := tmdbWarningKeywords[name]; ok {
+			warnings = appendMissing(warnings, topic)
+		}
+	}
+	return warnings, nil
+}
+
+// dddItem is a title in the DoesTheDogDie search results
+type dddItem struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ReleaseYear string `json:"releaseYear"`
+}
+
+// fetchDoesTheDogDieWarnings returns the topics DoesTheDogDie users have voted yes for a title
+func fetchDoesTheDogDieWarnings(imdbID, title string, year int) ([]string, error) {
+	query := url.Values{}
+	if imdbID != "" {
+		query.Set("imdb", imdbID)
+	} else {
+		query.Set("q", title)
+	}
+	var search struct {
+		Items []dddItem `json:"items"`
+	}
+	if err := getDoesTheDogDieJSON("https://www.doesthedogdie.com/dddsearch?"+query.Encode(), &search); err != nil {
+		return nil, err
+	}
+
+	item := findDoesTheDogDieItem(search.Items, imdbID, title, year)
+	if item == nil {
+		return nil, nil
+	}
+
+	var media struct {
+		TopicItemStats []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+			YesSum int `json:"yesSum"`
+			NoSum  int `json:"noSum"`
+		} `json:"topicItemStats"`
+	}
+	if err := getDoesTheDogDieJSON(fmt.Sprintf("https://www.doesthedogdie.com/media/%d", item.ID), &media); err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, stat := range media.TopicItemStats {
+		if stat.YesSum-stat.NoSum >= dddMinVotes {
+			warnings = appendMissing(warnings, strings.TrimSpace(stat.Topic.Name))
+		}
+	}
+	return warnings, nil
+}
+
+// findDoesTheDogDieItem picks the searched title from the results, an IMDb id search has one result
+func findDoesTheDogDieItem(items []dddItem, imdbID, title string, year int) *dddItem {
+	for i, item := range items {
+		if imdbID != "" {
+			return &items[i]
+		}
+		if titlematch.Similarity(item.Name, title) < dddMatchScore {
+			continue
+		}
+		if year == 0 || item.ReleaseYear == "" || item.ReleaseYear == strconv.Itoa(year) {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+// getDoesTheDogDieJSON performs a GET request against the DoesTheDogDie API
+func getDoesTheDogDieJSON(url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-API-KEY", viper.GetString("ContentWarnings.DoesTheDogDieAPIKey"))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// contentWarningTags returns the cw/<topic> tags of the warnings
+func contentWarningTags(warnings []string) []string {
+	var tags []string
+	for _, warning := range warnings {
+		tags = appendMissing(tags, "cw/"+slugify(warning))
+	}
+	return tags
+}
+
+// contentWarningCallout renders the warnings as a collapsed callout, empty without warnings
+func contentWarningCallout(warnings []string) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("> [!warning]- Content warnings\n")
+	for _, warning := range warnings {
+		sb.WriteString("> - " + warning + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+var countriesDir string
+
+// countriesCmd represents the countries command
+var countriesCmd = &cobra.Command{
+	Use:   "countries",
+	Short: "Generate a films by country dashboard note",
+	Long: `Collect the country frontmatter of the notes, set by the IMDb and TMDB importers when
+TMDBAccessToken is set, and write a dashboard listing the films of each country to
+"Films by country.md" in StatsOutputDir (default <MarkdownOutputDir>/stats).
+
+The dashboard is written between hermes markers, anything else in the note is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateCountries()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(countriesCmd)
+
+	countriesCmd.Flags().StringVarP(&countriesDir, "dir", "d", "", "Directory with movie notes (default MarkdownOutputDir)")
+	countriesCmd.MarkFlagDirname("dir")
+}
+
+func generateCountries() {
+	if countriesDir == "" {
+		countriesDir = viper.GetString("MarkdownOutputDir")
+	}
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	paths, err := findNotes(countriesDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", countriesDir, err)
+		return
+	}
+
+	countries := make(map[string][]rollupEntry)
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		entry := rollupEntry{
+			Path:   path,
+			Title:  note.Title(),
+			Year:   note.Frontmatter.GetInt("year"),
+			Rating: note.Frontmatter.GetFloat("my_rating"),
+		}
+		for _, country := range note.Frontmatter.GetStrings("country") {
+			countries[country] = append(countries[country], entry)
+		}
+	}
+
+	if err := writeCountriesNote(filepath.Join(outputDir, "Films by country.md"), countries); err != nil {
+		log.Errorf("Error writing countries note: %v\n", err)
+		return
+	}
+
+	summaryf("Found films from %d countries\n", len(countries))
+}
+
+// countryName returns the English name of an ISO 3166-1 country code, the code itself if it's unknown
+func countryName(code string) string {
+	region, err := language.ParseRegion(code)
+	if err != nil {
+		return code
+	}
+	if name := display.English.Regions().Name(region); name != "" {
+		return name
+	}
+	return code
+}
+
+// writeCountriesNote creates or updates the dashboard, countries with the most films first
+func writeCountriesNote(path string, countries map[string][]rollupEntry) error {
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if len(countries[codes[i]]) != len(countries[codes[j]]) {
+			return len(countries[codes[i]]) > len(countries[codes[j]])
+		}
+		return countryName(codes[i]) < countryName(codes[j])
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| Country | Films | Average rating |\n|---|---|---|\n")
+	for _, code := range codes {
+		average := "-"
+		if value, ok := averageRating(countries[code]); ok {
+			average = fmt.Sprintf("%.1f", value)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %d | %s |\n", countryName(code), len(countries[code]), average))
+	}
+
+	for _, code := range codes {
+		entries := countries[code]
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Year != entries[j].Year {
+				return entries[i].Year < entries[j].Year
+			}
+			return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+		})
+
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", countryName(code)))
+		for _, entry := range entries {
+			sb.WriteString("- " + wikilink(entry.Path))
+			if entry.Rating > 0 {
+				sb.WriteString(fmt.Sprintf(" (%g)", entry.Rating))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return updateNote(path, "Films by country", []string{"stats"}, func(note *Note) error {
+		note.Frontmatter.Set("countries", len(countries))
+
+		note.Body = replaceSection(note.Body, "countries", sb.String())
+
+		return nil
+	})
+}
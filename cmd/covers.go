@@ -0,0 +1,246 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	coversDir      string
+	coversMinWidth int
+)
+
+// coverUpgrades rewrite a cover URL to a larger size of the same image. The candidates are tried
+// in order, so the preferred size of each host comes first.
+var coverUpgrades = []struct {
+	pattern      *regexp.Regexp
+	replacements []string
+}{
+	// TMDB posters: /t/p/w500/ -> /t/p/original/
+	{regexp.MustCompile(`(image\.tmdb\.org/t/p/)w\d+/`), []string{"${1}w780/", "${1}original/"}},
+	// IGDB: t_cover_big -> t_cover_big_2x
+	{regexp.MustCompile(`(images\.igdb\.com/igdb/image/upload/)t_(?:thumb|cover_small|cover_big)/`), []string{"${1}t_cover_big_2x/", "${1}t_720p/"}},
+	// Goodreads and Amazon: "cover._SX98_.jpg" -> "cover.jpg"
+	{regexp.MustCompile(`\._[A-Z]{2}\d+_(\.\w+)$`), []string{"$1"}},
+	// Google Books thumbnails are 128 wide
+	{regexp.MustCompile(`(books\.google\.com/books/\S*?)&zoom=\d`), []string{"${1}&zoom=1&fife=w800"}},
+	// Comic Vine
+	{regexp.MustCompile(`(comicvine\.gamespot\.com/a/uploads/)scale_\w+/`), []string{"${1}original/"}},
+	// AniList
+	{regexp.MustCompile(`(/cover/)(?:small|medium)/`), []string{"${1}large/"}},
+	// Steam store headers are 460x215, the library capsule is the portrait cover
+	{regexp.MustCompile(`(/steam/apps/\d+/)header\.jpg`), []string{"${1}library_600x900_2x.jpg", "${1}library_600x900.jpg"}},
+}
+
+// coversCmd represents the covers command
+var coversCmd = &cobra.Command{
+	Use:   "covers",
+	Short: "Manage the covers of notes",
+}
+
+// coversRefreshCmd represents the covers refresh command
+var coversRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Replace low resolution covers with larger versions",
+	Long: `Find notes whose cover image is narrower than --min-width pixels and replace it with a
+larger version of the same image from the same site: TMDB, IGDB, Goodreads, Google Books,
+Comic Vine, AniList and Steam covers have larger sizes. Steam games without a cover get the
+library cover of their steam_appid.
+
+Only the cover field and images of the old cover in the note body are changed. Image sizes
+are cached in CacheDir, covers that can't be made larger and notes of other sources without
+a cover are counted at the end, import them again to look for a cover. Imports keep the larger
+cover, and the cover of games the source has none for.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		refreshCovers()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coversCmd)
+	coversCmd.AddCommand(coversRefreshCmd)
+
+	coversRefreshCmd.Flags().StringVarP(&coversDir, "dir", "d", "", "Vault directory (default MarkdownOutputDir)")
+	coversRefreshCmd.MarkFlagDirname("dir")
+	coversRefreshCmd.Flags().IntVar(&coversMinWidth, "min-width", 600, "Covers narrower than this are replaced")
+}
+
+func refreshCovers() {
+	if coversDir == "" {
+		coversDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	paths, err := findNotes(coversDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", coversDir, err)
+		return
+	}
+
+	var refreshed, small, missing int
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		cover := note.Frontmatter.GetString("cover")
+		var candidates []string
+		width := 0
+		switch {
+		case cover == "" && noteID(note.Frontmatter, "steam_appid") != "":
+			candidates = []string{"https://cdn.akamai.steamstatic.com/steam/apps/" + noteID(note.Frontmatter, "steam_appid") + "/library_600x900_2x.jpg"}
+		case cover == "":
+			missing++
+			continue
+		case !strings.HasPrefix(cover, "https://") && !strings.HasPrefix(cover, "http://"):
+			// Attachments in the vault
+			continue
+		default:
+			width, err = coverWidth(cover)
+			if err != nil {
+				log.WithField("Path", path).Warnf("Error reading cover %s: %v\n", cover, err)
+				continue
+			}
+			if width >= coversMinWidth {
+				continue
+			}
+			candidates = largerCovers(cover)
+		}
+
+		best, bestWidth := "", width
+		for _, candidate := range candidates {
+			candidateWidth, err := coverWidth(candidate)
+			if err != nil {
+				log.WithField("Path", path).Debugf("No cover at %s: %v\n", candidate, err)
+				continue
+			}
+			if candidateWidth > bestWidth {
+				best, bestWidth = candidate, candidateWidth
+			}
+			if bestWidth >= coversMinWidth {
+				break
+			}
+		}
+		if best == "" {
+			log.WithField("Path", path).Infof("No larger cover than %dpx found\n", width)
+			small++
+			continue
+		}
+
+		note.Frontmatter.Set("cover", best)
+		if cover != "" {
+			note.Body = strings.ReplaceAll(note.Body, "]("+cover+")", "]("+best+")")
+		}
+		if err := note.Write(); err != nil {
+			log.WithField("Path", path).Errorf("Error writing note: %v\n", err)
+			continue
+		}
+		log.WithField("Path", path).Infof("Cover %dpx -> %dpx\n", width, bestWidth)
+		refreshed++
+	}
+
+	summaryf("Refreshed %d covers, %d can't be made larger, %d notes have no cover\n", refreshed, small, missing)
+}
+
+// keepUpgradedCover sets the cover of a note being imported back to the larger size of it the
+// existing note has, or to the existing cover if the import has none. Returns true if it did.
+func keepUpgradedCover(note *Note, existing string) bool {
+	cover := note.Frontmatter.GetString("cover")
+	if existing == "" || existing == cover {
+		return false
+	}
+	if cover == "" {
+		note.Frontmatter.Set("cover", existing)
+		return true
+	}
+	if !containsString(largerCovers(cover), existing) {
+		return false
+	}
+	note.Frontmatter.Set("cover", existing)
+	note.Body = strings.ReplaceAll(note.Body, "]("+cover+")", "]("+existing+")")
+	return true
+}
+
+// largerCovers returns the URLs of larger sizes of a cover image
+func largerCovers(cover string) []string {
+	var candidates []string
+	for _, upgrade := range coverUpgrades {
+		if !upgrade.pattern.MatchString(cover) {
+			continue
+		}
+		for _, replacement := range upgrade.replacements {
+			if candidate := upgrade.pattern.ReplaceAllString(cover, replacement); candidate != cover {
+				candidates = appendMissing(candidates, candidate)
+			}
+		}
+	}
+	return candidates
+}
+
+// coverWidth returns the width of the image at the URL, reading only its header. Images that
+// don't exist are cached as 0 wide so they aren't requested again.
+func coverWidth(url string) (int, error) {
+	var cached struct {
+		Width int `json:"width"`
+	}
+	// URLs are too long for file names
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(url)))
+	if readCache("coverwidth", key, &cached) {
+		if cached.Width == 0 {
+			return 0, errors.New("not found")
+		}
+		return cached.Width, nil
+	}
+
+	err := withRetry(func() error {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+
+		config, _, err := image.DecodeConfig(resp.Body)
+		if err != nil {
+			return err
+		}
+		cached.Width = config.Width
+		return nil
+	})
+	var statusErr httpStatusError
+	if err != nil && !(errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound) {
+		return 0, err
+	}
+
+	if err := writeCache("coverwidth", key, cached); err != nil {
+		log.Warnf("Error caching cover size of %s: %v\n", url, err)
+	}
+	if cached.Width == 0 {
+		return 0, errors.New("not found")
+	}
+	return cached.Width, nil
+}
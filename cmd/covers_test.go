@@ -0,0 +1,40 @@
+package cmd
+
+import "testing"
+
+func TestKeepUpgradedCover(t *testing.T) {
+	const small = "https://image.tmdb.org/t/p/w500/heat.jpg"
+	const large = "https://image.tmdb.org/t/p/original/heat.jpg"
+
+	tests := []struct {
+		name      string
+		cover     string
+		existing  string
+		wantCover string
+		wantBody  string
+	}{
+		{"upgraded", small, large, large, "![](" + large + ")\n"},
+		{"same", small, small, small, "![](" + small + ")\n"},
+		{"new image", small, "https://image.tmdb.org/t/p/original/other.jpg", small, "![](" + small + ")\n"},
+		{"no existing note", small, "", small, "![](" + small + ")\n"},
+		{"no cover in the import", "", large, large, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			note := &Note{Frontmatter: newFrontmatter()}
+			if tt.cover != "" {
+				note.Frontmatter.Set("cover", tt.cover)
+				note.Body = "![](" + tt.cover + ")\n"
+			}
+
+			keepUpgradedCover(note, tt.existing)
+			if got := note.Frontmatter.GetString("cover"); got != tt.wantCover {
+				t.Errorf("cover = %q, want %q", got, tt.wantCover)
+			}
+			if note.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", note.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// encryptedBlock matches an ASCII armored age message, optionally inside a callout
+var encryptedBlock = regexp.MustCompile(`(?m)^(> )?-----BEGIN AGE ENCRYPTED FILE-----\n(?:(?:> )?[A-Za-z0-9+/=]*\n)*?(?:> )?-----END AGE ENCRYPTED FILE-----$`)
+
+// encryptText encrypts text to an age X25519 recipient and returns it ASCII armored
+func encryptText(text, recipient string) (string, error) {
+	r, err := age.ParseX25519Recipient(strings.TrimSpace(recipient))
+	if err != nil {
+		return "", fmt.Errorf("invalid AgeRecipient: %w", err)
+	}
+
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, text); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := armored.Close(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// decryptText decrypts an ASCII armored age message, callout prefixes are removed first
+func decryptText(block string, identities []age.Identity) (string, error) {
+	lines := strings.Split(block, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "> ")
+	}
+
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(strings.Join(lines, "\n")+"\n")), identities...)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// readAgeIdentities reads the identities of an age identity file, as written by age-keygen
+func readAgeIdentities(filename string) ([]age.Identity, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return age.ParseIdentities(file)
+}
+
+// importIdentities are the AgeIdentityFile identities, read once per run
+var importIdentities struct {
+	loaded     bool
+	identities []age.Identity
+}
+
+// existingEncryption returns the encrypted block of the note at path that decrypts to text, without
+// callout prefixes. age output is random, so encrypting unchanged text again would rewrite the note
+// on every import. Empty if AgeIdentityFile isn't set or no block of the note decrypts to text.
+func existingEncryption(path, text string) string {
+	if !importIdentities.loaded {
+		importIdentities.loaded = true
+		if filename := viper.GetString("AgeIdentityFile"); filename != "" {
+			identities, err := readAgeIdentities(filename)
+			if err != nil {
+				log.Warnf("Error reading identity %s, encrypted notes are rewritten: %v\n", filename, err)
+			}
+			importIdentities.identities = identities
+		}
+	}
+	if len(importIdentities.identities) == 0 {
+		return ""
+	}
+
+	note, err := readNote(path)
+	if err != nil {
+		return ""
+	}
+	for _, block := range encryptedBlock.FindAllString(note.Body, -1) {
+		plaintext, err := decryptText(block, importIdentities.identities)
+		if err == nil && plaintext == text {
+			return strings.ReplaceAll(strings.TrimPrefix(block, "> "), "\n> ", "\n")
+		}
+	}
+	return ""
+}
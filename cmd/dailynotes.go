@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	importDailyNotes       string
+	importDailyNotesFormat string
+)
+
+// dailyActivities are the bullets added to the daily notes at the end of the import, by date
+var dailyActivities = make(map[string][]string)
+
+// momentTokens are the moment.js date tokens Obsidian uses in daily note formats, longest first
+var momentTokens = []struct {
+	token  string
+	format func(time.Time) string
+}{
+	{"YYYY", func(t time.Time) string { return t.Format("2006") }},
+	{"YY", func(t time.Time) string { return t.Format("06") }},
+	{"MMMM", func(t time.Time) string { return t.Format("January") }},
+	{"MMM", func(t time.Time) string { return t.Format("Jan") }},
+	{"MM", func(t time.Time) string { return t.Format("01") }},
+	{"M", func(t time.Time) string { return t.Format("1") }},
+	{"DDDD", func(t time.Time) string { return fmt.Sprintf("%03d", t.YearDay()) }},
+	{"DD", func(t time.Time) string { return t.Format("02") }},
+	{"D", func(t time.Time) string { return t.Format("2") }},
+	{"dddd", func(t time.Time) string { return t.Format("Monday") }},
+	{"ddd", func(t time.Time) string { return t.Format("Mon") }},
+}
+
+// addDailyActivity queues a bullet like "Watched [[Heat (1995)]] ★★★★" for the daily note of the date
+// when --daily-notes is set. Dates that can't be parsed are skipped.
+func addDailyActivity(date, verb, notePath, suffix string) {
+	if importDailyNotes == "" || date == "" {
+		return
+	}
+	day, err := parseWatchDate(date)
+	if err != nil {
+		log.WithField("Path", notePath).Debugf("Skipping daily note activity: %v\n", err)
+		return
+	}
+
+	line := "- " + verb + " " + wikilink(notePath)
+	if suffix != "" {
+		line += " " + suffix
+	}
+	key := day.Format("2006-01-02")
+	for i, queued := range dailyActivities[key] {
+		if dailyActivityKey(queued) == dailyActivityKey(line) {
+			dailyActivities[key][i] = line
+			return
+		}
+	}
+	dailyActivities[key] = append(dailyActivities[key], line)
+}
+
+// dailyActivityKey returns the verb and wikilink of an activity line, which identify it. The rating
+// after them can change between imports.
+func dailyActivityKey(line string) string {
+	if i := strings.Index(line, "]]"); i >= 0 {
+		return line[:i+2]
+	}
+	return line
+}
+
+// ratingStars shows a rating as one to five stars, ratings out of 10 are halved
+func ratingStars(rating, scale float64) string {
+	if rating <= 0 {
+		return ""
+	}
+	stars := int(math.Round(rating * 5 / scale))
+	if stars < 1 {
+		stars = 1
+	}
+	return strings.Repeat("★", stars)
+}
+
+// writeDailyNotes appends the queued activities missing from the daily notes and updates the ones
+// whose rating changed, creating the notes that don't exist yet
+func writeDailyNotes() {
+	if importDailyNotes == "" || len(dailyActivities) == 0 {
+		return
+	}
+
+	format := importDailyNotesFormat
+	if format == "" {
+		format = vaultDailyNoteFormat(importDailyNotes)
+	}
+
+	dates := make([]string, 0, len(dailyActivities))
+	for date := range dailyActivities {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		day, _ := time.Parse("2006-01-02", date)
+		path := filepath.Join(importDailyNotes, filepath.FromSlash(formatMomentDate(day, format))+".md")
+
+		content := ""
+		if existing, err := os.ReadFile(path); err == nil {
+			content = string(existing)
+		} else if !os.IsNotExist(err) {
+			log.Errorf("Error reading daily note %s: %v\n", path, err)
+			continue
+		}
+
+		// Lines are matched by verb and wikilink, so activities already in the note aren't added again
+		// on the next import and a changed rating replaces the line
+		lines := strings.Split(content, "\n")
+		var missing []string
+		changed := false
+		for _, line := range dailyActivities[date] {
+			key := dailyActivityKey(line)
+			found := false
+			for i, existing := range lines {
+				if existing != key && !strings.HasPrefix(existing, key+" ") {
+					continue
+				}
+				found = true
+				if existing != line {
+					lines[i] = line
+					changed = true
+				}
+				break
+			}
+			if !found {
+				missing = append(missing, line)
+			}
+		}
+		if len(missing) == 0 && !changed {
+			continue
+		}
+
+		content = strings.Join(lines, "\n")
+		if len(missing) > 0 {
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content += strings.Join(missing, "\n") + "\n"
+		}
+		if _, err := writeNoteFile(path, content); err != nil {
+			log.Errorf("Error writing daily note %s: %v\n", path, err)
+		}
+	}
+}
+
+// vaultDailyNoteFormat returns the format of the Daily notes core plugin from the vault the
+// directory is in, YYYY-MM-DD like Obsidian if it isn't set
+func vaultDailyNoteFormat(directory string) string {
+	dir, err := filepath.Abs(directory)
+	if err != nil {
+		return "YYYY-MM-DD"
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, ".obsidian", "daily-notes.json"))
+		if err == nil {
+			var settings struct {
+				Format string `json:"format"`
+			}
+			if json.Unmarshal(data, &settings) == nil && settings.Format != "" {
+				return settings.Format
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return "YYYY-MM-DD"
+}
+
+// formatMomentDate formats a date with a moment.js format like "YYYY/MM/YYYY-MM-DD dddd",
+// text in [brackets] is kept as is
+func formatMomentDate(t time.Time, format string) string {
+	var sb strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				sb.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+
+		matched := false
+		for _, token := range momentTokens {
+			if strings.HasPrefix(format[i:], token.token) {
+				sb.WriteString(token.format(t))
+				i += len(token.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sb.WriteByte(format[i])
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDailyNotesReplacesRating(t *testing.T) {
+	dir := testVault(t, nil)
+	path := filepath.Join(dir, "2024-05-01.md")
+	existing := "# Wednesday\n\n- Watched [[Heat (1995)]] ★★★\n- Read [[Dune]]\n"
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previousDir, previousFormat, previousActivities := importDailyNotes, importDailyNotesFormat, dailyActivities
+	importDailyNotes, importDailyNotesFormat, dailyActivities = dir, "YYYY-MM-DD", make(map[string][]string)
+	t.Cleanup(func() {
+		importDailyNotes, importDailyNotesFormat, dailyActivities = previousDir, previousFormat, previousActivities
+	})
+
+	addDailyActivity("2024-05-01", "Watched", "imdb/Heat (1995).md", "★★★")
+	addDailyActivity("2024-05-01", "Watched", "imdb/Heat (1995).md", ratingStars(8, 10))
+	addDailyActivity("2024-05-01", "Read", "goodreads/Dune.md", "")
+	addDailyActivity("2024-05-01", "Watched", "imdb/Casino (1995).md", "")
+	writeDailyNotes()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Wednesday\n\n- Watched [[Heat (1995)]] ★★★★\n- Read [[Dune]]\n- Watched [[Casino (1995)]]\n"
+	if string(got) != want {
+		t.Errorf("daily note = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// dateLayouts are the plain date formats of the exports and notes, Goodreads uses slashes
+var dateLayouts = []string{"2006-01-02", "2006/01/02", "2006-01-02 15:04"}
+
+// dateLocations caches the loaded Timezone, an invalid name is warned about once
+var dateLocations = map[string]*time.Location{}
+
+// dateLocation returns the time zone timestamps are converted to dates in: Timezone in the config
+// or the time zone of the computer
+func dateLocation() *time.Location {
+	name := viper.GetString("Timezone")
+	if name == "" {
+		return time.Local
+	}
+	if location, ok := dateLocations[name]; ok {
+		return location
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Warnf("Unknown Timezone %q, using the local time zone: %v\n", name, err)
+		location = time.Local
+	}
+	dateLocations[name] = location
+	return location
+}
+
+// localDate returns the ISO date of an instant in the Timezone, e.g. a rating made at 23:30 UTC
+// is on the next day in UTC+13
+func localDate(t time.Time) string {
+	return t.In(dateLocation()).Format("2006-01-02")
+}
+
+// isoDate converts a date of an export to an ISO date. Plain dates are already on the calendar of
+// the user and only reformatted, timestamps are converted to the date in the Timezone. Empty and
+// unknown values are returned as is.
+func isoDate(date string) string {
+	day, err := parseWatchDate(date)
+	if err != nil {
+		return date
+	}
+	return day.Format("2006-01-02")
+}
+
+// parseWatchDate parses the date formats used in the notes and exports. Timestamps are converted
+// to the date in the Timezone and returned as a plain date like the other formats.
+func parseWatchDate(date string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if day, err := time.Parse(layout, date); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		day, _ := time.Parse("2006-01-02", localDate(t))
+		return day, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", date)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	decryptIdentity string
+	decryptWrite    bool
+)
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [path...]",
+	Short: "Decrypt the encrypted private notes in notes",
+	Long: `Decrypt the age encrypted blocks, like Goodreads private notes written when AgeRecipient is
+set, in the given notes or directories (default MarkdownOutputDir) and print them.
+
+The identity is read from --identity or AgeIdentityFile, the file written by age-keygen.
+With --write the blocks are replaced with their plaintext in the notes.`,
+	ValidArgsFunction: completeFiles("md"),
+	Run: func(cmd *cobra.Command, args []string) {
+		decryptNotes(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+
+	decryptCmd.Flags().StringVarP(&decryptIdentity, "identity", "i", "", "age identity file (default AgeIdentityFile)")
+	decryptCmd.Flags().BoolVarP(&decryptWrite, "write", "w", false, "Replace the encrypted blocks with plaintext in the notes")
+}
+
+func decryptNotes(args []string) {
+	if decryptIdentity == "" {
+		decryptIdentity = viper.GetString("AgeIdentityFile")
+	}
+	if decryptIdentity == "" {
+		log.Error("No identity, set AgeIdentityFile in config or use --identity\n")
+		return
+	}
+
+	identities, err := readAgeIdentities(decryptIdentity)
+	if err != nil {
+		log.Errorf("Error reading identity %s: %v\n", decryptIdentity, err)
+		return
+	}
+
+	if len(args) == 0 {
+		args = []string{viper.GetString("MarkdownOutputDir")}
+	}
+
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		notes, err := findNotes(arg)
+		if err != nil {
+			log.Errorf("Error reading notes from %s: %v\n", arg, err)
+			return
+		}
+		paths = append(paths, notes...)
+	}
+
+	decrypted := 0
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Warnf("Error reading %s: %v\n", path, err)
+			continue
+		}
+
+		var failed bool
+		replaced := encryptedBlock.ReplaceAllStringFunc(string(content), func(block string) string {
+			plaintext, err := decryptText(block, identities)
+			if err != nil {
+				log.Warnf("Error decrypting block in %s: %v\n", path, err)
+				failed = true
+				return block
+			}
+			decrypted++
+
+			if !decryptWrite {
+				fmt.Printf("%s:\n%s\n\n", path, plaintext)
+			}
+			// Blocks inside a callout keep the callout prefix
+			if strings.HasPrefix(block, "> ") {
+				return "> " + strings.ReplaceAll(plaintext, "\n", "\n> ")
+			}
+			return plaintext
+		})
+
+		if decryptWrite && !failed && replaced != string(content) {
+			if err := os.WriteFile(path, []byte(replaced), 0644); err != nil {
+				log.Warnf("Error writing %s: %v\n", path, err)
+			}
+		}
+	}
+
+	summaryf("Decrypted %d blocks\n", decrypted)
+}
@@ -0,0 +1,335 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	seedOut    string
+	seedMovies int
+	seedBooks  int
+	seedRandom int64
+)
+
+// devtoolsCmd represents the devtools command
+var devtoolsCmd = &cobra.Command{
+	Use:    "devtools",
+	Short:  "Tools for developing hermes",
+	Hidden: true,
+}
+
+// devtoolsSeedCmd represents the devtools seed command
+var devtoolsSeedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Generate a fake vault to develop and benchmark against",
+	Long: `Generate a vault of fake IMDb movie and Goodreads book notes in the layout the importers
+write, to run the other commands against without touching your own vault.
+
+The notes vary like real vaults do: block and flow style lists, quoted values, missing years and
+ratings, values older hermes versions wrote, CRLF line endings, byte order marks, text written
+in Obsidian, duplicates and sync conflict copies. The same --seed generates the same vault.
+
+The output directory must not exist or be empty.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := seedVault(seedOut, seedMovies, seedBooks, seedRandom); err != nil {
+			log.Errorf("Error generating vault: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devtoolsCmd)
+	devtoolsCmd.AddCommand(devtoolsSeedCmd)
+
+	devtoolsSeedCmd.Flags().StringVarP(&seedOut, "out", "o", "testvault", "Directory to generate the vault in")
+	devtoolsSeedCmd.MarkFlagDirname("out")
+	devtoolsSeedCmd.Flags().IntVar(&seedMovies, "movies", 200, "Number of movie notes")
+	devtoolsSeedCmd.Flags().IntVar(&seedBooks, "books", 100, "Number of book notes")
+	devtoolsSeedCmd.Flags().Int64Var(&seedRandom, "seed", 1, "Random seed")
+}
+
+// Words the fake titles and names are made of
+var (
+	seedTitleWords = []string{"Night", "Return", "Last", "Silent", "River", "Empire", "Shadow", "Summer", "Glass",
+		"Winter", "Stranger", "Garden", "Iron", "Dream", "City", "Road", "Fire", "Ghost", "Kingdom", "Star",
+		"Storm", "Island", "Memory", "Crown", "Secret", "Wolf", "Ocean", "Mirror", "Paper", "Machine"}
+	seedFirstNames = []string{"Anna", "Mika", "John", "Sofia", "Ridley", "Ursula", "Kenji", "Maria", "Olli",
+		"Greta", "David", "Ayumi", "Tove", "Frank", "Leena"}
+	seedLastNames = []string{"Virtanen", "Scott", "Le Guin", "Kurosawa", "Lindqvist", "Herbert", "Jansson",
+		"Mann", "Nolan", "Korhonen", "Bergman", "Atwood", "Tanaka", "Öberg", "Müller"}
+	seedMovieGenres = []string{"Action", "Comedy", "Crime", "Drama", "Horror", "Sci-Fi", "Thriller", "Romance", "Animation"}
+	seedBookShelves = []string{"fantasy", "science-fiction", "classics", "history", "mystery", "non-fiction"}
+	seedCountries   = []string{"United States", "Finland", "Japan", "France", "Sweden", "United Kingdom"}
+)
+
+// seedNote is a generated note and how it's written
+type seedNote struct {
+	path   string
+	fields [][2]string
+	body   string
+}
+
+// seedVault writes the fake vault
+func seedVault(out string, movies, books int, seed int64) error {
+	if entries, err := os.ReadDir(out); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", out)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	var notes []seedNote
+	for i := 0; i < movies; i++ {
+		notes = append(notes, seedMovie(random, out, i))
+	}
+	for i := 0; i < books; i++ {
+		notes = append(notes, seedBook(random, out, i))
+	}
+
+	written, conflicts, duplicates := 0, 0, 0
+	for _, note := range notes {
+		if err := writeSeedNote(random, note); err != nil {
+			return err
+		}
+		written++
+
+		switch roll := random.Float64(); {
+		case roll < 0.03:
+			// A sync conflict copy with a different rating
+			conflict := note
+			conflict.path = seedConflictPath(random, note.path)
+			conflict.fields = withSeedField(note.fields, "my_rating", fmt.Sprint(random.Intn(10)+1))
+			if err := writeSeedNote(random, conflict); err != nil {
+				return err
+			}
+			conflicts++
+		case roll < 0.05:
+			// The same item imported twice under another name
+			duplicate := note
+			duplicate.path = strings.TrimSuffix(note.path, ".md") + " 2.md"
+			if err := writeSeedNote(random, duplicate); err != nil {
+				return err
+			}
+			duplicates++
+		}
+	}
+
+	summaryf("Generated %d notes, %d conflict copies and %d duplicates in %s\n", written, conflicts, duplicates, out)
+	return nil
+}
+
+// seedMovie generates a movie note like the IMDb importer writes
+func seedMovie(random *rand.Rand, out string, i int) seedNote {
+	title := seedTitle(random)
+	year := 1950 + random.Intn(75)
+	director := seedName(random)
+	id := fmt.Sprintf("tt%07d", 100000+i*7919%9000000)
+
+	fields := [][2]string{
+		{"title", seedQuote(random, title)},
+		{"imdb_id", id},
+		{"url", "https://www.imdb.com/title/" + id + "/"},
+		{"year", fmt.Sprint(year)},
+		{"imdb_rating", fmt.Sprintf("%.2f", 4+random.Float64()*5)},
+		{"my_rating", fmt.Sprint(random.Intn(10) + 1)},
+		{"date_rated", seedDate(random, year).Format("2006-01-02")},
+		{"runtime", fmt.Sprint(80 + random.Intn(100))},
+		{"genres", seedList(random, seedPick(random, seedMovieGenres, 1+random.Intn(3)))},
+		{"directors", seedList(random, []string{director})},
+		{"director_sort", sortName(director)},
+		{"country", seedList(random, seedPick(random, seedCountries, 1))},
+		{"tags", seedList(random, []string{"imdb/movie"})},
+	}
+	fields = seedVariations(random, fields)
+
+	path := filepath.Join(out, "imdb", sanitizeFilename(fmt.Sprintf("%s (%d)", title, year))+".md")
+	return seedNote{path: path, fields: fields, body: seedBody(random)}
+}
+
+// seedBook generates a book note like the Goodreads importer writes
+func seedBook(random *rand.Rand, out string, i int) seedNote {
+	title := seedTitle(random)
+	if random.Float64() < 0.2 {
+		title += fmt.Sprintf(" (The %s Saga, #%d)", seedTitleWords[random.Intn(len(seedTitleWords))], 1+random.Intn(5))
+	}
+	year := 1900 + random.Intn(125)
+	author := seedName(random)
+	shelf := "read"
+	if random.Float64() < 0.3 {
+		shelf = "to-read"
+	}
+
+	fields := [][2]string{
+		{"title", seedQuote(random, title)},
+		{"authors", seedList(random, []string{author})},
+		{"author_sort", sortName(author)},
+		{"goodreads_id", fmt.Sprintf("%q", fmt.Sprint(1000+i*104729%900000))},
+		{"year", fmt.Sprint(year)},
+		{"my_rating", "0"},
+		{"average_rating", fmt.Sprintf("%.2f", 3+random.Float64()*2)},
+		{"pages", fmt.Sprint(100 + random.Intn(700))},
+	}
+	if shelf == "read" {
+		fields = withSeedField(fields, "my_rating", fmt.Sprint(1+random.Intn(5)))
+		fields = append(fields, [2]string{"date_read", seedDate(random, year).Format("2006-01-02")})
+	}
+	fields = append(fields,
+		[2]string{"date_added", seedDate(random, year).Format("2006-01-02")},
+		[2]string{"tags", seedList(random, append([]string{"goodreads/" + shelf}, seedPick(random, seedBookShelves, random.Intn(3))...))},
+	)
+	fields = seedVariations(random, fields)
+
+	path := filepath.Join(out, "goodreads", sanitizeFilename(fmt.Sprintf("%s (%d)", title, year))+".md")
+	return seedNote{path: path, fields: fields, body: seedBody(random)}
+}
+
+// seedVariations drops fields and adds the values real vaults have
+func seedVariations(random *rand.Rand, fields [][2]string) [][2]string {
+	var varied [][2]string
+	for _, field := range fields {
+		switch {
+		case field[0] == "year" && random.Float64() < 0.08:
+			continue
+		case field[0] == "my_rating" && random.Float64() < 0.05:
+			continue
+		case field[0] == "my_rating" && random.Float64() < 0.02:
+			// Out of range
+			field[1] = "11"
+		}
+		varied = append(varied, field)
+	}
+
+	if random.Float64() < 0.05 {
+		// Written by older hermes versions
+		varied = append(varied, [2]string{"cover", `""`})
+		varied = withSeedField(varied, "tags", "\n  - rating/0\n  - year/0s")
+	}
+	if random.Float64() < 0.1 {
+		// Set by Obsidian plugins
+		varied = append(varied, [2]string{"modified", seedDate(random, 2020).Format(time.RFC3339)})
+	}
+	return varied
+}
+
+// writeSeedNote writes a note, some with CRLF line endings or a byte order mark
+func writeSeedNote(random *rand.Rand, note seedNote) error {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, field := range note.fields {
+		// Block lists start on the next line
+		separator := ": "
+		if strings.HasPrefix(field[1], "\n") {
+			separator = ":"
+		}
+		sb.WriteString(field[0] + separator + field[1] + "\n")
+	}
+	sb.WriteString("---\n" + note.body)
+
+	content := sb.String()
+	switch roll := random.Float64(); {
+	case roll < 0.05:
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	case roll < 0.08:
+		content = "\ufeff" + content
+	}
+
+	if err := os.MkdirAll(filepath.Dir(note.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(note.path, []byte(content), 0644)
+}
+
+// seedList renders a list in block or flow style
+func seedList(random *rand.Rand, values []string) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	if random.Float64() < 0.3 {
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+	return "\n  - " + strings.Join(values, "\n  - ")
+}
+
+// withSeedField replaces the value of a field, returning a copy
+func withSeedField(fields [][2]string, key, value string) [][2]string {
+	updated := make([][2]string, len(fields))
+	copy(updated, fields)
+	for i := range updated {
+		if updated[i][0] == key {
+			updated[i][1] = value
+		}
+	}
+	return updated
+}
+
+// seedConflictPath returns a Dropbox or Syncthing conflict copy name of the path
+func seedConflictPath(random *rand.Rand, path string) string {
+	base := strings.TrimSuffix(path, ".md")
+	if random.Float64() < 0.5 {
+		return base + " (conflicted copy 2024-03-01).md"
+	}
+	return base + ".sync-conflict-20240301-120000-ABCDEFG.md"
+}
+
+// seedTitle returns a title of one to three words, sometimes with a leading article or a colon
+func seedTitle(random *rand.Rand) string {
+	words := seedPick(random, seedTitleWords, 1+random.Intn(3))
+	title := strings.Join(words, " ")
+	switch roll := random.Float64(); {
+	case roll < 0.25:
+		title = "The " + title
+	case roll < 0.3:
+		title += ": " + seedTitleWords[random.Intn(len(seedTitleWords))]
+	}
+	return title
+}
+
+// seedName returns a person's name
+func seedName(random *rand.Rand) string {
+	return seedFirstNames[random.Intn(len(seedFirstNames))] + " " + seedLastNames[random.Intn(len(seedLastNames))]
+}
+
+// seedPick returns n different values of the list
+func seedPick(random *rand.Rand, values []string, n int) []string {
+	picked := make([]string, 0, n)
+	for _, i := range random.Perm(len(values))[:min(n, len(values))] {
+		picked = append(picked, values[i])
+	}
+	return picked
+}
+
+// seedQuote quotes a value like YAML encoders and people do, titles with a colon must be quoted
+func seedQuote(random *rand.Rand, value string) string {
+	if strings.Contains(value, ":") || strings.Contains(value, "#") || random.Float64() < 0.2 {
+		return fmt.Sprintf("%q", value)
+	}
+	return value
+}
+
+// seedDate returns a date after the year
+func seedDate(random *rand.Rand, year int) time.Time {
+	start := time.Date(max(year, 2005), 1, 1, 0, 0, 0, 0, time.UTC)
+	days := int(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Sub(start).Hours() / 24)
+	return start.AddDate(0, 0, random.Intn(max(days, 1)))
+}
+
+// seedBody returns an empty body or text written in Obsidian
+func seedBody(random *rand.Rand) string {
+	switch roll := random.Float64(); {
+	case roll < 0.6:
+		return ""
+	case roll < 0.9:
+		return "\nRecommended by a friend, reminds me of " + seedTitle(random) + ".\n"
+	default:
+		return "\n## Notes\n\n- [[" + seedTitle(random) + "]]\n- #favourite\n"
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// digestHighlights is how many of the best rated notes the digest lists
+const digestHighlights = 5
+
+var (
+	digestDays  int
+	digestPrint bool
+)
+
+// digestCmd represents the digest command
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Send an email digest of the recent imports",
+	Long: `Send an email listing the notes added or updated in the last days, the best rated of them
+and the upcoming episodes of the TV shows in the vault. Run it weekly from cron or a scheduled task.
+
+Upcoming episodes are fetched from TMDB for notes with a tmdb_tv_id, they are skipped if
+TMDBAccessToken isn't set.
+
+The email is sent with Digest.Provider smtp or resend:
+
+  Digest:
+    Provider: smtp
+    From: hermes@example.com
+    To: me@example.com
+    SMTPHost: smtp.example.com
+    SMTPPort: 587
+    SMTPUsername: hermes@example.com
+    SMTPPassword: keyring://smtp
+
+With resend, Digest.ResendAPIKey is used instead of the SMTP settings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sendDigest()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(digestCmd)
+
+	digestCmd.Flags().IntVar(&digestDays, "days", 7, "Number of days the digest covers")
+	digestCmd.Flags().BoolVar(&digestPrint, "print", false, "Print the digest instead of sending it")
+}
+
+// digestNote is a recently added or updated note
+type digestNote struct {
+	Path     string
+	Title    string
+	Rating   float64
+	Modified time.Time
+}
+
+// digestEpisode is an upcoming episode of a TV show in the vault
+type digestEpisode struct {
+	Show    string
+	Episode tmdbEpisode
+}
+
+func sendDigest() {
+	now := time.Now()
+	since := now.AddDate(0, 0, -digestDays)
+
+	notes, shows, err := recentNotes(viper.GetString("MarkdownOutputDir"), since)
+	if err != nil {
+		log.Errorf("Error reading notes: %v\n", err)
+		return
+	}
+	episodes := upcomingEpisodes(shows, now, now.AddDate(0, 0, digestDays))
+
+	subject := fmt.Sprintf("hermes digest: %d new or updated notes", len(notes))
+	body := renderDigest(notes, episodes, since)
+
+	if digestPrint {
+		fmt.Printf("Subject: %s\n\n%s", subject, body)
+		return
+	}
+
+	if err := sendEmail(subject, body); err != nil {
+		log.Errorf("Error sending digest: %v\n", err)
+		return
+	}
+	summaryf("Sent digest with %d notes and %d upcoming episodes\n", len(notes), len(episodes))
+}
+
+// recentNotes returns the notes modified after since, and the TMDB ids and titles of all TV show notes
+func recentNotes(directory string, since time.Time) ([]digestNote, map[string]string, error) {
+	paths, err := findNotes(directory)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var notes []digestNote
+	shows := make(map[string]string)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		if id := noteID(note.Frontmatter, tmdbIdField("tv")); id != "" {
+			shows[id] = note.Title()
+		}
+		if info.ModTime().Before(since) {
+			continue
+		}
+		notes = append(notes, digestNote{
+			Path:     path,
+			Title:    note.Title(),
+			Rating:   note.Frontmatter.GetFloat("my_rating"),
+			Modified: info.ModTime(),
+		})
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].Modified.After(notes[j].Modified)
+	})
+
+	return notes, shows, nil
+}
+
+// upcomingEpisodes returns the next episodes of the shows airing between from and to, by air date
+func upcomingEpisodes(shows map[string]string, from, to time.Time) []digestEpisode {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" || len(shows) == 0 {
+		return nil
+	}
+
+	var episodes []digestEpisode
+	for id, show := range shows {
+		episode, err := fetchTMDBNextEpisode(token, id)
+		if err != nil {
+			log.WithField("Show", show).Warnf("Error fetching next episode: %v\n", err)
+			continue
+		}
+		// Air dates are plain dates, compared as strings
+		if episode == nil || episode.AirDate < from.Format("2006-01-02") || episode.AirDate > to.Format("2006-01-02") {
+			continue
+		}
+		episodes = append(episodes, digestEpisode{Show: show, Episode: *episode})
+	}
+
+	sort.Slice(episodes, func(i, j int) bool {
+		if episodes[i].Episode.AirDate != episodes[j].Episode.AirDate {
+			return episodes[i].Episode.AirDate < episodes[j].Episode.AirDate
+		}
+		return episodes[i].Show < episodes[j].Show
+	})
+
+	return episodes
+}
+
+// renderDigest renders the plain text body of the digest
+func renderDigest(notes []digestNote, episodes []digestEpisode, since time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("New and updated since %s\n\n", since.Format("2006-01-02")))
+	if len(notes) == 0 {
+		sb.WriteString("Nothing new.\n")
+	}
+	for _, note := range notes {
+		sb.WriteString("- " + note.Title + "\n")
+	}
+
+	var rated []digestNote
+	for _, note := range notes {
+		if note.Rating > 0 {
+			rated = append(rated, note)
+		}
+	}
+	sort.SliceStable(rated, func(i, j int) bool {
+		return rated[i].Rating > rated[j].Rating
+	})
+	if len(rated) > digestHighlights {
+		rated = rated[:digestHighlights]
+	}
+	if len(rated) > 0 {
+		sb.WriteString("\nRating highlights\n\n")
+		for _, note := range rated {
+			sb.WriteString(fmt.Sprintf("- %s (%g)\n", note.Title, note.Rating))
+		}
+	}
+
+	if len(episodes) > 0 {
+		sb.WriteString("\nUpcoming episodes\n\n")
+		for _, upcoming := range episodes {
+			episode := upcoming.Episode
+			sb.WriteString(fmt.Sprintf("- %s: %s S%02dE%02d", episode.AirDate, upcoming.Show, episode.SeasonNumber, episode.EpisodeNumber))
+			if episode.Name != "" {
+				sb.WriteString(" " + episode.Name)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// sendEmail sends a plain text email with the configured Digest.Provider
+func sendEmail(subject, body string) error {
+	from := viper.GetString("Digest.From")
+	to := viper.GetStringSlice("Digest.To")
+	if from == "" || len(to) == 0 {
+		return fmt.Errorf("Digest.From and Digest.To must be set in the config")
+	}
+
+	switch provider := viper.GetString("Digest.Provider"); provider {
+	case "smtp":
+		host := viper.GetString("Digest.SMTPHost")
+		if host == "" {
+			return fmt.Errorf("Digest.SMTPHost must be set in the config")
+		}
+		var auth smtp.Auth
+		if username := viper.GetString("Digest.SMTPUsername"); username != "" {
+			auth = smtp.PlainAuth("", username, viper.GetString("Digest.SMTPPassword"), host)
+		}
+
+		message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+			from, strings.Join(to, ", "), subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(body, "\n", "\r\n"))
+		address := fmt.Sprintf("%s:%d", host, viper.GetInt("Digest.SMTPPort"))
+		return smtp.SendMail(address, auth, from, to, []byte(message))
+	case "resend":
+		req, err := newJSONRequest("https://api.resend.com/emails", map[string]interface{}{
+			"from":    from,
+			"to":      to,
+			"subject": subject,
+			"text":    body,
+		})
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+viper.GetString("Digest.ResendAPIKey"))
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown Digest.Provider %q, expected smtp or resend", provider)
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// importDryRun reports what an import would change instead of writing notes, JSON files, the cache
+// or the retry queue
+var importDryRun bool
+
+// dryRun counts what the dry run would have done
+var dryRun struct {
+	created  int
+	updated  int
+	moved    int
+	requests int
+	// blocked are the requests that would change data in a remote service, like pushing ratings
+	blocked int
+	// moves maps the new paths of notes that would be moved to their current paths
+	moves map[string]string
+}
+
+// dryRunReadOnlyPosts are the POST endpoints that only read, query APIs and token requests, by URL prefix
+var dryRunReadOnlyPosts = []string{
+	"https://api.igdb.com/v4/",
+	"https://id.twitch.tv/oauth2/token",
+	"https://graphql.anilist.co",
+}
+
+// dryRunTransport counts the API requests of a dry run and blocks the ones that aren't reads
+type dryRunTransport struct {
+	next http.RoundTripper
+}
+
+func (t dryRunTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !dryRunReadOnly(r) {
+		dryRun.blocked++
+		log.WithField("URL", r.URL.Redacted()).Infof("Would send %s request\n", r.Method)
+		return nil, fmt.Errorf("dry run: %s %s not sent", r.Method, r.URL.Host)
+	}
+	dryRun.requests++
+	return t.next.RoundTrip(r)
+}
+
+// dryRunReadOnly returns true for requests that don't change data in a remote service
+func dryRunReadOnly(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	if r.Method != http.MethodPost {
+		return false
+	}
+	url := r.URL.Scheme + "://" + r.URL.Host + r.URL.Path
+	for _, prefix := range dryRunReadOnlyPosts {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// startDryRun routes the API requests through the counting transport
+func startDryRun() {
+	if !importDryRun {
+		return
+	}
+	http.DefaultTransport = dryRunTransport{next: http.DefaultTransport}
+	dryRun.moves = make(map[string]string)
+	log.Info("Dry run, nothing is written")
+}
+
+// dryRunNoteWrite records a note that would be created or updated
+func dryRunNoteWrite(path string, exists bool) {
+	if exists {
+		dryRun.updated++
+		log.WithField("Path", path).Info("Would update note")
+		return
+	}
+	dryRun.created++
+	log.WithField("Path", path).Info("Would create note")
+}
+
+// dryRunExistingPath returns the current path of a note the dry run would have moved to path
+func dryRunExistingPath(path string) string {
+	if oldPath, ok := dryRun.moves[path]; ok {
+		return oldPath
+	}
+	return path
+}
+
+// writeOutputFile writes an importer's JSON output file, skipped in a dry run
+func writeOutputFile(name string, data []byte) error {
+	if importDryRun {
+		log.WithField("Path", name).Debug("Dry run, not writing output file")
+		return nil
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+// createOutputFile creates an importer's JSON output file for writing, writes are discarded in a dry run
+func createOutputFile(name string) (io.WriteCloser, error) {
+	if importDryRun {
+		log.WithField("Path", name).Debug("Dry run, not writing output file")
+		return nopWriteCloser{io.Discard}, nil
+	}
+	return os.Create(name)
+}
+
+// nopWriteCloser is a writer with a Close that does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// dryRunSummary reports the totals of a dry run
+func dryRunSummary() {
+	if !importDryRun {
+		return
+	}
+	var parts []string
+	parts = append(parts, fmt.Sprintf("%d notes would be created, %d updated, %d moved", dryRun.created, dryRun.updated, dryRun.moved))
+	// Responses read from the cache aren't requests, a run with an empty cache makes more
+	parts = append(parts, fmt.Sprintf("%d API requests sent (cached responses not counted)", dryRun.requests))
+	if dryRun.blocked > 0 {
+		parts = append(parts, fmt.Sprintf("%d changing requests not sent", dryRun.blocked))
+	}
+	summaryf("Dry run: %s\n", strings.Join(parts, ", "))
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDryRunReadOnly(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		want   bool
+	}{
+		{http.MethodGet, "https://api.themoviedb.org/3/movie/949", true},
+		{http.MethodHead, "https://example.com/cover.jpg", true},
+		{http.MethodPost, "https://api.igdb.com/v4/games", true},
+		{http.MethodPost, "https://id.twitch.tv/oauth2/token?grant_type=client_credentials", true},
+		{http.MethodPost, "https://graphql.anilist.co", true},
+		{http.MethodPost, "https://api.themoviedb.org/3/movie/949/rating", false},
+		{http.MethodPost, "https://api.igdb.com.example.com/v4/games", false},
+		{http.MethodDelete, "https://api.igdb.com/v4/games", false},
+	}
+
+	for _, tt := range tests {
+		r, err := http.NewRequest(tt.method, tt.url, strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dryRunReadOnly(r); got != tt.want {
+			t.Errorf("dryRunReadOnly(%s %s) = %v, want %v", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
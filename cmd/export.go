@@ -0,0 +1,173 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	exportSources []string
+	exportOut     string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the notes to other formats",
+}
+
+// exportICSCmd represents the export ics command
+var exportICSCmd = &cobra.Command{
+	Use:   "ics",
+	Short: "Export watch and read dates as calendar events",
+	Long: `Convert the watch and read dates of the notes of the given sources to all-day calendar events:
+
+  date_rated     watched (IMDb, TMDB)
+  screenings     watched at the venue of the screening
+  date_read      finished reading (Goodreads, StoryGraph)
+  date_finished  finished (MyAnimeList)
+
+The events are written to an iCalendar file that can be imported or subscribed to in a calendar app.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exportICS(exportSources, exportOut)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportICSCmd)
+
+	exportICSCmd.Flags().StringSliceVarP(&exportSources, "source", "s", []string{"imdb", "goodreads"}, "Sources to export, e.g. imdb,goodreads")
+	exportICSCmd.Flags().StringVarP(&exportOut, "out", "o", "history.ics", "iCalendar file to write")
+	exportICSCmd.RegisterFlagCompletionFunc("source", completeSources)
+	exportICSCmd.MarkFlagFilename("out", "ics")
+}
+
+func exportICS(sources []string, out string) {
+	templates := viper.GetStringMapString("PathTemplates")
+
+	var events []icsEvent
+	for _, source := range sources {
+		// Without a template the source has no notes and its root would be the whole vault
+		if templates[source] == "" && defaultPathTemplates[source] == "" {
+			log.Errorf("Unknown source %q, no notes to export\n", source)
+			continue
+		}
+
+		sourceEvents, err := historyEvents(source)
+		if err != nil {
+			log.WithField("Source", source).Errorf("Error reading notes: %v\n", err)
+			continue
+		}
+		events = append(events, sourceEvents...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+
+	if err := writeICS(out, events); err != nil {
+		log.Errorf("Error writing %s: %v\n", out, err)
+		return
+	}
+
+	summaryf("Exported %d events to %s\n", len(events), out)
+}
+
+// historyEvents returns the watch and read events of the notes of a source
+func historyEvents(source string) ([]icsEvent, error) {
+	directory := sourceRootDir(source)
+	paths, err := findNotes(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []icsEvent
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		title := note.Title()
+		item := eventItemKey(note)
+		uid := func(kind, date string) string {
+			return fmt.Sprintf("%s-%s-%s-%s@hermes", source, item, kind, strings.ReplaceAll(date, "/", "-"))
+		}
+		addEvent := func(kind, date, summary, description string) {
+			day, err := parseWatchDate(date)
+			if err != nil {
+				return
+			}
+			events = append(events, icsEvent{UID: uid(kind, date), Date: day, Summary: summary, Description: description})
+		}
+
+		addEvent("watched", note.Frontmatter.GetString("date_rated"), "Watched "+title, ratingDescription(note))
+		addEvent("read", note.Frontmatter.GetString("date_read"), "Finished reading "+title, ratingDescription(note))
+		addEvent("finished", note.Frontmatter.GetString("date_finished"), "Finished "+title, ratingDescription(note))
+
+		var screenings []Screening
+		if note.Frontmatter.Has("screenings") && note.Frontmatter.Decode("screenings", &screenings) == nil {
+			for _, screening := range screenings {
+				summary := "Watched " + title
+				if screening.Venue != "" {
+					summary += " at " + screening.Venue
+				}
+				addEvent("screening", screening.Date, summary, screening.Format)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// eventItemKey identifies the item of a note in the event UIDs by its id, so items with the same
+// title don't share UIDs. Notes without an id use the ISBN, or the title and year.
+func eventItemKey(note *Note) string {
+	for _, id := range mediaIDFields {
+		if value := noteID(note.Frontmatter, id.field); value != "" {
+			return id.key + "-" + slugify(value)
+		}
+	}
+	if ids := note.Frontmatter.Get("ids"); ids != nil && ids.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(ids.Content); i += 2 {
+			if value := ids.Content[i+1].Value; value != "" {
+				return slugify(ids.Content[i].Value) + "-" + slugify(value)
+			}
+		}
+	}
+	for _, field := range []string{"isbn13", "isbn"} {
+		if isbn := note.Frontmatter.GetString(field); isbn != "" {
+			return "isbn-" + slugify(isbn)
+		}
+	}
+
+	key := slugify(note.Title())
+	if year := note.Frontmatter.GetInt("year"); year > 0 {
+		key += "-" + strconv.Itoa(year)
+	}
+	return key
+}
+
+// ratingDescription describes the rating of a note, empty if it's not rated
+func ratingDescription(note *Note) string {
+	rating := note.Frontmatter.GetFloat("my_rating")
+	if rating <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("My rating: %g", rating)
+}
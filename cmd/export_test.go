@@ -0,0 +1,33 @@
+package cmd
+
+import "testing"
+
+func TestHistoryEventUIDsOfSameTitle(t *testing.T) {
+	testVault(t, map[string]string{
+		"imdb/Heat (1986).md":              "---\ntitle: Heat\nimdb_id: tt0091183\nyear: 1986\ndate_rated: 2024-05-01\n---\n",
+		"imdb/Heat (1995).md":              "---\ntitle: Heat\nids:\n  imdb: tt0113277\nyear: 1995\ndate_rated: 2024-05-01\n---\n",
+		"goodreads/Dune (1965).md":         "---\ntitle: Dune\nisbn: \"0441013597\"\ndate_read: 2024-05-01\n---\n",
+		"goodreads/Dune (2007).md":         "---\ntitle: Dune\nisbn13: \"9780340960196\"\ndate_read: 2024-05-01\n---\n",
+		"goodreads/Dune Messiah (1969).md": "---\ntitle: Dune Messiah\nyear: 1969\ndate_read: 2024-05-01\n---\n",
+	})
+
+	uids := make(map[string]bool)
+	for _, source := range []string{"imdb", "goodreads"} {
+		events, err := historyEvents(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			if uids[event.UID] {
+				t.Errorf("UID %s is used twice", event.UID)
+			}
+			uids[event.UID] = true
+		}
+	}
+	if len(uids) != 5 {
+		t.Errorf("UIDs = %v, want one for each of the 5 notes", uids)
+	}
+	if !uids["imdb-imdb-tt0113277-watched-2024-05-01@hermes"] {
+		t.Errorf("UIDs = %v, want the Heat (1995) UID from its ids mapping", uids)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ExternalRecord is a record written by an external importer, one JSON object per line
+type ExternalRecord struct {
+	// ID identifies the item in the external source, notes are matched and moved by it
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Type like movie, book or game, added as a <source>/<type> tag
+	Type   string   `json:"type,omitempty"`
+	Year   int      `json:"year,omitempty"`
+	Rating float64  `json:"rating,omitempty"`
+	URL    string   `json:"url,omitempty"`
+	Cover  string   `json:"cover,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Fields are copied to the frontmatter as is
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Body is markdown written below the frontmatter
+	Body string `json:"body,omitempty"`
+}
+
+var (
+	externalCmdLine string
+	externalSource  string
+)
+
+// externalCmd represents the external command
+var externalCmd = &cobra.Command{
+	Use:   "external [file]",
+	Short: "Import records written by an external importer",
+	Long: `Run an external importer and write its records as notes, so new sources can be added
+without changing hermes. The importer is any executable that writes one JSON object per line
+to stdout, diagnostics go to stderr and a non-zero exit aborts the import:
+
+  {"id": "42", "title": "Outer Wilds", "type": "game", "year": 2019, "rating": 9,
+   "url": "https://...", "cover": "https://...", "tags": ["backlog/done"],
+   "fields": {"platform": "PC"}, "body": "Markdown text"}
+
+id and title are required, everything else is optional. The id is stored as <source>_id and
+used to find the note again when the path template changes. Records can also be read from a
+file or stdin (-) instead of running a command.
+
+Notes use the <source> path template, by default <source>/{{title}} ({{year}}).md, with the
+{{title}}, {{year}}, {{decade}} and {{type}} placeholders. The source defaults to the name of
+the importer executable.
+
+  hermes import external --cmd "./itch-importer --user me"
+  ./itch-importer | hermes import external --source itch -`,
+	Run: func(cmd *cobra.Command, args []string) {
+		parse_external(inputFile(args, ""))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(externalCmd)
+
+	externalCmd.Flags().StringVar(&externalCmdLine, "cmd", "", "External importer to run, with its arguments")
+	externalCmd.Flags().StringVarP(&externalSource, "source", "s", "", "Source name used for paths, ids and tags (default the importer name)")
+}
+
+func parse_external(filename string) {
+	source := externalSource
+	if source == "" && externalCmdLine != "" {
+		if fields := strings.Fields(externalCmdLine); len(fields) > 0 {
+			source = strings.TrimSuffix(filepath.Base(fields[0]), filepath.Ext(fields[0]))
+		}
+	}
+	source = slugify(source)
+	if source == "" {
+		log.Error("Set the source name with --source")
+		return
+	}
+	if _, ok := defaultPathTemplates[source]; ok {
+		log.Errorf("%s is a built-in importer, use another --source\n", source)
+		return
+	}
+	defaultPathTemplates[source] = source + "/{{title}} ({{year}}).md"
+
+	var records []ExternalRecord
+	var err error
+	switch {
+	case externalCmdLine != "":
+		records, err = runExternalImporter(externalCmdLine)
+	case filename != "":
+		input, openErr := openInput(filename)
+		if openErr != nil {
+			log.Error(openErr)
+			return
+		}
+		records, err = readJSONLines[ExternalRecord](input)
+		input.Close()
+	default:
+		log.Error("Give the importer with --cmd or a file to read records from")
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading records: %v\n", err)
+		return
+	}
+
+	var valid []ExternalRecord
+	for i, record := range records {
+		if record.ID == "" || record.Title == "" {
+			log.Warnf("Skipping record %d without id or title\n", i+1)
+			continue
+		}
+		valid = append(valid, record)
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(valid); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d %s records\n", len(valid), source)
+		return
+	}
+
+	if err := writeExternalRecordsToJson(source, valid); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	if err := writeExternalRecordsToMarkdown(source, valid); err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d %s records, skipped %d\n", len(valid), source, len(records)-len(valid))
+}
+
+// runExternalImporter runs the importer and reads the records from its output
+func runExternalImporter(cmdLine string) ([]ExternalRecord, error) {
+	fields := strings.Fields(cmdLine)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty --cmd")
+	}
+	command := exec.Command(fields[0], fields[1:]...)
+	command.Stderr = os.Stderr
+
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	records, readErr := readJSONLines[ExternalRecord](stdout)
+	if readErr != nil {
+		// The rest of the output can't be read, stop the importer instead of waiting for it
+		command.Process.Kill()
+	}
+	if err := command.Wait(); err != nil && readErr == nil {
+		return nil, fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return records, readErr
+}
+
+// externalIdField returns the frontmatter field of the external ids, like itch_importer_id
+func externalIdField(source string) string {
+	return strings.ReplaceAll(source, "-", "_") + "_id"
+}
+
+func writeExternalRecordsToJson(source string, records []ExternalRecord) error {
+	jsonData, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(source+".json", jsonData)
+}
+
+// writeExternalRecordToMarkdown writes an external record to a markdown file
+func writeExternalRecordToMarkdown(source string, record ExternalRecord, relocator *noteRelocator) (string, error) {
+	filePath, err := notePath(source, map[string]string{
+		"title":  record.Title,
+		"year":   yearString(record.Year),
+		"decade": decade(record.Year),
+		"type":   record.Type,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(record.ID, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{}
+	if record.Type != "" {
+		tags = append(tags, source+"/"+slugify(record.Type))
+	}
+	tags = append(tags, record.Tags...)
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", record.Title)
+	if aliases := noteAliases(filePath, record.Title); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set(externalIdField(source), record.ID)
+	if record.URL != "" {
+		frontmatter.Set("url", record.URL)
+	}
+	if record.Year > 0 {
+		frontmatter.Set("year", record.Year)
+	}
+	if record.Rating > 0 {
+		frontmatter.Set("my_rating", record.Rating)
+	}
+	if record.Cover != "" {
+		frontmatter.Set("cover", record.Cover)
+	}
+
+	// Sorted so the fields are added in a stable order
+	keys := make([]string, 0, len(record.Fields))
+	for key := range record.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := frontmatter.Set(key, record.Fields[key]); err != nil {
+			return "", err
+		}
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if record.Cover != "" {
+		body += fmt.Sprintf("![](%s)\n\n", record.Cover)
+	}
+	if record.Body != "" {
+		body += strings.TrimRight(record.Body, "\n") + "\n"
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(record.ID, note)
+	return filePath, note.Write()
+}
+
+// writeExternalRecordsToMarkdown writes the records to markdown files
+func writeExternalRecordsToMarkdown(source string, records []ExternalRecord) error {
+	relocator := newNoteRelocator(externalIdField(source))
+	var entries []indexEntry
+	for _, record := range records {
+		path, err := writeExternalRecordToMarkdown(source, record, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: record.Title, Year: record.Year, Rating: record.Rating})
+	}
+	return writeIndexNote(source, entries)
+}
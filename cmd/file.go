@@ -1,6 +1,9 @@
 package cmd
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 // sanitizeFilename replaces invalid characters in the filename with underscores
 func sanitizeFilename(filename string) string {
@@ -10,3 +13,19 @@ func sanitizeFilename(filename string) string {
 
 	return strings.ReplaceAll(filename, "[^a-zA-Z0-9\\s:]+", "_")
 }
+
+// slugify converts a name into a lowercase tag-safe form, "On Hold" -> "on-hold"
+func slugify(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return r
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			return '-'
+		default:
+			return -1
+		}
+	}, name)
+}
@@ -0,0 +1,220 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var fixLinksDir string
+
+var (
+	// fixLinkFields are the frontmatter fields holding attachment paths
+	fixLinkFields = []string{"cover", "image", "banner", "poster", "attachments"}
+	// markdownLinkRegex matches the target of markdown links and images, <> allows spaces in the path
+	markdownLinkRegex = regexp.MustCompile(`\]\((<[^>]+>|[^)\s]+)\)`)
+)
+
+// fixLinksCmd represents the fix-links command
+var fixLinksCmd = &cobra.Command{
+	Use:   "fix-links",
+	Short: "Fix attachment paths broken by moving notes or attachments",
+	Long: `Find relative attachment paths that no longer resolve after reorganizing the vault and
+point them to where the file is now, found by its file name.
+
+The cover, image, banner, poster and attachments frontmatter fields and markdown links and
+images in the note body are checked. Paths resolve against the note's folder or the vault
+root, paths starting with ./ or ../ are rewritten relative to the note and others relative to
+the vault root. URLs and wikilinks are left alone, Obsidian finds wikilinks by name.
+
+Files with the same name in several folders are reported instead of guessed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fixLinks()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixLinksCmd)
+
+	fixLinksCmd.Flags().StringVarP(&fixLinksDir, "dir", "d", "", "Vault directory (default MarkdownOutputDir)")
+	fixLinksCmd.MarkFlagDirname("dir")
+}
+
+// linkFixer resolves broken attachment paths in a vault
+type linkFixer struct {
+	vault string
+	// files are the paths of the attachments by file name
+	files   map[string][]string
+	fixed   int
+	missing int
+}
+
+func fixLinks() {
+	if fixLinksDir == "" {
+		fixLinksDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	fixer, err := newLinkFixer(fixLinksDir)
+	if err != nil {
+		log.Errorf("Error reading %s: %v\n", fixLinksDir, err)
+		return
+	}
+
+	paths, err := findNotes(fixLinksDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", fixLinksDir, err)
+		return
+	}
+
+	updated := 0
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+
+		changed := fixer.fixNote(note)
+		if !changed {
+			continue
+		}
+		if err := note.Write(); err != nil {
+			log.Errorf("Error writing %s: %v\n", path, err)
+			continue
+		}
+		updated++
+	}
+
+	summaryf("Fixed %d links in %d notes, %d broken links not found\n", fixer.fixed, updated, fixer.missing)
+}
+
+// newLinkFixer indexes the files of the vault that aren't notes
+func newLinkFixer(vault string) (*linkFixer, error) {
+	fixer := &linkFixer{vault: vault, files: make(map[string][]string)}
+
+	err := filepath.WalkDir(vault, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != vault && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && !strings.EqualFold(filepath.Ext(path), ".md") {
+			name := strings.ToLower(d.Name())
+			fixer.files[name] = append(fixer.files[name], path)
+		}
+		return nil
+	})
+
+	return fixer, err
+}
+
+// fixNote rewrites the broken attachment paths of a note, returns true if any were fixed
+func (l *linkFixer) fixNote(note *Note) bool {
+	changed := false
+
+	for _, field := range fixLinkFields {
+		value := note.Frontmatter.Get(field)
+		if value == nil {
+			continue
+		}
+		links := note.Frontmatter.GetStrings(field)
+		fieldChanged := false
+		for i, link := range links {
+			if fixed, ok := l.resolve(note.Path, link); ok {
+				links[i] = fixed
+				fieldChanged = true
+			}
+		}
+		if !fieldChanged {
+			continue
+		}
+		if value.Kind == yaml.ScalarNode {
+			note.Frontmatter.Set(field, links[0])
+		} else {
+			note.Frontmatter.Set(field, links)
+		}
+		changed = true
+	}
+
+	body := markdownLinkRegex.ReplaceAllStringFunc(note.Body, func(match string) string {
+		target := match[2 : len(match)-1]
+		bracketed := strings.HasPrefix(target, "<")
+		link := strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+		if unescaped, err := url.PathUnescape(link); err == nil {
+			link = unescaped
+		}
+
+		fixed, ok := l.resolve(note.Path, link)
+		if !ok {
+			return match
+		}
+		if bracketed {
+			return "](<" + fixed + ">)"
+		}
+		return "](" + strings.ReplaceAll(fixed, " ", "%20") + ")"
+	})
+	if body != note.Body {
+		note.Body = body
+		changed = true
+	}
+
+	return changed
+}
+
+// resolve returns the new path of a broken relative link, false if the link works or can't be fixed
+func (l *linkFixer) resolve(notePath, link string) (string, bool) {
+	if link == "" || strings.Contains(link, "://") || strings.HasPrefix(link, "[[") ||
+		strings.HasPrefix(link, "#") || strings.HasPrefix(link, "mailto:") || filepath.IsAbs(link) {
+		return "", false
+	}
+	// Links to other notes are kept up to date by Obsidian
+	if strings.EqualFold(filepath.Ext(link), ".md") || filepath.Ext(link) == "" {
+		return "", false
+	}
+
+	noteDir := filepath.Dir(notePath)
+	for _, base := range []string{noteDir, l.vault} {
+		if _, err := os.Stat(filepath.Join(base, filepath.FromSlash(link))); err == nil {
+			return "", false
+		}
+	}
+
+	candidates := l.files[strings.ToLower(filepath.Base(filepath.FromSlash(link)))]
+	if len(candidates) != 1 {
+		if len(candidates) == 0 {
+			log.WithField("Note", notePath).Warnf("Attachment %s not found\n", link)
+		} else {
+			log.WithField("Note", notePath).Warnf("Attachment %s is ambiguous, found %d files with the name\n", link, len(candidates))
+		}
+		l.missing++
+		return "", false
+	}
+
+	base := l.vault
+	if strings.HasPrefix(link, "./") || strings.HasPrefix(link, "../") {
+		base = noteDir
+	}
+	fixed, err := filepath.Rel(base, candidates[0])
+	if err != nil {
+		return "", false
+	}
+	fixed = filepath.ToSlash(fixed)
+	if base == noteDir && !strings.HasPrefix(fixed, "../") {
+		fixed = "./" + fixed
+	}
+
+	l.fixed++
+	return fixed, true
+}
@@ -0,0 +1,200 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var franchisesDir string
+
+// tmdbCollection is the TMDB collection (franchise) a movie belongs to
+type tmdbCollection struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// franchisesCmd represents the franchises command
+var franchisesCmd = &cobra.Command{
+	Use:   "franchises",
+	Short: "Write franchise watch progress to movie notes",
+	Long: `Look up the TMDB collection of each movie note and write the collection name and how many of
+its released films you have watched to the note frontmatter:
+
+  franchise: The Matrix Collection
+  franchise_progress: 3/4
+
+Notes are matched to TMDB by tmdb_movie_id or imdb_id, TMDB watchlist notes without a rating
+don't count as watched. Run it again after importing new films to update the progress, the films
+of a collection are cached for a week so new sequels show up. Importers keep the fields.
+
+Requires TMDBAccessToken in the config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateFranchises()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(franchisesCmd)
+
+	franchisesCmd.Flags().StringVarP(&franchisesDir, "dir", "d", "", "Directory with movie notes (default MarkdownOutputDir)")
+	franchisesCmd.MarkFlagDirname("dir")
+}
+
+func updateFranchises() {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" {
+		log.Error("TMDBAccessToken must be set in the config")
+		return
+	}
+	if franchisesDir == "" {
+		franchisesDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	paths, err := findNotes(franchisesDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", franchisesDir, err)
+		return
+	}
+
+	// Notes of each collection, and the movies watched in it counted once even with several notes
+	notes := make(map[int][]*Note)
+	watched := make(map[int]map[int]bool)
+	names := make(map[int]string)
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		mediaType, id, err := noteTMDBID(token, note)
+		if err != nil {
+			log.WithField("Title", note.Title()).Warnf("Error finding TMDB id: %v\n", err)
+			continue
+		}
+		if mediaType != "movie" || id == 0 {
+			continue
+		}
+
+		collection, err := fetchTMDBMovieCollection(token, id)
+		if err != nil {
+			log.WithField("Title", note.Title()).Warnf("Error fetching TMDB collection: %v\n", err)
+			continue
+		}
+		if collection.ID == 0 {
+			continue
+		}
+
+		names[collection.ID] = collection.Name
+		notes[collection.ID] = append(notes[collection.ID], note)
+		if watched[collection.ID] == nil {
+			watched[collection.ID] = make(map[int]bool)
+		}
+		if !hasTag(note.Frontmatter, "tmdb/watchlist") || note.Frontmatter.GetFloat("my_rating") > 0 {
+			watched[collection.ID][id] = true
+		}
+	}
+
+	today := localDate(time.Now())
+	updated := 0
+	for collectionID, collectionNotes := range notes {
+		parts, err := fetchTMDBCollectionParts(token, collectionID)
+		if err != nil {
+			log.WithField("Collection", names[collectionID]).Warnf("Error fetching TMDB collection: %v\n", err)
+			continue
+		}
+
+		// Announced films don't count until they are released
+		released := make(map[int]bool)
+		for _, part := range parts {
+			if part.ReleaseDate != "" && part.ReleaseDate <= today {
+				released[part.ID] = true
+			}
+		}
+		for id := range watched[collectionID] {
+			released[id] = true
+		}
+
+		progress := fmt.Sprintf("%d/%d", len(watched[collectionID]), len(released))
+		for _, note := range collectionNotes {
+			note.Frontmatter.Set("franchise", names[collectionID])
+			note.Frontmatter.Set("franchise_progress", progress)
+			if err := note.Write(); err != nil {
+				log.Errorf("Error writing %s: %v\n", note.Path, err)
+				continue
+			}
+			updated++
+		}
+	}
+
+	summaryf("Updated %d notes in %d franchises\n", updated, len(notes))
+}
+
+// fetchTMDBMovieCollection returns the collection of a movie, the ID is 0 if it has none
+func fetchTMDBMovieCollection(token string, id int) (tmdbCollection, error) {
+	var collection tmdbCollection
+	key := strconv.Itoa(id)
+	if readCache("tmdbcollection", key, &collection) {
+		return collection, nil
+	}
+
+	var response struct {
+		BelongsToCollection *tmdbCollection `json:"belongs_to_collection"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d", id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return collection, err
+	}
+	if response.BelongsToCollection != nil {
+		collection = *response.BelongsToCollection
+	}
+
+	if err := writeCache("tmdbcollection", key, collection); err != nil {
+		log.Warnf("Error caching TMDB collection of %s: %v\n", key, err)
+	}
+	return collection, nil
+}
+
+// tmdbCollectionCacheAge is how long the movies of a collection are cached, collections get new
+// parts when sequels are announced
+const tmdbCollectionCacheAge = 7 * 24 * time.Hour
+
+// fetchTMDBCollectionParts returns the movies of a collection
+func fetchTMDBCollectionParts(token string, id int) ([]tmdbPersonFilm, error) {
+	var parts []tmdbPersonFilm
+	key := strconv.Itoa(id)
+	if readFreshCache("tmdbcollectionparts", key, tmdbCollectionCacheAge, &parts) {
+		return parts, nil
+	}
+
+	var response struct {
+		Parts []tmdbPersonFilm `json:"parts"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/collection/%d", id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCache("tmdbcollectionparts", key, response.Parts); err != nil {
+		log.Warnf("Error caching TMDB collection %s: %v\n", key, err)
+	}
+	return response.Parts, nil
+}
@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter is the YAML header of a note, kept as a node so keys stay in their original order
+type Frontmatter struct {
+	node *yaml.Node
+	// headComment and footComment are the comments around the keys, yaml keeps them on the document
+	headComment string
+	footComment string
+}
+
+// newFrontmatter returns an empty frontmatter block
+func newFrontmatter() *Frontmatter {
+	return &Frontmatter{node: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}}
+}
+
+// parseFrontmatter parses a YAML frontmatter block
+func parseFrontmatter(data string) (*Frontmatter, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, err
+	}
+
+	// Empty frontmatter
+	if len(doc.Content) == 0 {
+		return newFrontmatter(), nil
+	}
+
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("frontmatter is not a mapping")
+	}
+
+	return &Frontmatter{node: doc.Content[0], headComment: doc.HeadComment, footComment: doc.FootComment}, nil
+}
+
+// Keys returns the frontmatter keys in file order
+func (f *Frontmatter) Keys() []string {
+	var keys []string
+	for i := 0; i < len(f.node.Content); i += 2 {
+		keys = append(keys, f.node.Content[i].Value)
+	}
+	return keys
+}
+
+// Has returns true if the key is present
+func (f *Frontmatter) Has(key string) bool {
+	return f.Get(key) != nil
+}
+
+// Get returns the value node for a key, or nil if the key is missing
+func (f *Frontmatter) Get(key string) *yaml.Node {
+	for i := 0; i+1 < len(f.node.Content); i += 2 {
+		if f.node.Content[i].Value == key {
+			return f.node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// GetString returns a scalar value as a string, empty if missing or not a scalar
+func (f *Frontmatter) GetString(key string) string {
+	value := f.Get(key)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// GetInt returns a scalar value as an int, 0 if missing or not a number
+func (f *Frontmatter) GetInt(key string) int {
+	var i int
+	if err := f.Decode(key, &i); err != nil {
+		return 0
+	}
+	return i
+}
+
+// GetFloat returns a scalar value as a float, 0 if missing or not a number
+func (f *Frontmatter) GetFloat(key string) float64 {
+	var n float64
+	if err := f.Decode(key, &n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// GetStrings returns a list value as strings, a single scalar is returned as a one item list
+func (f *Frontmatter) GetStrings(key string) []string {
+	value := f.Get(key)
+	if value == nil {
+		return nil
+	}
+
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Value == "" {
+			return nil
+		}
+		return []string{value.Value}
+	case yaml.SequenceNode, yaml.MappingNode:
+		var values []string
+		for _, item := range value.Content {
+			if item.Kind == yaml.ScalarNode {
+				values = append(values, item.Value)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// Decode decodes the value of a key into v
+func (f *Frontmatter) Decode(key string, v interface{}) error {
+	value := f.Get(key)
+	if value == nil {
+		return fmt.Errorf("key %s not found", key)
+	}
+	return value.Decode(v)
+}
+
+// Set sets a key to the given value, replacing the existing value in place or appending the key
+func (f *Frontmatter) Set(key string, v interface{}) error {
+	var value yaml.Node
+	if err := value.Encode(v); err != nil {
+		return err
+	}
+	plainDates(&value)
+
+	for i := 0; i+1 < len(f.node.Content); i += 2 {
+		if f.node.Content[i].Value == key {
+			old := f.node.Content[i+1]
+			// Setting the value a key already has keeps the quoting of the file, e.g. dates stay unquoted
+			if sameNodeValue(old, &value) {
+				return nil
+			}
+			// Keep the comments and the flow style of lists like tags: [a, b] written in Obsidian
+			value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+			if old.Kind == value.Kind && value.Kind != yaml.ScalarNode {
+				value.Style |= old.Style & yaml.FlowStyle
+			}
+			f.node.Content[i+1] = &value
+			return nil
+		}
+	}
+
+	f.node.Content = append(f.node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&value)
+
+	return nil
+}
+
+// plainDates makes the date strings of a node plain timestamps: yaml quotes strings like 2024-01-02,
+// but the notes written from templates and by Obsidian have them unquoted
+func plainDates(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		if _, err := time.Parse("2006-01-02", node.Value); err == nil {
+			node.Tag = "!!timestamp"
+			node.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+		}
+	}
+	for _, child := range node.Content {
+		plainDates(child)
+	}
+}
+
+// sameNodeValue returns true if two scalars, lists or mappings of scalars have the same values
+func sameNodeValue(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	switch a.Kind {
+	case yaml.ScalarNode:
+		// An empty string isn't a null value
+		return a.Value != "" || a.Tag == b.Tag
+	case yaml.SequenceNode, yaml.MappingNode:
+		for i := range a.Content {
+			if !sameNodeValue(a.Content[i], b.Content[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// AddTags adds the tags missing from the tags list, returns true if any were added
+func (f *Frontmatter) AddTags(tags ...string) (bool, error) {
+	existing := f.GetStrings("tags")
+	merged := existing
+	for _, tag := range tags {
+		found := false
+		for _, e := range merged {
+			if e == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, tag)
+		}
+	}
+
+	if len(merged) == len(existing) {
+		return false, nil
+	}
+	return true, f.Set("tags", merged)
+}
+
+// Delete removes a key, returns true if the key existed
+func (f *Frontmatter) Delete(key string) bool {
+	for i := 0; i+1 < len(f.node.Content); i += 2 {
+		if f.node.Content[i].Value == key {
+			f.node.Content = append(f.node.Content[:i], f.node.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the frontmatter as YAML without the --- delimiters
+func (f *Frontmatter) String() (string, error) {
+	if len(f.node.Content) == 0 && f.headComment == "" && f.footComment == "" {
+		return "", nil
+	}
+
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		Content:     []*yaml.Node{f.node},
+		HeadComment: f.headComment,
+		FootComment: f.footComment,
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// splitFrontmatter splits note content into the raw frontmatter and the body.
+// Delimiter lines may have trailing whitespace and a byte order mark is ignored, like Obsidian does.
+func splitFrontmatter(content string) (frontmatter string, body string, ok bool) {
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\ufeff")
+
+	line, rest, found := strings.Cut(content, "\n")
+	if !found || !isFrontmatterDelimiter(line) {
+		return "", content, false
+	}
+
+	// Frontmatter can be empty, in which case the closing delimiter follows immediately
+	offset := 0
+	for offset <= len(rest) {
+		line, after, found := strings.Cut(rest[offset:], "\n")
+		if isFrontmatterDelimiter(line) {
+			return rest[:offset], after, true
+		}
+		if !found {
+			break
+		}
+		offset += len(line) + 1
+	}
+
+	return "", content, false
+}
+
+// noteLayout is the formatting of a note file around the frontmatter and body: a byte order mark,
+// CRLF line endings and delimiter lines with trailing whitespace are written back as they were read
+type noteLayout struct {
+	bom   bool
+	crlf  bool
+	open  string
+	close string
+}
+
+// detectNoteLayout returns the layout of note content
+func detectNoteLayout(content string) noteLayout {
+	layout := noteLayout{
+		bom:  strings.HasPrefix(content, "\ufeff"),
+		crlf: strings.Contains(content, "\r\n"),
+	}
+	content = strings.ReplaceAll(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n")
+
+	raw, _, ok := splitFrontmatter(content)
+	if !ok {
+		return layout
+	}
+	layout.open, _, _ = strings.Cut(content, "\n")
+	layout.close, _, _ = strings.Cut(content[len(layout.open)+1+len(raw):], "\n")
+	return layout
+}
+
+// render returns note content with the layout
+func (l noteLayout) render(frontmatter, body string) string {
+	open, close := l.open, l.close
+	if open == "" {
+		open = "---"
+	}
+	if close == "" {
+		close = "---"
+	}
+
+	content := open + "\n" + frontmatter + close + "\n" + body
+	if l.crlf {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if l.bom {
+		content = "\ufeff" + content
+	}
+	return content
+}
+
+// isFrontmatterDelimiter returns true for a --- line
+func isFrontmatterDelimiter(line string) bool {
+	return strings.TrimRight(line, " \t") == "---"
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNoteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   interface{}
+	}{
+		{
+			name:    "checkbox",
+			content: "---\ntitle: Heat\nwatched: true\nfavorite: false\n---\nBody\n",
+			key:     "watched",
+			value:   true,
+		},
+		{
+			name:    "date",
+			content: "---\ntitle: Heat\ndate_added: 2024-01-05\nseen: 2024-01-05T20:30:00\n---\nBody\n",
+			key:     "date_added",
+			value:   "2024-01-05",
+		},
+		{
+			name:    "flow list",
+			content: "---\ntitle: Heat\ntags: [movie, imdb/watched]\n---\nBody\n",
+			key:     "tags",
+			value:   []string{"movie", "imdb/watched"},
+		},
+		{
+			name:    "block list",
+			content: "---\ntitle: Heat\ntags:\n  - movie\n  - imdb/watched\n---\nBody\n",
+			key:     "tags",
+			value:   []string{"movie", "imdb/watched"},
+		},
+		{
+			name:    "aliases",
+			content: "---\ntitle: Heat\naliases:\n  - Heat (1995)\n  - \"Heat: A Los Angeles Crime Saga\"\n---\nBody\n",
+			key:     "aliases",
+			value:   []string{"Heat (1995)", "Heat: A Los Angeles Crime Saga"},
+		},
+		{
+			name:    "empty value",
+			content: "---\ntitle: Heat\ncover:\n---\nBody\n",
+			key:     "title",
+			value:   "Heat",
+		},
+		{
+			name:    "quoted number",
+			content: "---\ntitle: \"1917\"\nyear: 2019\n---\nBody\n",
+			key:     "title",
+			value:   "1917",
+		},
+		{
+			name:    "comments",
+			content: "---\n# Written by hermes\n\ntitle: Heat # the 1995 one\n# Rating out of 10\nmy_rating: 9\n\n# end\n---\nBody\n",
+			key:     "my_rating",
+			value:   9,
+		},
+		{
+			name:    "byte order mark",
+			content: "\ufeff---\ntitle: Heat\n---\nBody\n",
+			key:     "title",
+			value:   "Heat",
+		},
+		{
+			name:    "CRLF",
+			content: "---\r\ntitle: Heat\r\ntags:\r\n  - movie\r\n---\r\nBody\r\n\r\nMore\r\n",
+			key:     "tags",
+			value:   []string{"movie"},
+		},
+		{
+			name:    "delimiter whitespace",
+			content: "--- \ntitle: Heat\n---\t\nBody\n",
+			key:     "title",
+			value:   "Heat",
+		},
+		{
+			name:    "empty frontmatter",
+			content: "---\n---\nBody\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Heat.md")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			note, err := readNote(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.key != "" {
+				if err := note.Frontmatter.Set(tt.key, tt.value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			content, err := note.Content()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != tt.content {
+				t.Errorf("Content() = %q, want %q", content, tt.content)
+			}
+
+			if err := note.Write(); err != nil {
+				t.Fatal(err)
+			}
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(written) != tt.content {
+				t.Errorf("written %q, want %q", written, tt.content)
+			}
+		})
+	}
+}
+
+func TestNoteSetKeepsLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Heat.md")
+	content := "\ufeff--- \r\ntitle: Heat\r\ntags: [movie]\r\n---\r\nBody\r\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readNote(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note.Frontmatter.Set("title", "Heat (1995)")
+	note.Frontmatter.Set("tags", []string{"movie", "imdb/watched"})
+
+	got, err := note.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\ufeff--- \r\ntitle: Heat (1995)\r\ntags: [movie, imdb/watched]\r\n---\r\nBody\r\n"
+	if got != want {
+		t.Errorf("Content() = %q, want %q", got, want)
+	}
+}
+
+func TestImportersWriteDatesUnquoted(t *testing.T) {
+	dir := testVault(t, nil)
+
+	if err := newMovieNoteWriter().write(MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie", DateRated: "2024-01-02"}); err != nil {
+		t.Fatal(err)
+	}
+	book := Book{ID: 234225, Title: "Dune", OriginalPublicationYear: 1965, ExclusiveShelf: "read", DateRead: "2024-01-02", DateAdded: "2023-12-24"}
+	if _, err := writeBookToMarkdown(book, newNoteRelocator("goodreads_id")); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, lines := range map[string][]string{
+		"imdb/Heat (1995).md":      {"date_rated: 2024-01-02\n"},
+		"goodreads/Dune (1965).md": {"date_read: 2024-01-02\n", "date_added: 2023-12-24\n"},
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(string(content), line) {
+				t.Errorf("%s doesn't have %q:\n%s", path, line, content)
+			}
+		}
+	}
+}
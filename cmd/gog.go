@@ -0,0 +1,432 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// GalaxyGame is a game in the GOG Galaxy library, releases of the same game on
+// several launchers are merged into one game
+type GalaxyGame struct {
+	ReleaseKeys     []string `json:"Release Keys"`
+	Platforms       []string `json:"Platforms"`
+	Title           string   `json:"Title"`
+	PlaytimeMinutes int      `json:"Playtime (mins)"`
+	LastPlayed      string   `json:"Last Played"`
+	Year            int      `json:"Year"`
+	Developers      []string `json:"Developers"`
+	Publishers      []string `json:"Publishers"`
+	Genres          []string `json:"Genres"`
+	Cover           string   `json:"Cover"`
+	Summary         string   `json:"Summary"`
+}
+
+// galaxyRelease is a single release of a game, e.g. steam_620 or gog_1207658924
+type galaxyRelease struct {
+	Key             string
+	PlaytimeMinutes int
+	LastPlayed      string
+	Pieces          map[string]json.RawMessage
+}
+
+// galaxyMeta is the meta game piece of a release
+type galaxyMeta struct {
+	ReleaseDate int64    `json:"releaseDate"`
+	Developers  []string `json:"developers"`
+	Publishers  []string `json:"publishers"`
+	Genres      []string `json:"genres"`
+}
+
+// gogCmd represents the gog command
+var gogCmd = &cobra.Command{
+	Use:   "gog [file]",
+	Short: "Import the game library from the GOG Galaxy database",
+	Long: `Read the GOG Galaxy 2.0 database and write one note per game in the library. Galaxy
+aggregates the libraries of the connected launchers (GOG, Steam, Epic, ...), releases of the
+same game on several launchers are merged into one note with the total playtime.
+
+The default file is galaxy-2.0.db, on Windows the database is in
+C:\ProgramData\GOG.com\Galaxy\storage\galaxy-2.0.db. Close Galaxy or copy the database
+before importing, it's opened read-only.`,
+	ValidArgsFunction: completeInputFile("db"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing GOG Galaxy library...")
+		parse_gog(inputFile(args, "galaxy-2.0.db"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(gogCmd)
+}
+
+func parse_gog(filename string) {
+	var games []GalaxyGame
+	var err error
+	if importJSONIn {
+		games, err = readGalaxyJSONLines(filename)
+	} else {
+		games, err = readGalaxyDatabase(filename)
+	}
+	if err != nil {
+		log.Errorf("Error reading %s: %v\n", filename, err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(games); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d games\n", len(games))
+		return
+	}
+
+	if err := writeGalaxyGamesToJson(games); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err = writeGalaxyGamesToMarkdown(games)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d games\n", len(games))
+}
+
+func readGalaxyJSONLines(filename string) ([]GalaxyGame, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	records, err := readJSONLines[GalaxyGame](input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Edited records may have lost the release keys the notes are identified by
+	var games []GalaxyGame
+	for i, game := range records {
+		if len(game.ReleaseKeys) == 0 {
+			log.Warnf("Skipping record %d without release keys\n", i+1)
+			continue
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// readGalaxyDatabase reads the owned releases from the Galaxy database and merges them into games
+func readGalaxyDatabase(filename string) ([]GalaxyGame, error) {
+	// database/sql would happily create an empty database for a mistyped path
+	if _, err := os.Stat(filename); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+filename+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	releases, err := readGalaxyReleases(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := readGalaxyPieces(db, releases); err != nil {
+		return nil, err
+	}
+
+	return mergeGalaxyReleases(releases), nil
+}
+
+// readGalaxyReleases returns the releases in the library with their playtime
+func readGalaxyReleases(db *sql.DB) (map[string]*galaxyRelease, error) {
+	rows, err := db.Query(`
+		SELECT lr.releaseKey,
+			COALESCE(SUM(gt.minutesInGame), 0),
+			COALESCE(MAX(lpd.lastPlayedDate), '')
+		FROM LibraryReleases lr
+		LEFT JOIN GameTimes gt ON gt.releaseKey = lr.releaseKey AND gt.userId = lr.userId
+		LEFT JOIN LastPlayedDates lpd ON lpd.gameReleaseKey = lr.releaseKey
+		GROUP BY lr.releaseKey`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := make(map[string]*galaxyRelease)
+	for rows.Next() {
+		release := &galaxyRelease{Pieces: make(map[string]json.RawMessage)}
+		if err := rows.Scan(&release.Key, &release.PlaytimeMinutes, &release.LastPlayed); err != nil {
+			return nil, err
+		}
+		releases[release.Key] = release
+	}
+
+	return releases, rows.Err()
+}
+
+// galaxyPieceTypes are the game pieces we read, the user's edits override the original pieces
+var galaxyPieceTypes = []string{"originalTitle", "title", "originalMeta", "meta", "originalImages", "summary"}
+
+// readGalaxyPieces adds the title, meta, images and summary pieces to the releases
+func readGalaxyPieces(db *sql.DB, releases map[string]*galaxyRelease) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(galaxyPieceTypes)), ",")
+	args := make([]interface{}, len(galaxyPieceTypes))
+	for i, pieceType := range galaxyPieceTypes {
+		args[i] = pieceType
+	}
+
+	rows, err := db.Query(`
+		SELECT gp.releaseKey, gpt.type, gp.value
+		FROM GamePieces gp
+		JOIN GamePieceTypes gpt ON gpt.id = gp.gamePieceTypeId
+		WHERE gpt.type IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, pieceType, value string
+		if err := rows.Scan(&key, &pieceType, &value); err != nil {
+			return err
+		}
+		if release, ok := releases[key]; ok {
+			release.Pieces[pieceType] = json.RawMessage(value)
+		}
+	}
+
+	return rows.Err()
+}
+
+// piece decodes the first of the named pieces the release has, returns false if it has none of them
+func (r *galaxyRelease) piece(v interface{}, names ...string) bool {
+	for _, name := range names {
+		if data, ok := r.Pieces[name]; ok && json.Unmarshal(data, v) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeGalaxyReleases merges the releases of the same game, by title, into games
+func mergeGalaxyReleases(releases map[string]*galaxyRelease) []GalaxyGame {
+	// Sorted so the details of a game come from the same release every run
+	keys := make([]string, 0, len(releases))
+	for key := range releases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	games := make(map[string]*GalaxyGame)
+	var order []string
+	for _, key := range keys {
+		release := releases[key]
+
+		var title struct {
+			Title string `json:"title"`
+		}
+		if !release.piece(&title, "title", "originalTitle") || title.Title == "" {
+			log.WithField("ReleaseKey", key).Debug("Skipping release without a title")
+			continue
+		}
+
+		normalized := normalizeTitle(title.Title)
+		game, ok := games[normalized]
+		if !ok {
+			game = &GalaxyGame{Title: title.Title}
+			games[normalized] = game
+			order = append(order, normalized)
+		}
+
+		game.ReleaseKeys = append(game.ReleaseKeys, key)
+		if platform := galaxyPlatform(key); !containsString(game.Platforms, platform) {
+			game.Platforms = append(game.Platforms, platform)
+		}
+		game.PlaytimeMinutes += release.PlaytimeMinutes
+		if lastPlayed := galaxyDate(release.LastPlayed); lastPlayed > game.LastPlayed {
+			game.LastPlayed = lastPlayed
+		}
+
+		var meta galaxyMeta
+		if game.Year == 0 && release.piece(&meta, "meta", "originalMeta") {
+			if meta.ReleaseDate > 0 {
+				game.Year = time.Unix(meta.ReleaseDate, 0).UTC().Year()
+			}
+			game.Developers = meta.Developers
+			game.Publishers = meta.Publishers
+			game.Genres = meta.Genres
+		}
+
+		var images struct {
+			VerticalCover string `json:"verticalCover"`
+		}
+		if game.Cover == "" && release.piece(&images, "originalImages") {
+			game.Cover = images.VerticalCover
+		}
+
+		var summary struct {
+			Summary string `json:"summary"`
+		}
+		if game.Summary == "" && release.piece(&summary, "summary") {
+			game.Summary = summary.Summary
+		}
+	}
+
+	result := make([]GalaxyGame, 0, len(order))
+	for _, normalized := range order {
+		result = append(result, *games[normalized])
+	}
+	return result
+}
+
+// galaxyPlatform returns the launcher of a release key, e.g. steam for steam_620
+func galaxyPlatform(releaseKey string) string {
+	platform, _, _ := strings.Cut(releaseKey, "_")
+	return platform
+}
+
+// galaxyDate returns the date part of a Galaxy timestamp
+func galaxyDate(timestamp string) string {
+	if len(timestamp) < len("2006-01-02") {
+		return ""
+	}
+	return timestamp[:len("2006-01-02")]
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeGalaxyGamesToJson(games []GalaxyGame) error {
+	jsonData, err := json.Marshal(games)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("gog.json", jsonData)
+}
+
+// writeGalaxyGameToMarkdown writes a game to a markdown file
+func writeGalaxyGameToMarkdown(game GalaxyGame, relocator *noteRelocator) (string, error) {
+	developer := ""
+	if len(game.Developers) > 0 {
+		developer = game.Developers[0]
+	}
+	platform := ""
+	if len(game.Platforms) > 0 {
+		platform = game.Platforms[0]
+	}
+
+	filePath, err := notePath("gog", map[string]string{
+		"title":     game.Title,
+		"year":      yearString(game.Year),
+		"decade":    decade(game.Year),
+		"developer": developer,
+		"platform":  platform,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// A game bought on another launcher gets a new release key, keep using the key the note already has
+	id := ""
+	if len(game.ReleaseKeys) > 0 {
+		id = game.ReleaseKeys[0]
+	}
+	for _, key := range game.ReleaseKeys {
+		if _, ok := relocator.paths[key]; ok {
+			id = key
+			break
+		}
+	}
+	if err := relocator.relocate(id, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{"gog/game"}
+	for _, platform := range game.Platforms {
+		tags = append(tags, "platform/"+platform)
+	}
+	if game.PlaytimeMinutes == 0 {
+		tags = append(tags, "gog/unplayed")
+	}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", game.Title)
+	if aliases := noteAliases(filePath, game.Title); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set("gog_release_key", id)
+	frontmatter.Set("release_keys", game.ReleaseKeys)
+	frontmatter.Set("platforms", game.Platforms)
+	if game.Year > 0 {
+		frontmatter.Set("year", game.Year)
+	}
+	frontmatter.Set("playtime_hours", float64(game.PlaytimeMinutes/6)/10)
+	if game.LastPlayed != "" {
+		frontmatter.Set("last_played", game.LastPlayed)
+	}
+	if len(game.Developers) > 0 {
+		frontmatter.Set("developers", game.Developers)
+	}
+	if len(game.Publishers) > 0 {
+		frontmatter.Set("publishers", game.Publishers)
+	}
+	if len(game.Genres) > 0 {
+		frontmatter.Set("genres", game.Genres)
+	}
+	if game.Cover != "" {
+		frontmatter.Set("cover", game.Cover)
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if game.Cover != "" {
+		body += fmt.Sprintf("![](%s)\n\n", game.Cover)
+	}
+	if game.Summary != "" {
+		body += game.Summary + "\n"
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(id, note)
+	return filePath, note.Write()
+}
+
+// writeGalaxyGamesToMarkdown writes a list of games to markdown files
+func writeGalaxyGamesToMarkdown(games []GalaxyGame) error {
+	relocator := newNoteRelocator("gog_release_key")
+	var entries []indexEntry
+	for _, game := range games {
+		path, err := writeGalaxyGameToMarkdown(game, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: game.Title, Year: game.Year})
+	}
+	return writeIndexNote("gog", entries)
+}
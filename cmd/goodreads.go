@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+	"math"
 	"strconv"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // Book struct represents a book entry in the CSV
@@ -20,6 +22,7 @@ type Book struct {
 	ID                       int      `json:"Book Id"`
 	Title                    string   `json:"Title"`
 	Authors                  []string `json:"Authors"`
+	AuthorLastFirst          string   `json:"Author l-f"`
 	ISBN                     string   `json:"ISBN"`
 	ISBN13                   string   `json:"ISBN13"`
 	MyRating                 float64  `json:"My Rating"`
@@ -39,11 +42,24 @@ type Book struct {
 	PrivateNotes             string   `json:"Private Notes"`
 	ReadCount                int      `json:"Read Count"`
 	OwnedCopies              int      `json:"Owned Copies"`
+	Description              string   `json:"Description"`
+	Categories               []string `json:"Categories"`
+	CoverURL                 string   `json:"Cover URL"`
+	// RatingsCount is the number of Google Books ratings, for the reading priority
+	RatingsCount int `json:"Ratings Count"`
+	// Priority ranks the to-read books, see readingPriority
+	Priority float64 `json:"Priority"`
+	// OwnedFormats are the ebook formats of the book in the OPDS library, like epub
+	OwnedFormats []string `json:"Owned Formats"`
+	// OwnedFormatsUnknown is set when the OPDS library couldn't be searched, the note keeps its formats
+	OwnedFormatsUnknown bool `json:"Owned Formats Unknown,omitempty"`
 }
 
+var goodreadsEnrich bool
+
 // goodreadsCmd represents the goodreads command
 var goodreadsCmd = &cobra.Command{
-	Use:   "goodreads",
+	Use:   "goodreads [file]",
 	Short: "A brief description of your command",
 	Long: `A longer description that spans multiple lines and likely contains examples
 and usage of using your command. For example:
@@ -51,15 +67,18 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	ValidArgsFunction: completeInputFile("csv"),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("processing goodreads export...")
-		parse_goodreads()
+		log.Info("Processing goodreads export...")
+		parse_goodreads(inputFile(args, "goodreads_library_export.csv"))
 	},
 }
 
 func init() {
 	importCmd.AddCommand(goodreadsCmd)
 
+	goodreadsCmd.Flags().BoolVar(&goodreadsEnrich, "enrich", false, "Fetch description, categories and cover from Google Books")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -76,23 +95,72 @@ func splitString(str string) []string {
 	return strings.Split(str, ",")
 }
 
-func parse_goodreads() {
-	// Open the CSV file
-	csvFile, err := os.Open("goodreads_library_export.csv") // Replace "books.csv" with your actual filename
+func parse_goodreads(filename string) {
+	input, err := openInput(filename)
 	if err != nil {
-		fmt.Println(err)
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	var books []Book
+	if importJSONIn {
+		books, err = readJSONLines[Book](input)
+	} else {
+		books, err = readGoodreadsCSV(input)
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	findOwnedEbooks(books)
+
+	if importJSONOut {
+		if err := writeJSONLines(books); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d books\n", len(books))
 		return
 	}
-	defer csvFile.Close()
 
+	// Convert the slice of books to JSON, private notes stay out of it when they are encrypted
+	jsonBooks := books
+	if viper.GetString("AgeRecipient") != "" {
+		jsonBooks = make([]Book, len(books))
+		for i, book := range books {
+			book.PrivateNotes = ""
+			jsonBooks[i] = book
+		}
+	}
+	jsonData, err := json.Marshal(jsonBooks)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if err := writeOutputFile("goodreads.json", jsonData); err != nil {
+		log.Error(err)
+		return
+	}
+
+	err = writeBooksToMarkdown(books)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d books\n", len(books))
+}
+
+// readGoodreadsCSV reads the books from a Goodreads library export, enriching them if requested
+func readGoodreadsCSV(r io.Reader) ([]Book, error) {
 	// Create a new CSV reader
-	reader := csv.NewReader(csvFile)
+	reader := csv.NewReader(r)
 
 	// Skip the header row (assuming the first row contains column names)
-	_, err = reader.Read()
+	_, err := reader.Read()
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, err
 	}
 
 	var books []Book
@@ -104,48 +172,48 @@ func parse_goodreads() {
 			break
 		}
 		if err != nil {
-			fmt.Println(err)
+			log.Warn(err)
 			continue
 		}
 
 		// Convert string values to appropriate types
 		bookID, err := strconv.Atoi(record[0])
 		if err != nil {
-			fmt.Println(err)
+			log.Warn(err)
 			continue
 		}
 
-		myRating, err := strconv.ParseFloat(record[8], 64)
+		myRating, err := strconv.ParseFloat(record[7], 64)
 		if err != nil {
 			myRating = 0.0
 		}
 
-		averageRating, err := strconv.ParseFloat(record[9], 64)
+		averageRating, err := strconv.ParseFloat(record[8], 64)
 		if err != nil {
 			averageRating = 0.0
 		}
 
-		numberOfPages, err := strconv.Atoi(record[12])
+		numberOfPages, err := strconv.Atoi(record[11])
 		if err != nil {
 			numberOfPages = 0
 		}
 
-		yearPublished, err := strconv.Atoi(record[13])
+		yearPublished, err := strconv.Atoi(record[12])
 		if err != nil {
 			yearPublished = 0
 		}
 
-		originalPublicationYear, err := strconv.Atoi(record[14])
+		originalPublicationYear, err := strconv.Atoi(record[13])
 		if err != nil {
 			originalPublicationYear = 0
 		}
 
-		readCount, err := strconv.Atoi(record[20])
+		readCount, err := strconv.Atoi(record[22])
 		if err != nil {
 			readCount = 0
 		}
 
-		ownedCopies, err := strconv.Atoi(record[21])
+		ownedCopies, err := strconv.Atoi(record[23])
 		if err != nil {
 			ownedCopies = 0
 		}
@@ -154,56 +222,279 @@ func parse_goodreads() {
 		isbn := strings.TrimPrefix(strings.TrimSuffix(record[5], "\""), "=\"")
 		isbn13 := strings.TrimPrefix(strings.TrimSuffix(record[6], "\""), "=\"")
 
-		// Separate authors (assuming comma-separated)
-		authors := splitString(record[2])
+		// Main author first, followed by the comma-separated additional authors
+		authors := []string{record[2]}
+		for _, author := range splitString(record[4]) {
+			if author = strings.TrimSpace(author); author != "" {
+				authors = append(authors, author)
+			}
+		}
 
 		// Create a new Book struct and append it to the slice
 		book := Book{
 			ID:                      bookID,
 			Title:                   record[1],
 			Authors:                 authors,
+			AuthorLastFirst:         record[3],
 			ISBN:                    isbn,
 			ISBN13:                  isbn13,
 			MyRating:                myRating,
 			AverageRating:           averageRating,
-			Publisher:               record[10],
-			Binding:                 record[11],
+			Publisher:               record[9],
+			Binding:                 record[10],
 			NumberOfPages:           numberOfPages,
 			YearPublished:           yearPublished,
 			OriginalPublicationYear: originalPublicationYear,
-			DateRead:                record[15],
-			DateAdded:               record[16],
-			Bookshelves:             splitString(record[17]),
-
-			BookshelvesWithPositions: splitString(record[18]),
-			ExclusiveShelf:           record[19],
-			MyReview:                 record[20],
-			Spoiler:                  record[21],
-			PrivateNotes:             record[22],
+			DateRead:                isoDate(record[14]),
+			DateAdded:               isoDate(record[15]),
+			Bookshelves:             splitString(record[16]),
+
+			BookshelvesWithPositions: splitString(record[17]),
+			ExclusiveShelf:           record[18],
+			MyReview:                 record[19],
+			Spoiler:                  record[20],
+			PrivateNotes:             record[21],
 			ReadCount:                readCount,
 			OwnedCopies:              ownedCopies,
 		}
 
+		if goodreadsEnrich {
+			err := withRetry(func() error { return enrichBookFromGoogleBooks(&book) })
+			if err != nil {
+				log.WithField("Title", book.Title).Warnf("Error fetching Google Books data: %v\n", err)
+			}
+			queueRetry("goodreads", strconv.Itoa(book.ID), book, err)
+		}
+
 		books = append(books, book)
 	}
 
-	// Convert the slice of books to JSON
-	jsonData, err := json.Marshal(books)
-	if err != nil {
-		fmt.Println(err)
-		return
+	return books, nil
+}
+
+// retryBook re-enriches a queued book and rewrites its note
+func retryBook(data json.RawMessage) error {
+	var book Book
+	if err := json.Unmarshal(data, &book); err != nil {
+		return err
+	}
+
+	if err := withRetry(func() error { return enrichBookFromGoogleBooks(&book) }); err != nil {
+		return err
 	}
 
-	// Open a new file for writing JSON data
-	jsonFile, err := os.Create("goodreads.json") // Replace "books.json" with your desired output filename
+	_, err := writeBookToMarkdown(book, newNoteRelocator("goodreads_id"))
+	return err
+}
+
+// writeBookToMarkdown writes book info to a markdown file
+func writeBookToMarkdown(book Book, relocator *noteRelocator) (string, error) {
+	year := book.OriginalPublicationYear
+	if year == 0 {
+		year = book.YearPublished
+	}
+
+	author := ""
+	if len(book.Authors) > 0 {
+		author = book.Authors[0]
+	}
+
+	filePath, err := notePath("goodreads", map[string]string{
+		"title":       book.Title,
+		"year":        yearString(year),
+		"decade":      decade(year),
+		"author":      author,
+		"author_last": authorLastName(book),
+		"shelf":       book.ExclusiveShelf,
+	})
 	if err != nil {
-		fmt.Println(err)
-		return
+		return "", err
+	}
+
+	goodreadsID := strconv.Itoa(book.ID)
+	if err := relocator.relocate(goodreadsID, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{}
+	if book.ExclusiveShelf != "" {
+		tags = append(tags, "goodreads/"+slugify(book.ExclusiveShelf))
+	}
+	for _, shelf := range book.Bookshelves {
+		shelf = strings.TrimSpace(shelf)
+		if shelf != "" && shelf != book.ExclusiveShelf {
+			tags = append(tags, "goodreads/"+slugify(shelf))
+		}
+	}
+	if lang := detectLanguage(book.MyReview); lang != "" {
+		tags = append(tags, "lang/"+lang)
+	}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", book.Title)
+	if aliases := noteAliases(filePath, book.Title, bookTitleWithoutSeries(book.Title)); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set("authors", book.Authors)
+	frontmatter.Set("author_sort", authorSortName(book))
+	frontmatter.Set("goodreads_id", goodreadsID)
+	if book.ISBN != "" {
+		frontmatter.Set("isbn", book.ISBN)
+	}
+	if book.ISBN13 != "" {
+		frontmatter.Set("isbn13", book.ISBN13)
+	}
+	frontmatter.Set("year", year)
+	frontmatter.Set("my_rating", book.MyRating)
+	frontmatter.Set("average_rating", book.AverageRating)
+	frontmatter.Set("pages", book.NumberOfPages)
+	if book.NumberOfPages > 0 {
+		frontmatter.Set("est_reading_hours", readingHours(book.NumberOfPages))
+		tags = append(tags, "length/"+bookLength(book.NumberOfPages))
+	}
+	if book.Publisher != "" {
+		frontmatter.Set("publisher", book.Publisher)
+	}
+	if book.DateRead != "" {
+		frontmatter.Set("date_read", book.DateRead)
+	}
+	frontmatter.Set("date_added", book.DateAdded)
+	if len(book.Categories) > 0 {
+		frontmatter.Set("categories", book.Categories)
+	}
+	if book.CoverURL != "" {
+		frontmatter.Set("cover", book.CoverURL)
+	}
+	if book.Priority > 0 {
+		frontmatter.Set("priority", book.Priority)
+	}
+	if len(book.OwnedFormats) > 0 {
+		frontmatter.Set("owned_format", book.OwnedFormats)
+	} else if existing := relocator.existingNode(goodreadsID, "owned_format"); book.OwnedFormatsUnknown && existing != nil {
+		frontmatter.Set("owned_format", existing)
 	}
-	defer jsonFile.Close()
+	frontmatter.Set("tags", tags)
 
-	// Write the JSON data to the file
-	jsonFile.Write(jsonData)
+	var body strings.Builder
+	body.WriteString("\n")
+	if book.CoverURL != "" {
+		body.WriteString(fmt.Sprintf("![](%s)\n\n", book.CoverURL))
+	}
+	if book.Description != "" {
+		body.WriteString(book.Description + "\n\n")
+	}
+	if book.MyReview != "" {
+		body.WriteString("## Review\n\n" + reviewToMarkdown(book.MyReview) + "\n")
+	}
+	if book.PrivateNotes != "" {
+		privateNotes := reviewToMarkdown(book.PrivateNotes)
+		// Encrypted so the notes stay private when the vault is synced, `hermes decrypt` reads them back
+		if recipient := viper.GetString("AgeRecipient"); recipient != "" {
+			if existing := existingEncryption(filePath, privateNotes); existing != "" {
+				privateNotes = existing
+			} else if privateNotes, err = encryptText(privateNotes, recipient); err != nil {
+				return "", fmt.Errorf("encrypting private notes: %w", err)
+			}
+		}
+		body.WriteString("\n> [!note]- Private notes\n> " + strings.ReplaceAll(privateNotes, "\n", "\n> ") + "\n")
+	}
+	if goodreadsEnrich && book.CoverURL == "" {
+		body.WriteString(todoTasks("find cover"))
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body.String()}
+	relocator.restore(goodreadsID, note)
+	return filePath, note.Write()
+}
+
+// writeBooksToMarkdown writes a list of books to markdown files
+func writeBooksToMarkdown(books []Book) error {
+	setReadingPriorities(books)
+
+	relocator := newNoteRelocator("goodreads_id")
+	var entries []indexEntry
+	paths := make(map[int]string)
+	for _, book := range books {
+		path, err := writeBookToMarkdown(book, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		paths[book.ID] = path
+		year := book.OriginalPublicationYear
+		if year == 0 {
+			year = book.YearPublished
+		}
+		entries = append(entries, indexEntry{Path: path, Title: book.Title, Year: year, Rating: book.MyRating})
+		addDailyActivity(book.DateRead, "Read", path, ratingStars(book.MyRating, 5))
+	}
+	if err := writeReadNextNote(books, paths); err != nil {
+		return err
+	}
+	return writeIndexNote("goodreads", entries)
+}
+
+// bookTitleWithoutSeries strips the series from Goodreads titles like "The Way of Kings (The Stormlight Archive, #1)"
+func bookTitleWithoutSeries(title string) string {
+	start := strings.LastIndex(title, " (")
+	if start == -1 || !strings.HasSuffix(title, ")") || !strings.Contains(title[start:], "#") {
+		return title
+	}
+	return title[:start]
+}
+
+// readingHours estimates the reading time of a book from its page count, rounded to half an hour
+func readingHours(pages int) float64 {
+	pagesPerHour := viper.GetFloat64("BookPagesPerHour")
+	if pagesPerHour <= 0 {
+		pagesPerHour = 40
+	}
+	return math.Round(float64(pages)/pagesPerHour*2) / 2
+}
+
+// bookLength returns the length category of a book for the length/ tag
+func bookLength(pages int) string {
+	switch {
+	case pages < 200:
+		return "short"
+	case pages <= 400:
+		return "medium"
+	}
+	return "long"
+}
+
+// authorSortName returns the main author in sorting form, preferring the "Author l-f" column of the export
+func authorSortName(book Book) string {
+	if book.AuthorLastFirst != "" {
+		return book.AuthorLastFirst
+	}
+	if len(book.Authors) == 0 {
+		return ""
+	}
+	return sortName(book.Authors[0])
+}
+
+// authorLastName returns the main author's last name from the "Author l-f" column
+func authorLastName(book Book) string {
+	if last, _, ok := strings.Cut(book.AuthorLastFirst, ","); ok {
+		return strings.TrimSpace(last)
+	}
+	if len(book.Authors) == 0 {
+		return ""
+	}
+	names := strings.Fields(book.Authors[0])
+	if len(names) == 0 {
+		return ""
+	}
+	return names[len(names)-1]
+}
 
-	fmt.Printf("Processed %d books\n", len(books))
+// reviewToMarkdown converts the HTML line breaks Goodreads uses in reviews to newlines
+func reviewToMarkdown(review string) string {
+	review = strings.ReplaceAll(review, "<br/>", "\n")
+	review = strings.ReplaceAll(review, "<br />", "\n")
+	return strings.TrimSpace(review)
 }
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// googleBooksVolume is the subset of the Google Books volumeInfo we use
+type googleBooksVolume struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	PageCount   int      `json:"pageCount"`
+	Categories  []string `json:"categories"`
+	ImageLinks  struct {
+		Thumbnail string `json:"thumbnail"`
+	} `json:"imageLinks"`
+	// RatingsCount is 0 in volumes cached before it was stored, which counts as unknown popularity
+	RatingsCount int `json:"ratingsCount"`
+}
+
+// enrichBookFromGoogleBooks fills in description, categories, cover and missing page count for a book
+func enrichBookFromGoogleBooks(book *Book) error {
+	volume, err := fetchGoogleBooksVolume(book)
+	if err != nil || volume == nil {
+		return err
+	}
+
+	book.Description = volume.Description
+	book.Categories = volume.Categories
+	book.RatingsCount = volume.RatingsCount
+	// Google serves http thumbnail links, https works for the same URL
+	book.CoverURL = strings.Replace(volume.ImageLinks.Thumbnail, "http://", "https://", 1)
+	if book.NumberOfPages == 0 {
+		book.NumberOfPages = volume.PageCount
+	}
+
+	return nil
+}
+
+// fetchGoogleBooksVolume looks the book up by ISBN, falling back to title and author.
+// Returns nil if Google Books doesn't know the book.
+func fetchGoogleBooksVolume(book *Book) (*googleBooksVolume, error) {
+	var queries []string
+	if book.ISBN13 != "" {
+		queries = append(queries, "isbn:"+book.ISBN13)
+	}
+	if book.ISBN != "" {
+		queries = append(queries, "isbn:"+book.ISBN)
+	}
+	query := "intitle:" + book.Title
+	if len(book.Authors) > 0 {
+		query += " inauthor:" + book.Authors[0]
+	}
+	queries = append(queries, query)
+
+	// Misses are cached as an empty volume so they aren't looked up on every run
+	var volume googleBooksVolume
+	cacheKey := queries[0]
+	if readCache("googlebooks", cacheKey, &volume) {
+		if volume.Title == "" {
+			return nil, nil
+		}
+		return &volume, nil
+	}
+
+	for _, query := range queries {
+		found, err := searchGoogleBooks(query)
+		if err != nil {
+			return nil, err
+		}
+		if found != nil {
+			volume = *found
+			break
+		}
+	}
+
+	if err := writeCache("googlebooks", cacheKey, volume); err != nil {
+		log.Warnf("Error caching Google Books volume %s: %v\n", cacheKey, err)
+	}
+
+	if volume.Title == "" {
+		return nil, nil
+	}
+	return &volume, nil
+}
+
+// searchGoogleBooks returns the first volume matching the query
+func searchGoogleBooks(query string) (*googleBooksVolume, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("maxResults", "1")
+	if apiKey := viper.GetString("GoogleBooksAPIKey"); apiKey != "" {
+		params.Set("key", apiKey)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get("https://www.googleapis.com/books/v1/volumes?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var response struct {
+		TotalItems int `json:"totalItems"`
+		Items      []struct {
+			VolumeInfo googleBooksVolume `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Items) == 0 {
+		return nil, nil
+	}
+	return &response.Items[0].VolumeInfo, nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var heatmapDir string
+
+// heatmapCmd represents the heatmap command
+var heatmapCmd = &cobra.Command{
+	Use:   "heatmap",
+	Short: "Add watch history heatmaps to the yearly stats notes",
+	Long: `Collect the watch dates of the movie notes (date_rated and screenings) and write a
+GitHub style heatmap of each year to the yearly stats note, e.g. stats/2023.md.
+
+The notes are written to StatsOutputDir (default <MarkdownOutputDir>/stats).
+The heatmap is written between hermes markers, anything else in the stats note is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateHeatmaps()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(heatmapCmd)
+
+	heatmapCmd.Flags().StringVarP(&heatmapDir, "dir", "d", "", "Directory with movie notes (default the IMDb notes directory)")
+	heatmapCmd.MarkFlagDirname("dir")
+}
+
+// heatmapLevels are the cells of the heatmap, by the number of watches on a day
+var heatmapLevels = []string{"⬜", "🟩", "🟨", "🟧", "🟥"}
+
+func generateHeatmaps() {
+	if heatmapDir == "" {
+		heatmapDir = sourceRootDir("imdb")
+	}
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	paths, err := findNotes(heatmapDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", heatmapDir, err)
+		return
+	}
+
+	// Watches per day, grouped by year
+	years := make(map[int]map[string]int)
+	addWatch := func(date string) {
+		day, err := parseWatchDate(date)
+		if err != nil {
+			return
+		}
+		if years[day.Year()] == nil {
+			years[day.Year()] = make(map[string]int)
+		}
+		years[day.Year()][day.Format("2006-01-02")]++
+	}
+
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		addWatch(note.Frontmatter.GetString("date_rated"))
+		var screenings []Screening
+		if note.Frontmatter.Has("screenings") && note.Frontmatter.Decode("screenings", &screenings) == nil {
+			for _, screening := range screenings {
+				addWatch(screening.Date)
+			}
+		}
+	}
+
+	for year, days := range years {
+		if err := writeHeatmap(year, days, outputDir); err != nil {
+			log.WithField("Year", year).Errorf("Error writing stats note: %v\n", err)
+		}
+	}
+
+	summaryf("Generated heatmaps for %d years\n", len(years))
+}
+
+// writeHeatmap creates or updates the stats note of a year with the heatmap of its watch days
+func writeHeatmap(year int, days map[string]int, directory string) error {
+	var total int
+	for _, count := range days {
+		total += count
+	}
+
+	path := filepath.Join(directory, strconv.Itoa(year)+".md")
+	return updateNote(path, strconv.Itoa(year), []string{"stats"}, func(note *Note) error {
+		note.Frontmatter.Set("watched", total)
+		note.Frontmatter.Set("watch_days", len(days))
+
+		note.Body = replaceSection(note.Body, "heatmap", renderHeatmap(year, days))
+
+		return nil
+	})
+}
+
+// renderHeatmap renders the year as a grid of weeks (columns) and weekdays (rows), Monday first
+func renderHeatmap(year int, days map[string]int) string {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// The first column starts on the Monday before January 1st
+	offset := (int(start.Weekday()) + 6) % 7
+	first := start.AddDate(0, 0, -offset)
+
+	var rows [7]strings.Builder
+	// Month labels start above the column where the month starts, each cell is two characters wide
+	months := []byte(strings.Repeat(" ", 2*54+3))
+	for week, column := first, 0; week.Before(end); week, column = week.AddDate(0, 0, 7), column+2 {
+		for d := 0; d < 7; d++ {
+			day := week.AddDate(0, 0, d)
+			if day.Day() == 1 && day.Year() == year {
+				copy(months[column:], day.Format("Jan"))
+			}
+		}
+
+		for d := 0; d < 7; d++ {
+			day := week.AddDate(0, 0, d)
+			if day.Before(start) || !day.Before(end) {
+				rows[d].WriteString("  ")
+				continue
+			}
+			level := days[day.Format("2006-01-02")]
+			if level >= len(heatmapLevels) {
+				level = len(heatmapLevels) - 1
+			}
+			rows[d].WriteString(heatmapLevels[level])
+		}
+	}
+
+	weekdays := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+	var sb strings.Builder
+	sb.WriteString("## Watch history\n\n```\n")
+	sb.WriteString("    " + strings.TrimRight(string(months), " ") + "\n")
+	for d, row := range rows {
+		sb.WriteString(weekdays[d] + " " + strings.TrimRight(row.String(), " ") + "\n")
+	}
+	sb.WriteString("```\n\n")
+
+	sb.WriteString("Less " + strings.Join(heatmapLevels, "") + " More\n\n")
+
+	var busiest string
+	for day, count := range days {
+		if count > days[busiest] || (count == days[busiest] && day < busiest) {
+			busiest = day
+		}
+	}
+	if days[busiest] > 1 {
+		sb.WriteString(fmt.Sprintf("Busiest day: %s (%d)\n", busiest, days[busiest]))
+	}
+
+	return sb.String()
+}
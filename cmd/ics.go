@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// icsEvent is an all-day calendar event
+type icsEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+}
+
+// icsEscaper escapes the characters that are special in iCalendar text values
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICS writes the events to an iCalendar file
+func writeICS(path string, events []icsEvent) error {
+	var sb strings.Builder
+	writeLine := func(line string) {
+		// Lines longer than 75 octets are folded, continuation lines start with a space
+		for len(line) > 75 {
+			cut := 75
+			for cut > 0 && !isRuneStart(line[cut]) {
+				cut--
+			}
+			sb.WriteString(line[:cut] + "\r\n")
+			line = " " + line[cut:]
+		}
+		sb.WriteString(line + "\r\n")
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//hermes//hermes//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	for _, event := range events {
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + event.UID)
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART;VALUE=DATE:" + event.Date.Format("20060102"))
+		writeLine("DTEND;VALUE=DATE:" + event.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeLine("SUMMARY:" + icsEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			writeLine("DESCRIPTION:" + icsEscaper.Replace(event.Description))
+		}
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// isRuneStart returns true if b is the first byte of a UTF-8 encoded rune
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -4,12 +4,12 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -33,6 +33,12 @@ type MovieSeen struct {
 	NumVotes      int      `json:"Num Votes"`
 	ReleaseDate   string   `json:"Release Date"`
 	Directors     []string `json:"Directors"`
+	Countries     []string `json:"Countries"`
+	Language      string   `json:"Language"`
+	// ContentWarnings are the topics of ContentWarnings.Provider
+	ContentWarnings []string `json:"Content Warnings"`
+	// NotOnTMDB is set when TMDB has no match for the IMDb id, not when the lookup failed
+	NotOnTMDB bool `json:"Not On TMDB,omitempty"`
 }
 
 // Movie struct represents a movie entry in the CSV
@@ -58,7 +64,7 @@ type MovieWatchlist struct {
 
 // imdbCmd represents the imdb command
 var imdbCmd = &cobra.Command{
-	Use:   "imdb",
+	Use:   "imdb [file]",
 	Short: "Parse IMDB export",
 	Long: `A longer description that spans multiple lines and likely contains examples
 and usage of using your command. For example:
@@ -66,9 +72,10 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	ValidArgsFunction: completeInputFile("csv"),
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Info("Processing imdb export...")
-		parse_imdb()
+		parse_imdb(inputFile(args, "imdb_export.csv"))
 	},
 }
 
@@ -86,28 +93,93 @@ func init() {
 	// imdbCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func parse_imdb() {
-	// Open the CSV file
-	csvFile, err := os.Open("imdb_export.csv") // Replace "movies.csv" with your actual filename
+// parse_imdb streams the export: each movie is enriched and written before the next row is read,
+// so memory use doesn't grow with the size of the export
+func parse_imdb(filename string) {
+	input, err := openInput(filename)
 	if err != nil {
-		fmt.Println(err)
+		log.Error(err)
 		return
 	}
-	defer csvFile.Close()
+	defer input.Close()
+
+	var process func(MovieSeen) error
+	var finish func() error
+	// abort is called instead of finish when the input can't be read to the end
+	abort := func() {}
+	failed := 0
+	if importJSONOut {
+		writer := bufio.NewWriter(os.Stdout)
+		encoder := json.NewEncoder(writer)
+		process = func(movie MovieSeen) error { return encoder.Encode(movie) }
+		finish = writer.Flush
+	} else {
+		jsonFile := newJSONArrayWriter("movies.json")
+		notes := newMovieNoteWriter()
+		process = func(movie MovieSeen) error {
+			if err := jsonFile.Write(movie); err != nil {
+				return err
+			}
+			// A note that can't be written doesn't stop the rest of the import
+			if err := notes.write(movie); err != nil {
+				log.WithField("ImdbId", movie.ImdbId).Errorf("Error writing markdown: %v\n", err)
+				failed++
+			}
+			return nil
+		}
+		finish = func() error {
+			if err := jsonFile.Close(); err != nil {
+				return err
+			}
+			return notes.finish()
+		}
+		abort = jsonFile.Abort
+	}
+
+	count := 0
+	each := eachImdbMovie
+	if importJSONIn {
+		each = eachJSONLine[MovieSeen]
+	}
+	err = each(input, func(movie MovieSeen) error {
+		count++
+		enrichMovieOrigin(&movie)
+		enrichMovieWarnings(&movie)
+		return process(movie)
+	})
+	if err != nil {
+		// A broken or truncated input, like a --json-in stream that was cut short, would replace
+		// movies.json and the index note with part of the movies. The notes written are kept.
+		log.Error(err)
+		abort()
+		summaryf("Stopped after %d movies, movies.json and the index note were not updated\n", count)
+		return
+	}
+	if err := finish(); err != nil {
+		log.Errorf("Error writing output: %v\n", err)
+	}
+
+	if failed > 0 {
+		summaryf("Processed %d movies, %d notes failed\n", count, failed)
+		return
+	}
+	summaryf("Processed %d movies\n", count)
+}
 
+// eachImdbMovie reads the movies from an IMDb ratings export one row at a time, calling fn for each
+func eachImdbMovie(r io.Reader, fn func(MovieSeen) error) error {
 	// Create a new CSV reader
-	reader := csv.NewReader(csvFile)
+	reader := csv.NewReader(r)
 	reader.FieldsPerRecord = 14 // Imdb watched export has exactly 14 fields
+	// Every field is copied to the movie, so the record slice can be reused
+	reader.ReuseRecord = true
 
 	// Skip the header row (assuming the first row contains column names)
-	_, err = reader.Read()
+	_, err := reader.Read()
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	var movies []MovieSeen
-
 	// Read each record from the CSV file
 	for {
 		record, err := reader.Read()
@@ -115,7 +187,7 @@ func parse_imdb() {
 			break
 		}
 		if err != nil {
-			fmt.Println(err)
+			log.Warn(err)
 			continue
 		}
 
@@ -160,14 +232,19 @@ func parse_imdb() {
 		// Separate genres (assuming comma-separated)
 		genres := strings.Split(record[10], ",")
 
-		// Separate directors (assuming comma-separated)
-		directors := strings.Split(record[13], ",")
+		// Separate directors (assuming comma-separated), series have none
+		var directors []string
+		for _, director := range strings.Split(record[13], ",") {
+			if director = strings.TrimSpace(director); director != "" {
+				directors = append(directors, director)
+			}
+		}
 
 		// Create a new Movie struct and append it to the slice
 		movie := MovieSeen{
 			ImdbId:        record[0],
 			MyRating:      myRating,
-			DateRated:     record[2],
+			DateRated:     isoDate(record[2]),
 			Title:         record[3],
 			OriginalTitle: record[4],
 			URL:           record[5],
@@ -183,91 +260,170 @@ func parse_imdb() {
 
 		log.Debugf("%v\n", movie)
 
-		movies = append(movies, movie)
-	}
-
-	writeMovieToJson(movies)
-	err = writeMoviesToMarkdown(movies, filepath.Join(viper.GetString("MarkdownOutputDir"), "imdb"))
-	if err != nil {
-		log.Errorf("Error writing markdown: %v\n", err)
+		if err := fn(movie); err != nil {
+			return err
+		}
 	}
 
-	log.Infof("Processed %d movies\n", len(movies))
+	return nil
 }
 
-func writeMovieToJson(movies []MovieSeen) {
-	// Convert the slice of movies to JSON
-	jsonData, err := json.Marshal(movies)
-	if err != nil {
-		fmt.Println(err)
+// enrichMovieOrigin fills in the countries and original language from TMDB when TMDBAccessToken is set
+func enrichMovieOrigin(movie *MovieSeen) {
+	token := viper.GetString("TMDBAccessToken")
+	// Movies read with --json-in may already have them
+	if token == "" || len(movie.Countries) > 0 {
 		return
 	}
 
-	// Open a new file for writing JSON data
-	jsonFile, err := os.Create("movies.json") // Replace "movies.json" with your desired filename
+	mediaType, id, err := findTMDBByImdbID(token, movie.ImdbId)
+	movie.NotOnTMDB = err == nil && id == 0
+	if err == nil && id != 0 {
+		var origin tmdbOrigin
+		origin, err = fetchTMDBOrigin(token, mediaType, id)
+		movie.Countries, movie.Language = origin.Countries, origin.Language
+	}
 	if err != nil {
-		fmt.Println(err)
+		log.WithField("ImdbId", movie.ImdbId).Warnf("Error fetching TMDB details: %v\n", err)
+	}
+}
+
+// enrichMovieWarnings fills in the content warnings when ContentWarnings.Provider is set
+func enrichMovieWarnings(movie *MovieSeen) {
+	// Movies read with --json-in may already have them
+	if !contentWarningsEnabled() || movie.ContentWarnings != nil {
 		return
 	}
-	defer jsonFile.Close()
 
-	// Write the JSON data to the file
-	_, err = jsonFile.Write(jsonData)
+	var mediaType string
+	var id int
+	var err error
+	if viper.GetString("ContentWarnings.Provider") == warningsTMDB {
+		mediaType, id, err = findTMDBByImdbID(viper.GetString("TMDBAccessToken"), movie.ImdbId)
+	}
+	if err == nil {
+		movie.ContentWarnings, err = fetchContentWarnings(mediaType, id, movie.ImdbId, movie.Title, movie.Year)
+	}
 	if err != nil {
-		fmt.Println(err)
+		log.WithField("ImdbId", movie.ImdbId).Warnf("Error fetching content warnings: %v\n", err)
 	}
 }
 
 // writeMovieToMarkdown writes movie info to a markdown file
-func writeMovieToMarkdown(movie MovieSeen, directory string) error {
-	// Sanitize movie title for filename
-	filename := sanitizeFilename(movie.Title) + ".md"
-	filePath := filepath.Join(directory, filename)
+func writeMovieToMarkdown(movie MovieSeen, relocator *noteRelocator) (string, error) {
+	director := ""
+	if len(movie.Directors) > 0 {
+		director = movie.Directors[0]
+	}
+
+	preference := relocator.titlePreference(movie.ImdbId)
+	name := preferredTitle(preference, movie.Title, movie.OriginalTitle, titleLocalized)
+
+	filePath, err := notePath("imdb", map[string]string{
+		"title":          name,
+		"original_title": movie.OriginalTitle,
+		"year":           yearString(movie.Year),
+		"decade":         decade(movie.Year),
+		"type":           strings.TrimPrefix(mapTypeToTag(movie.TitleType), "imdb/"),
+		"director":       director,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(movie.ImdbId, filePath); err != nil {
+		return "", err
+	}
 
 	// Create markdown content
-	var title string
+	title := fmt.Sprintf("title: %s\n", sanitizeTitle(name))
 	if movie.Title == movie.OriginalTitle {
 		movie.Title = sanitizeTitle(movie.Title)
-		title = fmt.Sprintf("title: %s\n", movie.Title)
 	} else {
 		movie.Title = sanitizeTitle(movie.Title)
 		movie.OriginalTitle = sanitizeTitle(movie.OriginalTitle)
-		title = fmt.Sprintf("title: %s\noriginal_title: %s\n", movie.Title, movie.OriginalTitle)
+		title += fmt.Sprintf("original_title: %s\n", movie.OriginalTitle)
+	}
+	if preference != "" {
+		title += fmt.Sprintf("title_preference: %s\n", preference)
+	}
+
+	aliasList := ""
+	if aliases := noteAliases(filePath, movie.Title, movie.OriginalTitle); len(aliases) > 0 {
+		aliasList = fmt.Sprintf("aliases:\n  - %s\n", strings.Join(aliases, "\n  - "))
 	}
 
 	tags := []string{}
 	tags = append(tags, mapTypeToTag(movie.TitleType))
+	tags = append(tags, contentWarningTags(movie.ContentWarnings)...)
 
 	genreList := strings.Join(movie.Genres, "\n  - ")
-	tagList := strings.Join(tags, "\n  - ")
 
-	content := fmt.Sprintf("---\n%surl: %s\nyear: %d\nimdb_rating: %.2f\nmy_rating: %d\ndate_rated: %s\nruntime: %d\ngenres:\n  - %s\ntags:\n  - %s\n---\n\n",
-		title, movie.URL, movie.Year, movie.IMDbRating, movie.MyRating, movie.DateRated, movie.RuntimeMins, genreList, tagList)
+	directorList := ""
+	if len(movie.Directors) > 0 {
+		directorList = fmt.Sprintf("directors:\n  - %s\ndirector_sort: %s\n", strings.Join(movie.Directors, "\n  - "), sortName(director))
+	}
+	originList := ""
+	if len(movie.Countries) > 0 {
+		originList = fmt.Sprintf("country:\n  - %s\n", strings.Join(movie.Countries, "\n  - "))
+	}
+	if movie.Language != "" {
+		originList += fmt.Sprintf("language: %s\n", movie.Language)
+	}
+	tagList := strings.Join(tags, "\n  - ")
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(directory, 0755); err != nil {
-		return err
+	todo := ""
+	if movie.NotOnTMDB {
+		todo = strings.TrimPrefix(todoTasks("find TMDB match"), "\n")
+	}
+	if callout := contentWarningCallout(movie.ContentWarnings); callout != "" {
+		if todo != "" {
+			todo += "\n"
+		}
+		todo += callout
 	}
 
+	content := fmt.Sprintf("---\n%s%simdb_id: %s\nids:\n  imdb: %s\nurl: %s\nyear: %d\nimdb_rating: %.2f\nmy_rating: %d\ndate_rated: %s\nruntime: %d\ngenres:\n  - %s\n%s%stags:\n  - %s\n---\n\n%s",
+		title, aliasList, movie.ImdbId, movie.ImdbId, movie.URL, movie.Year, movie.IMDbRating, movie.MyRating, movie.DateRated, movie.RuntimeMins, genreList, directorList, originList, tagList, todo)
+
 	// Write content to file
-	return os.WriteFile(filePath, []byte(content), 0644)
+	_, err = writeNoteFile(filePath, relocator.restoreContent(movie.ImdbId, filePath, content))
+	return filePath, err
 }
 
 func sanitizeTitle(title string) string {
 	return strings.ReplaceAll(title, ":", "")
 }
 
-// writeMoviesToMarkdown writes a list of movies to markdown files
-func writeMoviesToMarkdown(movies []MovieSeen, directory string) error {
-	for _, movie := range movies {
-		err := writeMovieToMarkdown(movie, directory)
-		if err != nil {
-			return err
-		}
+// movieNoteWriter writes movie notes one at a time, keeping only what the index note needs
+type movieNoteWriter struct {
+	relocator *noteRelocator
+	entries   []indexEntry
+}
+
+func newMovieNoteWriter() *movieNoteWriter {
+	return &movieNoteWriter{relocator: newNoteRelocator("imdb_id")}
+}
+
+// write writes the note of a movie
+func (w *movieNoteWriter) write(movie MovieSeen) error {
+	path, err := writeMovieToMarkdown(movie, w.relocator)
+	if skipNoteConflict(err) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
+	w.entries = append(w.entries, indexEntry{Path: path, Title: movie.Title, Year: movie.Year, Rating: float64(movie.MyRating)})
+	addDailyActivity(movie.DateRated, "Watched", path, ratingStars(float64(movie.MyRating), 10))
 	return nil
 }
 
+// finish writes the index note of the written movies
+func (w *movieNoteWriter) finish() error {
+	return writeIndexNote("imdb", w.entries)
+}
+
 // mapTypeToTag maps a imdb title type to a markdown tag
 func mapTypeToTag(titleType string) string {
 	switch titleType {
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseImdbTruncatedStream(t *testing.T) {
+	vault := testVault(t, nil)
+
+	workdir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(workdir) })
+
+	previousJSONIn := importJSONIn
+	importJSONIn = true
+	t.Cleanup(func() { importJSONIn = previousJSONIn })
+
+	previous := `[{"ImdbId":"tt0113277","Title":"Heat"}]`
+	if err := os.WriteFile("movies.json", []byte(previous), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := `{"ImdbId":"tt0113277","Title":"Heat","Year":1995,"Title Type":"Movie"}` + "\n" + `{"ImdbId":"tt01`
+	if err := os.WriteFile("movies.jsonl", []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parse_imdb("movies.jsonl")
+
+	if got, err := os.ReadFile("movies.json"); err != nil || string(got) != previous {
+		t.Errorf("movies.json = %q (%v), want the previous run's %q", got, err, previous)
+	}
+	if _, err := os.Stat("movies.json.partial"); !os.IsNotExist(err) {
+		t.Errorf("movies.json.partial left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vault, "imdb/Heat (1995).md")); err != nil {
+		t.Errorf("note read before the error wasn't kept: %v", err)
+	}
+	notes, err := findNotes(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 {
+		t.Errorf("notes = %v, want only the movie note without an index note", notes)
+	}
+}
@@ -23,11 +23,40 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("import called")
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		startDryRun()
+		startRunLogFile()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		writeDailyNotes()
+		dryRunSummary()
+		if noteWrites.written+noteWrites.unchanged > 0 {
+			summaryf("Notes: %d written, %d unchanged\n", noteWrites.written, noteWrites.unchanged)
+		}
+		reportSyncConflicts()
+		saveRetryQueue()
+		unlockVault()
+		stopRunLogFile()
+		notifyRunCompleted("import " + cmd.Name())
+	},
 }
 
 func init() {
 	rootCmd.AddCommand(importCmd)
 
+	// Importers read their export from the file argument, "-" reads it from stdin.
+	// The JSON stream flags allow filtering the records with other tools before writing notes:
+	//   hermes import imdb export.csv --json-out | jq -c 'select(.Year > 2000)' | hermes import imdb - --json-in
+	importCmd.PersistentFlags().BoolVar(&importJSONOut, "json-out", false, "Write the processed records to stdout as JSON lines instead of writing notes")
+	importCmd.PersistentFlags().BoolVar(&importJSONIn, "json-in", false, "Read JSON lines written by --json-out instead of the export CSV")
+	importCmd.PersistentFlags().BoolVar(&importDryRun, "dry-run", false, "Report the notes that would be created, updated and moved without writing anything")
+
+	// Dated activity like watches, reads and plays is appended to the Obsidian daily notes:
+	//   - Watched [[Heat (1995)]] ★★★★
+	importCmd.PersistentFlags().StringVar(&importDailyNotes, "daily-notes", "", "Daily notes directory to append the dated activity of the import to")
+	importCmd.MarkPersistentFlagDirname("daily-notes")
+	importCmd.PersistentFlags().StringVar(&importDailyNotesFormat, "daily-notes-format", "", "Daily note name format like YYYY-MM-DD (default from the vault's Daily notes settings)")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+const indexNoteName = "_Index.md"
+
+// indexEntry is a note listed in a source's index note
+type indexEntry struct {
+	Path   string
+	Title  string
+	Year   int
+	Rating float64
+}
+
+// writeIndexNote creates or updates the Map of Content note of a source,
+// grouping the entries as set by IndexNoteGroupBy (decade, rating or alphabet, empty disables)
+func writeIndexNote(source string, entries []indexEntry) error {
+	groupBy := viper.GetString("IndexNoteGroupBy")
+	if groupBy == "" {
+		return nil
+	}
+
+	groupKey, err := indexGrouping(groupBy)
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string][]indexEntry)
+	for _, entry := range entries {
+		key := groupKey(entry)
+		groups[key] = append(groups[key], entry)
+	}
+
+	var keys []string
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		// Best ratings first, unrated last
+		if groupBy == "rating" {
+			var a, b int
+			fmt.Sscanf(keys[i], "Rating %d", &a)
+			fmt.Sscanf(keys[j], "Rating %d", &b)
+			return a > b
+		}
+		return keys[i] < keys[j]
+	})
+
+	var sb strings.Builder
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool {
+			return strings.ToLower(group[i].Title) < strings.ToLower(group[j].Title)
+		})
+
+		sb.WriteString(fmt.Sprintf("## %s\n\n", key))
+		for _, entry := range group {
+			sb.WriteString("- " + wikilink(entry.Path) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	path := filepath.Join(sourceRootDir(source), indexNoteName)
+	return updateNote(path, strings.ToUpper(source[:1])+source[1:]+" index", []string{"index"}, func(note *Note) error {
+		note.Frontmatter.Set("count", len(entries))
+
+		note.Body = replaceSection(note.Body, "index", sb.String())
+
+		return nil
+	})
+}
+
+// indexGrouping returns the function that picks the group heading for an entry
+func indexGrouping(groupBy string) (func(indexEntry) string, error) {
+	switch groupBy {
+	case "decade":
+		return func(entry indexEntry) string {
+			return decade(entry.Year)
+		}, nil
+	case "rating":
+		return func(entry indexEntry) string {
+			if entry.Rating <= 0 {
+				return "Unrated"
+			}
+			return fmt.Sprintf("Rating %d", int(math.Round(entry.Rating)))
+		}, nil
+	case "alphabet":
+		return func(entry indexEntry) string {
+			for _, r := range entry.Title {
+				if unicode.IsLetter(r) {
+					return string(unicode.ToUpper(r))
+				}
+				break
+			}
+			return "#"
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown IndexNoteGroupBy %q, expected decade, rating or alphabet", groupBy)
+}
+
+// sourceRootDir returns the directory a source's notes are written under,
+// the static part of its path template before any placeholders
+func sourceRootDir(source string) string {
+	template := viper.GetStringMapString("PathTemplates")[source]
+	if template == "" {
+		template = defaultPathTemplates[source]
+	}
+
+	if i := strings.Index(template, "{{"); i != -1 {
+		template = template[:i]
+	}
+	if i := strings.LastIndex(template, "/"); i != -1 {
+		template = template[:i]
+	} else {
+		template = ""
+	}
+
+	return filepath.Join(viper.GetString("MarkdownOutputDir"), filepath.FromSlash(template))
+}
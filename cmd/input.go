@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+var (
+	importJSONIn  bool
+	importJSONOut bool
+)
+
+// inputFile returns the input file given as the first argument, or the default file
+func inputFile(args []string, defaultFile string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return defaultFile
+}
+
+// openInput opens the importer input, "-" reads from stdin
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// readJSONLines reads one JSON record per line, as written by --json-out
+func readJSONLines[T any](r io.Reader) ([]T, error) {
+	var records []T
+	err := eachJSONLine(r, func(record T) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// eachJSONLine calls fn for each JSON record as it's read, stopping at the first error
+func eachJSONLine[T any](r io.Reader, fn func(T) error) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record T
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// writeJSONLines writes the records to stdout as one JSON object per line
+func writeJSONLines[T any](records []T) error {
+	writer := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// jsonArrayWriter writes records as a JSON array one at a time, so the records don't have to be
+// kept in memory to write an importer's JSON output file. The records go to a .partial file
+// created on the first write, which replaces the output file when it's closed.
+type jsonArrayWriter struct {
+	name  string
+	file  io.WriteCloser
+	buf   *bufio.Writer
+	count int
+}
+
+func newJSONArrayWriter(name string) *jsonArrayWriter {
+	return &jsonArrayWriter{name: name}
+}
+
+// open creates the file, nothing is written in a dry run
+func (w *jsonArrayWriter) open() error {
+	if w.file != nil {
+		return nil
+	}
+	file, err := createOutputFile(w.name + ".partial")
+	if err != nil {
+		return err
+	}
+	w.file, w.buf = file, bufio.NewWriter(file)
+	return nil
+}
+
+// Write appends a record to the array
+func (w *jsonArrayWriter) Write(record any) error {
+	if err := w.open(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if w.count == 0 {
+		separator = "["
+	}
+	w.count++
+	if _, err := w.buf.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = w.buf.Write(data)
+	return err
+}
+
+// Close ends the array and closes the file, an empty array is written as null like json.Marshal
+// does for a nil slice
+func (w *jsonArrayWriter) Close() error {
+	if err := w.open(); err != nil {
+		return err
+	}
+	end := "]"
+	if w.count == 0 {
+		end = "null"
+	}
+	if _, err := w.buf.WriteString(end); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if importDryRun {
+		return nil
+	}
+	return os.Rename(w.name+".partial", w.name)
+}
+
+// Abort removes the partial file, the output file of the previous run is kept
+func (w *jsonArrayWriter) Abort() {
+	if w.file == nil {
+		return
+	}
+	w.file.Close()
+	if !importDryRun {
+		os.Remove(w.name + ".partial")
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageStopwords are common short words of each detectable language, by ISO 639-1 code
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "is", "was", "it", "of", "to", "in", "that", "this", "but", "with", "for", "not", "book", "i", "you", "a", "be", "have", "so", "very", "read", "my"},
+	"fi": {"ja", "on", "ei", "se", "että", "oli", "mutta", "kun", "kirja", "tämä", "hyvä", "myös", "niin", "vain", "kuin", "olen", "ole", "mitä", "tai", "jo", "sen", "hän", "minä", "joka", "vielä"},
+	"sv": {"och", "är", "det", "att", "inte", "en", "som", "på", "med", "för", "jag", "den", "var", "men", "om", "bok", "av", "har", "till", "mycket"},
+	"de": {"und", "ist", "die", "der", "das", "nicht", "ich", "ein", "eine", "mit", "sehr", "war", "auch", "es", "zu", "buch", "aber", "sich", "auf", "den"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "pas", "je", "que", "des", "du", "en", "très", "livre", "mais", "pour", "dans", "ce", "qui"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "no", "que", "de", "muy", "libro", "pero", "con", "por", "para", "se", "lo", "del"},
+}
+
+// languageMinWords is how many words a text needs before its language is guessed
+const languageMinWords = 5
+
+var languageIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range languageStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// detectLanguage guesses the language of a text from its stopwords, returns an ISO 639-1 code
+// or empty if the text is too short or no language clearly wins
+func detectLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < languageMinWords {
+		return ""
+	}
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for _, lang := range languageIndex[word] {
+			scores[lang]++
+		}
+	}
+
+	var best, second int
+	var bestLang string
+	for lang, score := range scores {
+		switch {
+		case score > best || (score == best && lang < bestLang):
+			best, second, bestLang = score, best, lang
+		case score > second:
+			second = score
+		}
+	}
+
+	// Ties and single hits are too weak to tag
+	if best < 2 || best == second {
+		return ""
+	}
+	return bestLang
+}
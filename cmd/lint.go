@@ -0,0 +1,404 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// lint severities, ordered from least to most severe
+var lintSeverities = map[string]int{
+	"info":    0,
+	"warning": 1,
+	"error":   2,
+}
+
+// LintRuleConfig is the config of a single lint rule, not every rule uses every field
+type LintRuleConfig struct {
+	Severity string   `mapstructure:"severity"`
+	Disabled bool     `mapstructure:"disabled"`
+	Field    string   `mapstructure:"field"`
+	Min      float64  `mapstructure:"min"`
+	Max      float64  `mapstructure:"max"`
+	Allowed  []string `mapstructure:"allowed"`
+}
+
+// lintRule checks notes for a single kind of problem
+type lintRule interface {
+	// check returns a message for each problem found in the note
+	check(note *Note) []string
+}
+
+// lintFixer is implemented by rules that can fix the problems they find
+type lintFixer interface {
+	// fix changes the note to resolve the problems, returns true if anything changed
+	fix(note *Note) (bool, error)
+}
+
+// lintRules maps rule names to their constructors, rules are enabled by configuring them under lint.rules
+var lintRules = map[string]func(config LintRuleConfig) lintRule{
+	"missing-year":  func(config LintRuleConfig) lintRule { return missingFieldRule{field: "year"} },
+	"rating-range":  newRatingRangeRule,
+	"tag-taxonomy":  func(config LintRuleConfig) lintRule { return tagTaxonomyRule{allowed: config.Allowed} },
+	"duplicate":     func(config LintRuleConfig) lintRule { return &duplicateRule{seen: make(map[string]string)} },
+	"body-length":   func(config LintRuleConfig) lintRule { return bodyLengthRule{min: int(config.Min)} },
+	"legacy-values": func(config LintRuleConfig) lintRule { return legacyValuesRule{} },
+}
+
+// defaultLintRules are used when the config has no lint rules
+var defaultLintRules = map[string]LintRuleConfig{
+	"missing-year":  {Severity: "warning"},
+	"rating-range":  {Severity: "error", Field: "my_rating", Min: 0, Max: 10},
+	"duplicate":     {Severity: "warning"},
+	"legacy-values": {Severity: "warning"},
+}
+
+// lintFinding is a problem found in a note
+type lintFinding struct {
+	Path     string
+	Rule     string
+	Severity string
+	Message  string
+	Fixed    bool
+}
+
+var (
+	lintDir    string
+	lintFailOn string
+	lintFix    bool
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:     "lint [files...]",
+	Aliases: []string{"check"},
+	Short:   "Check notes against the vault hygiene rules",
+	Long: `Check notes against configurable rules and report problems with a severity.
+
+Lints the given files, or every note in the directory. Exits with status 1 if any
+problem is at least as severe as --fail-on, so it can be used in a pre-commit hook.
+With --fix, problems of rules that can fix them are fixed in place and don't fail the run.
+
+Rules are configured in the config file:
+
+  lint:
+    rules:
+      missing-year:
+        severity: warning
+      rating-range:
+        severity: error
+        field: my_rating
+        min: 0
+        max: 10
+      tag-taxonomy:
+        severity: warning
+        allowed: [imdb/, goodreads/, genre/]
+      duplicate:
+        severity: error
+      body-length:
+        severity: info
+        min: 100
+      legacy-values:
+        severity: warning
+
+legacy-values finds the values old hermes versions wrote: rating/0 and year/0s tags
+and empty strings in fields like cover, it can be fixed with --fix.
+
+Without configured rules missing-year, rating-range, duplicate and legacy-values are checked.`,
+	ValidArgsFunction: completeFiles("md"),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !lintNotes(args) {
+			// os.Exit skips the post run, release the lock --fix took on the vault first
+			unlockVault()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringVarP(&lintDir, "dir", "d", "", "Directory with notes to lint (default MarkdownOutputDir)")
+	lintCmd.MarkFlagDirname("dir")
+	lintCmd.Flags().StringVar(&lintFailOn, "fail-on", "error", "Lowest severity that makes the command fail: info, warning or error")
+	lintCmd.Flags().BoolVar(&lintFix, "fix", false, "Fix the problems of rules that can fix them")
+}
+
+// lintNotes lints the notes and prints the findings, returns false if a finding reached the --fail-on severity
+func lintNotes(paths []string) bool {
+	failLevel, ok := lintSeverities[lintFailOn]
+	if !ok {
+		log.Errorf("Unknown severity %q\n", lintFailOn)
+		return false
+	}
+
+	configs := defaultLintRules
+	if viper.IsSet("lint.rules") {
+		configs = make(map[string]LintRuleConfig)
+		if err := viper.UnmarshalKey("lint.rules", &configs); err != nil {
+			log.Errorf("Error reading lint rules from config: %v\n", err)
+			return false
+		}
+	}
+
+	// Sorted so rules and findings are reported in a stable order
+	var names []string
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type configuredRule struct {
+		name     string
+		severity string
+		rule     lintRule
+	}
+	var rules []configuredRule
+	for _, name := range names {
+		config := configs[name]
+		if config.Disabled {
+			continue
+		}
+		newRule, ok := lintRules[name]
+		if !ok {
+			log.Errorf("Unknown lint rule %q\n", name)
+			return false
+		}
+		if config.Severity == "" {
+			config.Severity = "warning"
+		}
+		if _, ok := lintSeverities[config.Severity]; !ok {
+			log.Errorf("Unknown severity %q for lint rule %s\n", config.Severity, name)
+			return false
+		}
+		rules = append(rules, configuredRule{name: name, severity: config.Severity, rule: newRule(config)})
+	}
+
+	if len(paths) == 0 {
+		if lintDir == "" {
+			lintDir = viper.GetString("MarkdownOutputDir")
+		}
+		var err error
+		paths, err = findNotes(lintDir)
+		if err != nil {
+			log.Errorf("Error reading notes from %s: %v\n", lintDir, err)
+			return false
+		}
+	}
+
+	var findings []lintFinding
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			findings = append(findings, lintFinding{Path: path, Rule: "frontmatter", Severity: "error", Message: err.Error()})
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		changed := false
+		for _, rule := range rules {
+			messages := rule.rule.check(note)
+			fixed := false
+			if fixer, ok := rule.rule.(lintFixer); ok && lintFix && len(messages) > 0 {
+				fixed, err = fixer.fix(note)
+				if err != nil {
+					log.WithField("Path", path).Errorf("Error fixing %s: %v\n", rule.name, err)
+				}
+				changed = changed || fixed
+			}
+			for _, message := range messages {
+				findings = append(findings, lintFinding{Path: path, Rule: rule.name, Severity: rule.severity, Message: message, Fixed: fixed})
+			}
+		}
+		if changed {
+			if err := note.Write(); err != nil {
+				log.WithField("Path", path).Errorf("Error writing fixed note: %v\n", err)
+			}
+		}
+	}
+
+	passed := true
+	counts := make(map[string]int)
+	for _, finding := range findings {
+		if finding.Fixed {
+			fmt.Printf("%s: fixed: %s: %s\n", finding.Path, finding.Rule, finding.Message)
+			counts["fixed"]++
+			continue
+		}
+		fmt.Printf("%s: %s: %s: %s\n", finding.Path, finding.Severity, finding.Rule, finding.Message)
+		counts[finding.Severity]++
+		if lintSeverities[finding.Severity] >= failLevel {
+			passed = false
+		}
+	}
+
+	log.Infof("Linted %d notes: %d errors, %d warnings, %d info, %d fixed\n", len(paths), counts["error"], counts["warning"], counts["info"], counts["fixed"])
+
+	return passed
+}
+
+// missingFieldRule reports notes without a value for a field
+type missingFieldRule struct {
+	field string
+}
+
+func (r missingFieldRule) check(note *Note) []string {
+	if note.Frontmatter.GetString(r.field) == "" || note.Frontmatter.GetString(r.field) == "0" {
+		return []string{fmt.Sprintf("missing %s", r.field)}
+	}
+	return nil
+}
+
+// ratingRangeRule reports ratings outside the allowed range
+type ratingRangeRule struct {
+	field    string
+	min, max float64
+}
+
+func newRatingRangeRule(config LintRuleConfig) lintRule {
+	rule := ratingRangeRule{field: config.Field, min: config.Min, max: config.Max}
+	if rule.field == "" {
+		rule.field = "my_rating"
+	}
+	if rule.max == 0 {
+		rule.max = 10
+	}
+	return rule
+}
+
+func (r ratingRangeRule) check(note *Note) []string {
+	if !note.Frontmatter.Has(r.field) {
+		return nil
+	}
+
+	var rating float64
+	if err := note.Frontmatter.Decode(r.field, &rating); err != nil {
+		return []string{fmt.Sprintf("%s is not a number: %q", r.field, note.Frontmatter.GetString(r.field))}
+	}
+	if rating < r.min || rating > r.max {
+		return []string{fmt.Sprintf("%s %g is outside %g-%g", r.field, rating, r.min, r.max)}
+	}
+	return nil
+}
+
+// tagTaxonomyRule reports tags that don't start with any of the allowed prefixes
+type tagTaxonomyRule struct {
+	allowed []string
+}
+
+func (r tagTaxonomyRule) check(note *Note) []string {
+	var problems []string
+	for _, tag := range note.Frontmatter.GetStrings("tags") {
+		tag = strings.TrimPrefix(tag, "#")
+		allowed := false
+		for _, prefix := range r.allowed {
+			if tag == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(tag, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("tag %s is not in the taxonomy", tag))
+		}
+	}
+	return problems
+}
+
+// duplicateRule reports notes with the same title and year as an earlier note
+type duplicateRule struct {
+	seen map[string]string
+}
+
+func (r *duplicateRule) check(note *Note) []string {
+	key := normalizeTitle(note.Title()) + "|" + note.Frontmatter.GetString("year")
+	if first, ok := r.seen[key]; ok {
+		return []string{fmt.Sprintf("same title and year as %s", first)}
+	}
+	r.seen[key] = note.Path
+	return nil
+}
+
+// bodyLengthRule reports notes with a body shorter than the minimum length
+type bodyLengthRule struct {
+	min int
+}
+
+func (r bodyLengthRule) check(note *Note) []string {
+	length := len([]rune(strings.TrimSpace(note.Body)))
+	if length < r.min {
+		return []string{fmt.Sprintf("body is %d characters, expected at least %d", length, r.min)}
+	}
+	return nil
+}
+
+// legacyTags are tags written by old hermes versions for unknown values
+var legacyTags = map[string]bool{
+	"rating/0": true,
+	"year/0s":  true,
+}
+
+// legacyEmptyFields are fields old hermes versions wrote as empty strings instead of leaving them out
+var legacyEmptyFields = []string{"cover", "url", "original_title", "date_rated", "date_read", "date_added"}
+
+// legacyValuesRule reports the placeholder values old hermes versions wrote for unknown values
+type legacyValuesRule struct{}
+
+func (r legacyValuesRule) check(note *Note) []string {
+	var problems []string
+	for _, tag := range note.Frontmatter.GetStrings("tags") {
+		if legacyTags[strings.TrimPrefix(tag, "#")] {
+			problems = append(problems, fmt.Sprintf("legacy tag %s", tag))
+		}
+	}
+	for _, field := range legacyEmptyFields {
+		if isEmptyString(note.Frontmatter.Get(field)) {
+			problems = append(problems, fmt.Sprintf("empty %s", field))
+		}
+	}
+	return problems
+}
+
+func (r legacyValuesRule) fix(note *Note) (bool, error) {
+	changed := false
+
+	tags := note.Frontmatter.GetStrings("tags")
+	var kept []string
+	for _, tag := range tags {
+		if !legacyTags[strings.TrimPrefix(tag, "#")] {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) != len(tags) {
+		changed = true
+		if len(kept) == 0 {
+			note.Frontmatter.Delete("tags")
+		} else if err := note.Frontmatter.Set("tags", kept); err != nil {
+			return false, err
+		}
+	}
+
+	for _, field := range legacyEmptyFields {
+		if isEmptyString(note.Frontmatter.Get(field)) {
+			note.Frontmatter.Delete(field)
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// isEmptyString returns true for an explicitly empty string like cover: "", a key without a value is null and kept
+func isEmptyString(value *yaml.Node) bool {
+	return value != nil && value.Kind == yaml.ScalarNode && value.Tag == "!!str" && value.Value == ""
+}
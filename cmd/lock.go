@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// vaultLockName is the advisory lock file in MarkdownOutputDir held while hermes writes notes.
+// Sync tools like Obsidian Sync skip hidden files.
+const vaultLockName = ".hermes.lock"
+
+// vaultLockStale is how old a lock of another computer must be to be taken over, its process
+// can't be checked
+const vaultLockStale = time.Hour
+
+// vaultLockInfo is the content of the lock file
+type vaultLockInfo struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+// vaultLock is the lock file held by this run, empty until the first note is written
+var vaultLock string
+
+// lockVault takes the vault lock before the first note of the run is written, exiting if another
+// hermes run holds it
+func lockVault() {
+	if vaultLock != "" {
+		return
+	}
+
+	path := filepath.Join(viper.GetString("MarkdownOutputDir"), vaultLockName)
+	host, _ := os.Hostname()
+	data, err := json.Marshal(vaultLockInfo{PID: os.Getpid(), Host: host, Started: time.Now()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.Write(data)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				log.Fatalf("Error writing lock file %s: %v\n", path, err)
+			}
+			vaultLock = path
+			return
+		}
+		if !errors.Is(err, os.ErrExist) {
+			log.Fatalf("Error creating lock file %s: %v\n", path, err)
+		}
+
+		holder, ok := readVaultLock(path)
+		if ok && !holder.stale(host) {
+			log.Fatalf("Another hermes run (pid %d on %s, started %s) is writing to the vault, remove %s if it isn't running\n",
+				holder.PID, holder.Host, holder.Started.Format(time.DateTime), path)
+		}
+		log.WithField("Path", path).Warn("Removing stale lock file")
+		os.Remove(path)
+	}
+	log.Fatalf("Could not take the lock file %s\n", path)
+}
+
+// readVaultLock reads the lock file, false if it can't be read
+func readVaultLock(path string) (vaultLockInfo, bool) {
+	var info vaultLockInfo
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		return info, false
+	}
+	return info, true
+}
+
+// stale returns true if the process holding the lock has exited
+func (l vaultLockInfo) stale(host string) bool {
+	if l.Host != host {
+		return time.Since(l.Started) > vaultLockStale
+	}
+	process, err := os.FindProcess(l.PID)
+	if err != nil {
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err != nil && !errors.Is(err, os.ErrPermission)
+}
+
+// unlockVault removes the lock file of the run
+func unlockVault() {
+	if vaultLock == "" {
+		return
+	}
+	if err := os.Remove(vaultLock); err != nil {
+		log.Warnf("Error removing lock file: %v\n", err)
+	}
+	vaultLock = ""
+}
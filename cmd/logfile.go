@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// consoleFormatter drops the entries above the console level, the logger itself
+// runs at debug level so the run log file gets everything
+type consoleFormatter struct {
+	log.Formatter
+	level log.Level
+}
+
+func (f consoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if entry.Level > f.level {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// fileHook writes every log entry to the run log file
+type fileHook struct {
+	file      *os.File
+	formatter log.Formatter
+}
+
+func (h *fileHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *fileHook) Fire(entry *log.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.file.Write(line)
+	return err
+}
+
+// runLogFile is the debug log of the current run, nil if logging to a file is disabled
+var runLogFile *fileHook
+
+// startRunLogFile starts writing a debug level log of the run to LogDir/<timestamp>.log
+func startRunLogFile() {
+	directory := viper.GetString("LogDir")
+	if directory == "" || runLogFile != nil || importDryRun {
+		return
+	}
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		log.Warnf("Error creating log directory: %v\n", err)
+		return
+	}
+	path := filepath.Join(directory, time.Now().Format("20060102-150405")+".log")
+	file, err := os.Create(path)
+	if err != nil {
+		log.Warnf("Error creating log file: %v\n", err)
+		return
+	}
+
+	runLogFile = &fileHook{
+		file:      file,
+		formatter: &log.TextFormatter{DisableColors: true, FullTimestamp: true},
+	}
+	log.AddHook(runLogFile)
+	log.SetFormatter(consoleFormatter{Formatter: log.StandardLogger().Formatter, level: log.GetLevel()})
+	log.SetLevel(log.DebugLevel)
+}
+
+// stopRunLogFile references the log file in the run summary and closes it
+func stopRunLogFile() {
+	if runLogFile == nil {
+		return
+	}
+	summaryf("Detailed log written to %s\n", runLogFile.file.Name())
+	runLogFile.file.Close()
+}
@@ -0,0 +1,457 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// MalEntry is an anime or manga from a MyAnimeList export
+type MalEntry struct {
+	Kind         string   `json:"Kind"` // anime or manga
+	MalId        int      `json:"MalId"`
+	AniListId    int      `json:"AniList Id,omitempty"`
+	Title        string   `json:"Title"`
+	EnglishTitle string   `json:"English Title"`
+	Format       string   `json:"Format"`
+	Episodes     int      `json:"Episodes"` // episodes of an anime, chapters of a manga
+	Progress     int      `json:"Progress"` // watched episodes or read chapters
+	VolumesRead  int      `json:"Volumes Read"`
+	Score        int      `json:"Score"`
+	Status       string   `json:"Status"`
+	StartDate    string   `json:"Start Date"`
+	FinishDate   string   `json:"Finish Date"`
+	Year         int      `json:"Year"`
+	Genres       []string `json:"Genres"`
+	Description  string   `json:"Description"`
+	CoverURL     string   `json:"Cover URL"`
+}
+
+// malExport is the XML export of an anime or manga list, the lists are exported separately
+type malExport struct {
+	Anime []struct {
+		ID              int    `xml:"series_animedb_id"`
+		Title           string `xml:"series_title"`
+		Type            string `xml:"series_type"`
+		Episodes        int    `xml:"series_episodes"`
+		WatchedEpisodes int    `xml:"my_watched_episodes"`
+		StartDate       string `xml:"my_start_date"`
+		FinishDate      string `xml:"my_finish_date"`
+		Score           int    `xml:"my_score"`
+		Status          string `xml:"my_status"`
+	} `xml:"anime"`
+	Manga []struct {
+		ID           int    `xml:"manga_mangadb_id"`
+		Title        string `xml:"manga_title"`
+		Chapters     int    `xml:"manga_chapters"`
+		ReadChapters int    `xml:"my_read_chapters"`
+		ReadVolumes  int    `xml:"my_read_volumes"`
+		StartDate    string `xml:"my_start_date"`
+		FinishDate   string `xml:"my_finish_date"`
+		Score        int    `xml:"my_score"`
+		Status       string `xml:"my_status"`
+	} `xml:"manga"`
+}
+
+// malCmd represents the mal command
+var malCmd = &cobra.Command{
+	Use:   "mal [file...]",
+	Short: "Parse MyAnimeList anime and manga list exports",
+	Long: `Parse the XML exports of MyAnimeList anime and manga lists and write one note per series.
+The exports can be given as downloaded (.xml.gz) or uncompressed, the default file is animelist.xml.
+
+Series are enriched with genres, description and cover from the AniList API,
+responses are cached in CacheDir.`,
+	ValidArgsFunction: completeFiles("xml"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing MyAnimeList export...")
+		if len(args) == 0 {
+			args = []string{"animelist.xml"}
+		}
+		parse_mal(args)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(malCmd)
+}
+
+func parse_mal(filenames []string) {
+	var entries []MalEntry
+	for _, filename := range filenames {
+		fileEntries, err := readMalFile(filename)
+		if err != nil {
+			log.Errorf("Error reading %s: %v\n", filename, err)
+			continue
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(entries); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d anime and manga\n", len(entries))
+		return
+	}
+
+	if err := writeMalEntriesToJson(entries); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err := writeMalEntriesToMarkdown(entries)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d anime and manga\n", len(entries))
+}
+
+// readMalFile reads a single export file, enriching the entries from AniList
+func readMalFile(filename string) ([]MalEntry, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	if importJSONIn {
+		return readJSONLines[MalEntry](input)
+	}
+
+	// MyAnimeList serves the exports gzipped
+	reader := bufio.NewReader(input)
+	var r io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var export malExport
+	if err := xml.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+
+	var entries []MalEntry
+	for _, anime := range export.Anime {
+		entries = append(entries, MalEntry{
+			Kind:       "anime",
+			MalId:      anime.ID,
+			Title:      anime.Title,
+			Format:     anime.Type,
+			Episodes:   anime.Episodes,
+			Progress:   anime.WatchedEpisodes,
+			Score:      anime.Score,
+			Status:     anime.Status,
+			StartDate:  malDate(anime.StartDate),
+			FinishDate: malDate(anime.FinishDate),
+		})
+	}
+	for _, manga := range export.Manga {
+		entries = append(entries, MalEntry{
+			Kind:        "manga",
+			MalId:       manga.ID,
+			Title:       manga.Title,
+			Episodes:    manga.Chapters,
+			Progress:    manga.ReadChapters,
+			VolumesRead: manga.ReadVolumes,
+			Score:       manga.Score,
+			Status:      manga.Status,
+			StartDate:   malDate(manga.StartDate),
+			FinishDate:  malDate(manga.FinishDate),
+		})
+	}
+
+	for i := range entries {
+		err := withRetry(func() error { return enrichMalEntry(&entries[i]) })
+		if err != nil {
+			log.WithField("Title", entries[i].Title).Warnf("Error fetching AniList data: %v\n", err)
+		}
+		queueRetry("mal", malKey(entries[i]), entries[i], err)
+	}
+
+	return entries, nil
+}
+
+// malDate returns the date, MyAnimeList uses 0000-00-00 for unset dates
+func malDate(date string) string {
+	if strings.HasPrefix(date, "0000") {
+		return ""
+	}
+	return isoDate(date)
+}
+
+// malKey identifies an entry in the retry queue, anime and manga ids overlap
+func malKey(entry MalEntry) string {
+	return entry.Kind + "/" + strconv.Itoa(entry.MalId)
+}
+
+// retryMalEntry re-enriches a queued entry and rewrites its note
+func retryMalEntry(data json.RawMessage) error {
+	var entry MalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+
+	if err := withRetry(func() error { return enrichMalEntry(&entry) }); err != nil {
+		return err
+	}
+
+	_, err := writeMalEntryToMarkdown(entry, newNoteRelocator(malIdField(entry.Kind)))
+	return err
+}
+
+// aniListMedia is the subset of the AniList Media object we use
+type aniListMedia struct {
+	ID    int `json:"id"`
+	Title struct {
+		English string `json:"english"`
+	} `json:"title"`
+	Format    string `json:"format"`
+	StartDate struct {
+		Year int `json:"year"`
+	} `json:"startDate"`
+	Genres      []string `json:"genres"`
+	Description string   `json:"description"`
+	CoverImage  struct {
+		Large string `json:"large"`
+	} `json:"coverImage"`
+}
+
+const aniListQuery = `query ($id: Int, $type: MediaType) {
+  Media(idMal: $id, type: $type) {
+    id
+    title { english }
+    format
+    startDate { year }
+    genres
+    description(asHtml: false)
+    coverImage { large }
+  }
+}`
+
+// aniListLineBreaks converts the HTML line breaks AniList leaves in plain text descriptions
+var aniListLineBreaks = strings.NewReplacer("<br>\n", "\n", "<br>", "\n")
+
+// enrichMalEntry fills in metadata from AniList, which can be queried with MyAnimeList ids
+func enrichMalEntry(entry *MalEntry) error {
+	var media aniListMedia
+	key := malKey(*entry)
+	if !readCache("anilist", strings.ReplaceAll(key, "/", "-"), &media) {
+		found, err := fetchAniListMedia(entry.MalId, strings.ToUpper(entry.Kind))
+		if err != nil {
+			return err
+		}
+		// Misses are cached as an empty media so they aren't looked up on every run
+		if found != nil {
+			media = *found
+		}
+		if err := writeCache("anilist", strings.ReplaceAll(key, "/", "-"), media); err != nil {
+			log.Warnf("Error caching AniList media %s: %v\n", key, err)
+		}
+	}
+
+	if media.Title.English != "" && media.Title.English != entry.Title {
+		entry.EnglishTitle = media.Title.English
+	}
+	if entry.Format == "" {
+		entry.Format = media.Format
+	}
+	entry.AniListId = media.ID
+	entry.Year = media.StartDate.Year
+	entry.Genres = media.Genres
+	entry.Description = strings.TrimSpace(aniListLineBreaks.Replace(media.Description))
+	entry.CoverURL = media.CoverImage.Large
+
+	return nil
+}
+
+// fetchAniListMedia queries AniList by MyAnimeList id, returns nil if AniList doesn't know the series
+func fetchAniListMedia(malId int, mediaType string) (*aniListMedia, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     aniListQuery,
+		"variables": map[string]interface{}{"id": malId, "type": mediaType},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post("https://graphql.anilist.co", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// AniList answers 404 for ids it doesn't have
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var response struct {
+		Data struct {
+			Media *aniListMedia `json:"Media"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Data.Media, nil
+}
+
+func writeMalEntriesToJson(entries []MalEntry) error {
+	jsonData, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("mal.json", jsonData)
+}
+
+// malIdField is the frontmatter field with the MyAnimeList id of a kind, anime and manga ids overlap
+func malIdField(kind string) string {
+	return "mal_" + kind + "_id"
+}
+
+// writeMalEntryToMarkdown writes anime or manga info to a markdown file
+func writeMalEntryToMarkdown(entry MalEntry, relocator *noteRelocator) (string, error) {
+	id := strconv.Itoa(entry.MalId)
+	// Titles are romanized originals, the English title is the localized one
+	preference := relocator.titlePreference(id)
+	name := preferredTitle(preference, entry.EnglishTitle, entry.Title, titleOriginal)
+
+	filePath, err := notePath(entry.Kind, map[string]string{
+		"title":  name,
+		"year":   yearString(entry.Year),
+		"decade": decade(entry.Year),
+		"format": entry.Format,
+		"status": entry.Status,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(id, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{entry.Kind}
+	if entry.Status != "" {
+		tags = append(tags, entry.Kind+"/"+slugify(entry.Status))
+	}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", name)
+	if entry.EnglishTitle != "" {
+		frontmatter.Set("english_title", entry.EnglishTitle)
+	}
+	if preference != "" {
+		frontmatter.Set("title_preference", preference)
+	}
+	if aliases := noteAliases(filePath, entry.Title, entry.EnglishTitle); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set(malIdField(entry.Kind), id)
+	if entry.AniListId > 0 {
+		frontmatter.Set("anilist_id", strconv.Itoa(entry.AniListId))
+	}
+	frontmatter.Set("url", fmt.Sprintf("https://myanimelist.net/%s/%s", entry.Kind, id))
+	if entry.Year > 0 {
+		frontmatter.Set("year", entry.Year)
+	}
+	if entry.Format != "" {
+		frontmatter.Set("format", entry.Format)
+	}
+	if entry.Score > 0 {
+		frontmatter.Set("my_rating", entry.Score)
+	}
+	frontmatter.Set("status", entry.Status)
+	if entry.Kind == "anime" {
+		if entry.Episodes > 0 {
+			frontmatter.Set("episodes", entry.Episodes)
+		}
+		frontmatter.Set("episodes_watched", entry.Progress)
+	} else {
+		if entry.Episodes > 0 {
+			frontmatter.Set("chapters", entry.Episodes)
+		}
+		frontmatter.Set("chapters_read", entry.Progress)
+		frontmatter.Set("volumes_read", entry.VolumesRead)
+	}
+	if entry.StartDate != "" {
+		frontmatter.Set("date_started", entry.StartDate)
+	}
+	if entry.FinishDate != "" {
+		frontmatter.Set("date_finished", entry.FinishDate)
+	}
+	if len(entry.Genres) > 0 {
+		frontmatter.Set("genres", entry.Genres)
+	}
+	if entry.CoverURL != "" {
+		frontmatter.Set("cover", entry.CoverURL)
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if entry.CoverURL != "" {
+		body += fmt.Sprintf("![](%s)\n\n", entry.CoverURL)
+	}
+	if entry.Description != "" {
+		body += entry.Description + "\n"
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(id, note)
+	return filePath, note.Write()
+}
+
+// writeMalEntriesToMarkdown writes the anime and manga to markdown files, each with their own index note
+func writeMalEntriesToMarkdown(entries []MalEntry) error {
+	for _, kind := range []string{"anime", "manga"} {
+		relocator := newNoteRelocator(malIdField(kind))
+		var indexEntries []indexEntry
+		for _, entry := range entries {
+			if entry.Kind != kind {
+				continue
+			}
+			path, err := writeMalEntryToMarkdown(entry, relocator)
+			if skipNoteConflict(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			indexEntries = append(indexEntries, indexEntry{Path: path, Title: entry.Title, Year: entry.Year, Rating: float64(entry.Score)})
+		}
+		if len(indexEntries) == 0 {
+			continue
+		}
+		if err := writeIndexNote(kind, indexEntries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
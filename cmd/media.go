@@ -0,0 +1,307 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lepinkainen/hermes/internal/titlematch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/text/language"
+)
+
+var mediaNotesDir string
+
+// mediaFile is what ffprobe found in a video file
+type mediaFile struct {
+	Path      string
+	Width     int
+	Audio     []string
+	Subtitles []string
+}
+
+var (
+	videoExtensions    = map[string]bool{".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true, ".webm": true, ".wmv": true, ".ts": true}
+	subtitleExtensions = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".sub": true, ".vtt": true}
+)
+
+// mediaCmd represents the media command
+var mediaCmd = &cobra.Command{
+	Use:   "media <directory>",
+	Short: "Record the languages and resolution of your media files in the movie notes",
+	Long: `Scan a media directory, like a Plex library, for video files and match them to movie notes
+by title and year from the file or folder name ("The Matrix (1999).mkv",
+"The.Matrix.1999.1080p.BluRay.mkv"). The audio and subtitle languages and the resolution
+found by ffprobe are written to the note frontmatter:
+
+  resolution: 1080p
+  audio_languages: [en, fi]
+  subtitle_languages: [fi, sv]
+  media_files: [/media/movies/The Matrix (1999)/The Matrix (1999).mkv]
+
+Subtitle files next to the video, like "The Matrix (1999).fi.srt", count as subtitles. Importers
+keep the fields when they rewrite the notes.
+Requires ffprobe from FFmpeg in the PATH.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDirs,
+	Run: func(cmd *cobra.Command, args []string) {
+		scanMedia(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mediaCmd)
+
+	mediaCmd.Flags().StringVarP(&mediaNotesDir, "dir", "d", "", "Directory with movie notes (default MarkdownOutputDir)")
+	mediaCmd.MarkFlagDirname("dir")
+}
+
+func scanMedia(directory string) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		log.Error("ffprobe not found, install FFmpeg\n")
+		return
+	}
+	if mediaNotesDir == "" {
+		mediaNotesDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	index, err := indexMovieNotes(mediaNotesDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", mediaNotesDir, err)
+		return
+	}
+
+	var videos []string
+	subtitles := make(map[string][]string)
+	err = filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		switch {
+		case videoExtensions[ext] && !strings.Contains(strings.ToLower(d.Name()), "sample"):
+			videos = append(videos, path)
+		case subtitleExtensions[ext]:
+			subtitles[filepath.Dir(path)] = append(subtitles[filepath.Dir(path)], path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Error scanning %s: %v\n", directory, err)
+		return
+	}
+
+	// Files of the same movie, like 4K and 1080p versions, are combined
+	files := make(map[string][]mediaFile)
+	unmatched := 0
+	for _, path := range videos {
+		title, year := parseMediaName(path)
+		notePath := index.find(title, year)
+		if notePath == "" {
+			log.WithField("Path", path).Debug("No note for media file")
+			unmatched++
+			continue
+		}
+
+		file, err := probeMediaFile(path)
+		if err != nil {
+			log.WithField("Path", path).Warnf("Error probing media file: %v\n", err)
+			continue
+		}
+		file.Subtitles = append(file.Subtitles, externalSubtitleLanguages(path, subtitles[filepath.Dir(path)])...)
+		files[notePath] = append(files[notePath], file)
+	}
+
+	updated := 0
+	for notePath, noteFiles := range files {
+		note, err := readNote(notePath)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", notePath, err)
+			continue
+		}
+
+		var paths, audio, subs []string
+		width := 0
+		for _, file := range noteFiles {
+			// A file with a year only matches a note of the same year, remakes share titles
+			_, year := parseMediaName(file.Path)
+			if noteYear := note.Frontmatter.GetInt("year"); year > 0 && noteYear > 0 && noteYear != year {
+				log.WithField("Path", file.Path).Debugf("Year doesn't match %s\n", notePath)
+				unmatched++
+				continue
+			}
+			paths = append(paths, file.Path)
+			audio = appendMissing(audio, file.Audio...)
+			subs = appendMissing(subs, file.Subtitles...)
+			if file.Width > width {
+				width = file.Width
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		sort.Strings(audio)
+		sort.Strings(subs)
+
+		if width > 0 {
+			note.Frontmatter.Set("resolution", mediaResolution(width))
+		}
+		note.Frontmatter.Set("audio_languages", audio)
+		note.Frontmatter.Set("subtitle_languages", subs)
+		note.Frontmatter.Set("media_files", paths)
+		if err := note.Write(); err != nil {
+			log.Errorf("Error writing %s: %v\n", notePath, err)
+			continue
+		}
+		updated++
+	}
+
+	summaryf("Matched %d media files to %d notes, %d unmatched\n", len(videos)-unmatched, updated, unmatched)
+}
+
+// parseMediaName returns the title and year of a video from its file name, or its folder if the
+// file name has no year
+func parseMediaName(path string) (string, int) {
+	for _, name := range []string{
+		strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		filepath.Base(filepath.Dir(path)),
+	} {
+		if title, year, ok := titlematch.SplitYear(name); ok {
+			return cleanMediaTitle(title), year
+		}
+	}
+	return cleanMediaTitle(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))), 0
+}
+
+// cleanMediaTitle turns the dots and underscores of release names into spaces
+func cleanMediaTitle(title string) string {
+	if !strings.Contains(title, " ") {
+		title = strings.NewReplacer(".", " ", "_", " ").Replace(title)
+	}
+	return strings.TrimSpace(title)
+}
+
+// probeMediaFile reads the video width and audio and subtitle languages of a file with ffprobe
+func probeMediaFile(path string) (mediaFile, error) {
+	file := mediaFile{Path: path}
+
+	output, err := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_streams", path).Output()
+	if err != nil {
+		return file, err
+	}
+
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			Width     int    `json:"width"`
+			Tags      struct {
+				Language string `json:"language"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return file, err
+	}
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if stream.Width > file.Width {
+				file.Width = stream.Width
+			}
+		case "audio":
+			if lang := languageCode(stream.Tags.Language); lang != "" {
+				file.Audio = appendMissing(file.Audio, lang)
+			}
+		case "subtitle":
+			if lang := languageCode(stream.Tags.Language); lang != "" {
+				file.Subtitles = appendMissing(file.Subtitles, lang)
+			}
+		}
+	}
+
+	return file, nil
+}
+
+// externalSubtitleLanguages returns the languages of subtitle files named after the video, like movie.fi.srt
+func externalSubtitleLanguages(video string, subtitles []string) []string {
+	base := strings.TrimSuffix(filepath.Base(video), filepath.Ext(video))
+
+	var languages []string
+	for _, subtitle := range subtitles {
+		name := strings.TrimSuffix(filepath.Base(subtitle), filepath.Ext(subtitle))
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		// Flags like movie.fi.forced.srt follow the language
+		for _, part := range strings.Split(strings.TrimPrefix(name, base+"."), ".") {
+			if lang := languageCode(part); lang != "" {
+				languages = appendMissing(languages, lang)
+				break
+			}
+		}
+	}
+	return languages
+}
+
+// languagesWithoutISO6391 are the ISO 639-2 languages without a two letter code that films have
+// tracks in. Other three letter codes are rarely languages, sdh in movie.en.sdh.srt is a flag.
+var languagesWithoutISO6391 = map[string]bool{
+	"fil": true, "gsw": true, "haw": true, "hmn": true, "nds": true, "tlh": true,
+}
+
+// languageCode normalizes ISO 639-2 codes of containers (fin, fre) and 639-1 codes (fi) to the
+// shortest form, empty for undetermined, unknown and special codes like mul and zxx
+func languageCode(code string) string {
+	if code == "" || strings.EqualFold(code, "und") {
+		return ""
+	}
+	tag, err := language.Parse(code)
+	if err != nil {
+		return ""
+	}
+	base, confidence := tag.Base()
+	if confidence == language.No {
+		return ""
+	}
+	// Languages in ISO 639-1 have a two letter code
+	if len(base.String()) != 2 && !languagesWithoutISO6391[base.String()] {
+		return ""
+	}
+	return base.String()
+}
+
+// mediaResolution names the resolution by width, wide films have fewer lines than their resolution
+func mediaResolution(width int) string {
+	switch {
+	case width >= 3800:
+		return "2160p"
+	case width >= 1900:
+		return "1080p"
+	case width >= 1200:
+		return "720p"
+	default:
+		return "sd"
+	}
+}
+
+// appendMissing appends the values not yet in the list
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !containsString(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import "testing"
+
+func TestLanguageCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"fi", "fi"},
+		{"fin", "fi"},
+		{"eng", "en"},
+		{"fre", "fr"},
+		{"ger", "de"},
+		{"FIN", "fi"},
+		{"fil", "fil"},
+		{"und", ""},
+		{"", ""},
+		{"mul", ""},
+		{"zxx", ""},
+		{"sdh", ""},
+		{"forced", ""},
+		{"cc", ""},
+	}
+
+	for _, tt := range tests {
+		if got := languageCode(tt.code); got != tt.want {
+			t.Errorf("languageCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestExternalSubtitleLanguages(t *testing.T) {
+	got := externalSubtitleLanguages("/media/Heat (1995).mkv", []string{
+		"/media/Heat (1995).fi.srt",
+		"/media/Heat (1995).sdh.en.srt",
+		"/media/Heat (1995).sv.forced.srt",
+		"/media/Heat (1995).srt",
+		"/media/Heat (1986).de.srt",
+	})
+	want := []string{"fi", "en", "sv"}
+	if len(got) != len(want) {
+		t.Fatalf("externalSubtitleLanguages() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("externalSubtitleLanguages() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mediaIDFields are the flat id fields of the notes and their key in the ids mapping, in the
+// order they are written. Fields not listed use the field name without the _id suffix as the key.
+var mediaIDFields = []struct {
+	field string
+	key   string
+}{
+	{"imdb_id", "imdb"},
+	{tmdbIdField("movie"), "tmdb_movie"},
+	{tmdbIdField("tv"), "tmdb_tv"},
+	{traktIdField("movie"), "trakt_movie"},
+	{traktIdField("show"), "trakt_show"},
+	{malIdField("anime"), "mal_anime"},
+	{malIdField("manga"), "mal_manga"},
+	{"anilist_id", "anilist"},
+	{"goodreads_id", "goodreads"},
+	{"comicvine_id", "comicvine"},
+	{"steam_appid", "steam"},
+	{"gog_release_key", "gog"},
+	{consoleIdField("nintendo"), "nintendo"},
+	{consoleIdField("psn"), "psn"},
+	{"igdb_id", "igdb"},
+	{"bgg_id", "bgg"},
+}
+
+// mediaIDKey returns the key of an id field in the ids mapping, imdb_id is ids.imdb
+func mediaIDKey(idField string) string {
+	for _, id := range mediaIDFields {
+		if id.field == idField {
+			return id.key
+		}
+	}
+	return strings.TrimSuffix(idField, "_id")
+}
+
+// setMediaIDs sets the ids mapping of a note to the id fields it has: the known mediaIDFields and
+// idField, the id field of the source writing the note. The flat fields stay for older notes and
+// queries, the mapping gives every note the ids in the same place.
+func setMediaIDs(f *Frontmatter, idField string) {
+	ids := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	add := func(field string) {
+		value := f.Get(field)
+		if value == nil || value.Kind != yaml.ScalarNode || value.Value == "" {
+			return
+		}
+		id := *value
+		id.HeadComment, id.LineComment, id.FootComment = "", "", ""
+		ids.Content = append(ids.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: mediaIDKey(field)}, &id)
+	}
+
+	known := false
+	for _, id := range mediaIDFields {
+		add(id.field)
+		known = known || id.field == idField
+	}
+	if !known {
+		add(idField)
+	}
+
+	if len(ids.Content) > 0 {
+		f.Set("ids", ids)
+	}
+}
+
+// noteID returns the value of an id field of a note, from the flat field of older notes or the
+// ids mapping, empty if the note has neither
+func noteID(f *Frontmatter, idField string) string {
+	if id := f.GetString(idField); id != "" {
+		return id
+	}
+
+	ids := f.Get("ids")
+	if ids == nil || ids.Kind != yaml.MappingNode {
+		return ""
+	}
+	key := mediaIDKey(idField)
+	for i := 0; i+1 < len(ids.Content); i += 2 {
+		if ids.Content[i].Value == key && ids.Content[i+1].Kind == yaml.ScalarNode {
+			return ids.Content[i+1].Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNoteID(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		field   string
+		want    string
+	}{
+		{"flat", "title: Heat\nimdb_id: tt0113277\n", "imdb_id", "tt0113277"},
+		{"ids", "title: Heat\nids:\n  imdb: tt0113277\n", "imdb_id", "tt0113277"},
+		{"steam", "title: Portal\nids:\n  steam: 400\n", "steam_appid", "400"},
+		{"other media type", "title: Heat\nids:\n  tmdb_movie: \"949\"\n", tmdbIdField("tv"), ""},
+		{"unknown field", "title: Outer Wilds\nids:\n  backloggd: \"42\"\n", "backloggd_id", "42"},
+		{"missing", "title: Heat\n", "imdb_id", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontmatter, err := parseFrontmatter(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := noteID(frontmatter, tt.field); got != tt.want {
+				t.Errorf("noteID(%s) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritersSetMediaIDs(t *testing.T) {
+	dir := testVault(t, nil)
+
+	if err := newMovieNoteWriter().write(MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeBookToMarkdown(Book{ID: 234225, Title: "Dune", OriginalPublicationYear: 1965}, newNoteRelocator("goodreads_id")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeComicToMarkdown(Comic{Title: "Saga", ComicVineId: "4050-45395"}, newNoteRelocator("comicvine_id")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		field string
+		key   string
+		want  string
+	}{
+		{"imdb/Heat (1995).md", "imdb_id", "imdb", "tt0113277"},
+		{"goodreads/Dune (1965).md", "goodreads_id", "goodreads", "234225"},
+		{"comics/Saga.md", "comicvine_id", "comicvine", "4050-45395"},
+	}
+	for _, tt := range tests {
+		note, err := readNote(filepath.Join(dir, tt.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]string)
+		if err := note.Frontmatter.Decode("ids", &ids); err != nil {
+			t.Fatalf("%s ids: %v", tt.path, err)
+		}
+		if ids[tt.key] != tt.want {
+			t.Errorf("%s ids = %v, want %s: %s", tt.path, ids, tt.key, tt.want)
+		}
+		if got := note.Frontmatter.GetString(tt.field); got != tt.want {
+			t.Errorf("%s %s = %q, want the flat field kept", tt.path, tt.field, got)
+		}
+	}
+}
+
+func TestRelocatorFindsIDsMapping(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"movies/Heat.md": "---\ntitle: Heat\nids:\n  imdb: tt0113277\nthoughts: Still great\n---\n",
+	})
+
+	if err := newMovieNoteWriter().write(MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "movies/Heat.md")); !os.IsNotExist(err) {
+		t.Errorf("the note with the ids mapping wasn't moved: %v", err)
+	}
+	note, err := readNote(filepath.Join(dir, "imdb/Heat (1995).md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := note.Frontmatter.GetString("thoughts"); got != "Still great" {
+		t.Errorf("thoughts = %q, want them kept from the moved note", got)
+	}
+}
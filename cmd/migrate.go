@@ -0,0 +1,335 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	migrateCSV     string
+	migrateMapFile string
+)
+
+// MigrateMapping maps the columns of a database export to note frontmatter
+type MigrateMapping struct {
+	// Title is the column holding the note title, the first column if unset
+	Title string `yaml:"title"`
+	// ID is the column with a unique id of the row, like the Notion page URL or the Airtable record
+	// id. Without it rows are identified by the title and year.
+	ID string `yaml:"id"`
+	// Body is the column written as the note body
+	Body string `yaml:"body"`
+	// Fields maps columns to frontmatter fields, all columns are copied if unset
+	Fields map[string]string `yaml:"fields"`
+	// Lists are the columns split on commas into lists, like multi-selects
+	Lists []string `yaml:"lists"`
+	// Tags maps columns to tag prefixes, each value becomes a <prefix>/<value> tag
+	Tags map[string]string `yaml:"tags"`
+	// ExtraTags are added to every note
+	ExtraTags []string `yaml:"extra_tags"`
+}
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Convert notes from other systems into hermes notes",
+}
+
+// migrateNotionCmd represents the migrate notion command
+var migrateNotionCmd = &cobra.Command{
+	Use:     "notion",
+	Aliases: []string{"airtable"},
+	Short:   "Convert a Notion or Airtable database CSV export into notes",
+	Long: `Convert a Notion or Airtable media database exported as CSV into notes, one per row.
+
+The mapping file picks the columns:
+
+  title: Name
+  id: Record ID
+  body: Notes
+  fields:
+    Year: year
+    My Rating: my_rating
+    Genre: genres
+  lists: [Genre]
+  tags:
+    Type: media
+  extra_tags: [notion]
+
+Without a mapping the first column is the title and every other column is copied to a
+frontmatter field named after it. Numbers, Yes/No checkboxes and dates are converted to
+YAML numbers, booleans and YYYY-MM-DD dates.
+
+Notes are written with the notion (or airtable) path template, which can use {{title}},
+{{year}} and {{decade}} when a column is mapped to the year field. The id column, or the title
+and year, is written as notion_id (airtable_id) so running the migration again updates the same
+notes. Rows that would overwrite the note of another row are skipped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrateDatabase(cmd.CalledAs())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateNotionCmd)
+
+	migrateNotionCmd.Flags().StringVar(&migrateCSV, "csv", "", "CSV export of the database")
+	migrateNotionCmd.Flags().StringVar(&migrateMapFile, "map", "", "YAML file mapping columns to frontmatter")
+	migrateNotionCmd.MarkFlagFilename("csv", "csv")
+	migrateNotionCmd.MarkFlagFilename("map", "yaml", "yml")
+	migrateNotionCmd.MarkFlagRequired("csv")
+}
+
+func migrateDatabase(source string) {
+	var mapping MigrateMapping
+	if migrateMapFile != "" {
+		data, err := os.ReadFile(migrateMapFile)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := yaml.Unmarshal(data, &mapping); err != nil {
+			log.Errorf("Error reading mapping %s: %v\n", migrateMapFile, err)
+			return
+		}
+	}
+
+	input, err := openInput(migrateCSV)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		log.Errorf("Error reading %s: %v\n", migrateCSV, err)
+		return
+	}
+	if len(records) == 0 {
+		log.Errorf("%s is empty\n", migrateCSV)
+		return
+	}
+
+	// Notion exports start with a byte order mark
+	header := records[0]
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	if mapping.Title == "" {
+		mapping.Title = header[0]
+	}
+	if mapping.Fields == nil {
+		mapping.Fields = make(map[string]string)
+		for _, column := range header {
+			if column != mapping.Title && column != mapping.Body && column != mapping.ID {
+				mapping.Fields[column] = migrateFieldName(column)
+			}
+		}
+	}
+
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[column] = i
+	}
+	required := append(migrateMappedColumns(mapping), mapping.Title)
+	if mapping.ID != "" {
+		required = append(required, mapping.ID)
+	}
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			log.Errorf("Column %q is not in %s\n", column, migrateCSV)
+			return
+		}
+	}
+
+	relocator := newNoteRelocator(migrateIdField(source))
+	seen := make(map[string]bool)
+	written := 0
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for column, i := range columns {
+			if i < len(record) {
+				row[column] = strings.TrimSpace(record[i])
+			}
+		}
+		if row[mapping.Title] == "" {
+			continue
+		}
+
+		id := migrateRowID(row, mapping)
+		if seen[id] {
+			log.WithField("Title", row[mapping.Title]).Warnf("Skipping duplicate row %s\n", id)
+			continue
+		}
+		seen[id] = true
+
+		err := writeMigratedNote(source, id, row, mapping, relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			log.WithField("Title", row[mapping.Title]).Errorf("Error writing note: %v\n", err)
+			continue
+		}
+		written++
+	}
+
+	summaryf("Migrated %d of %d rows\n", written, len(records)-1)
+}
+
+// migrateIdField is the frontmatter field with the row id of a source, e.g. notion_id
+func migrateIdField(source string) string {
+	return source + "_id"
+}
+
+// migrateRowID returns the id of a row, the title and year without an id column
+func migrateRowID(row map[string]string, mapping MigrateMapping) string {
+	if mapping.ID != "" && row[mapping.ID] != "" {
+		return row[mapping.ID]
+	}
+	for column, field := range mapping.Fields {
+		if field == "year" && row[column] != "" {
+			return row[mapping.Title] + " (" + row[column] + ")"
+		}
+	}
+	return row[mapping.Title]
+}
+
+// writeMigratedNote writes a database row as a note
+func writeMigratedNote(source, id string, row map[string]string, mapping MigrateMapping, relocator *noteRelocator) error {
+	lists := make(map[string]bool)
+	for _, column := range mapping.Lists {
+		lists[column] = true
+	}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", row[mapping.Title])
+	frontmatter.Set(migrateIdField(source), id)
+
+	// Sorted so the fields are written in a stable order
+	fieldColumns := make([]string, 0, len(mapping.Fields))
+	for column := range mapping.Fields {
+		fieldColumns = append(fieldColumns, column)
+	}
+	sort.Strings(fieldColumns)
+
+	year := 0
+	for _, column := range fieldColumns {
+		value := row[column]
+		if value == "" {
+			continue
+		}
+		field := mapping.Fields[column]
+		if lists[column] {
+			frontmatter.Set(field, splitList(value))
+			continue
+		}
+		converted := migrateValue(value)
+		if field == "year" {
+			year, _ = converted.(int)
+		}
+		frontmatter.Set(field, converted)
+	}
+
+	tags := append([]string{}, mapping.ExtraTags...)
+	tagColumns := make([]string, 0, len(mapping.Tags))
+	for column := range mapping.Tags {
+		tagColumns = append(tagColumns, column)
+	}
+	sort.Strings(tagColumns)
+	for _, column := range tagColumns {
+		for _, value := range splitList(row[column]) {
+			tags = append(tags, mapping.Tags[column]+"/"+slugify(value))
+		}
+	}
+	if len(tags) > 0 {
+		frontmatter.Set("tags", tags)
+	}
+
+	filePath, err := notePath(source, map[string]string{
+		"title":  row[mapping.Title],
+		"year":   yearString(year),
+		"decade": decade(year),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := relocator.relocate(id, filePath); err != nil {
+		return err
+	}
+
+	body := "\n"
+	if text := row[mapping.Body]; mapping.Body != "" && text != "" {
+		body += text + "\n"
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	return note.Write()
+}
+
+// migrateMappedColumns returns all columns named in the mapping
+func migrateMappedColumns(mapping MigrateMapping) []string {
+	var columns []string
+	if mapping.Body != "" {
+		columns = append(columns, mapping.Body)
+	}
+	for column := range mapping.Fields {
+		columns = append(columns, column)
+	}
+	columns = append(columns, mapping.Lists...)
+	for column := range mapping.Tags {
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// migrateFieldName turns a column name like "My Rating" into a frontmatter field like "my_rating"
+func migrateFieldName(column string) string {
+	return strings.ReplaceAll(slugify(column), "-", "_")
+}
+
+// migrateDateLayouts are the date formats of Notion and Airtable exports
+var migrateDateLayouts = []string{
+	"January 2, 2006",
+	"January 2, 2006 3:04 PM",
+	"2006-01-02",
+	"2006/01/02",
+	"1/2/2006",
+}
+
+// migrateValue converts numbers, Yes/No checkboxes and dates, other values are kept as strings.
+// Numbers that wouldn't be written back the same, like ISBNs and ids with leading zeros, stay strings.
+func migrateValue(value string) interface{} {
+	if i, err := strconv.Atoi(value); err == nil && strconv.Itoa(i) == value {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == value {
+		return f
+	}
+	switch value {
+	case "Yes", "checked":
+		return true
+	case "No", "unchecked":
+		return false
+	}
+	for _, layout := range migrateDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format("2006-01-02")
+		}
+	}
+	return value
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import "strings"
+
+// nameParticles are lowercase name prefixes that belong to the last name ("van Gogh", "de la Cruz")
+var nameParticles = map[string]bool{
+	"da": true, "das": true, "de": true, "del": true, "della": true, "den": true, "der": true,
+	"des": true, "di": true, "dos": true, "du": true, "la": true, "le": true, "st.": true,
+	"ten": true, "ter": true, "van": true, "von": true, "zu": true,
+}
+
+// nameSuffixes are generational suffixes kept at the end of the sort name
+var nameSuffixes = map[string]bool{
+	"jr.": true, "jr": true, "sr.": true, "sr": true, "ii": true, "iii": true, "iv": true,
+}
+
+// sortName converts a name to its sorting form, "Christopher Nolan" -> "Nolan, Christopher".
+// Particles stay with the last name ("Ursula K. Le Guin" -> "Le Guin, Ursula K.")
+// and names that already contain a comma are returned as-is.
+func sortName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.Contains(name, ",") {
+		return name
+	}
+
+	parts := strings.Fields(name)
+
+	suffix := ""
+	if len(parts) > 2 && nameSuffixes[strings.ToLower(parts[len(parts)-1])] {
+		suffix = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(parts) < 2 {
+		return name
+	}
+
+	// Walk back over particles, the first name is never a particle ("Van Morrison")
+	last := len(parts) - 1
+	for last > 1 && nameParticles[strings.ToLower(parts[last-1])] {
+		last--
+	}
+
+	sorted := strings.Join(parts[last:], " ") + ", " + strings.Join(parts[:last], " ")
+	if suffix != "" {
+		sorted += ", " + suffix
+	}
+
+	return sorted
+}
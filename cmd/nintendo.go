@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// nintendoPlayHistory is the play history of a Nintendo Account
+type nintendoPlayHistory struct {
+	PlayHistories []struct {
+		TitleID            string `json:"titleId"`
+		TitleName          string `json:"titleName"`
+		DeviceType         string `json:"deviceType"`
+		FirstPlayedAt      string `json:"firstPlayedAt"`
+		LastPlayedAt       string `json:"lastPlayedAt"`
+		TotalPlayedMinutes int    `json:"totalPlayedMinutes"`
+	} `json:"playHistories"`
+}
+
+// nintendoCmd represents the nintendo command
+var nintendoCmd = &cobra.Command{
+	Use:   "nintendo [file]",
+	Short: "Import Nintendo Switch play activity",
+	Long: `Parse the play history JSON of a Nintendo Account and write one note per game
+with the total playtime and the first and last played dates. The default file is play_history.json.
+
+Games are enriched with release year, developers, genres and cover from IGDB when
+IGDBClientID and IGDBClientSecret (a Twitch application) are set in the config,
+responses are cached in CacheDir.`,
+	ValidArgsFunction: completeInputFile("json"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing Nintendo play history...")
+		parse_nintendo(inputFile(args, "play_history.json"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(nintendoCmd)
+}
+
+func parse_nintendo(filename string) {
+	games, err := readNintendoFile(filename)
+	if err != nil {
+		log.Errorf("Error reading %s: %v\n", filename, err)
+		return
+	}
+	writeConsoleGames("nintendo", games)
+}
+
+// readNintendoFile reads the play history, enriching the games from IGDB
+func readNintendoFile(filename string) ([]ConsoleGame, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	if importJSONIn {
+		return readJSONLines[ConsoleGame](input)
+	}
+
+	var history nintendoPlayHistory
+	if err := json.NewDecoder(input).Decode(&history); err != nil {
+		return nil, err
+	}
+
+	var games []ConsoleGame
+	for _, played := range history.PlayHistories {
+		games = append(games, ConsoleGame{
+			Source:          "nintendo",
+			TitleID:         played.TitleID,
+			Title:           played.TitleName,
+			Platform:        "switch",
+			PlaytimeMinutes: played.TotalPlayedMinutes,
+			FirstPlayed:     consoleDate(played.FirstPlayedAt),
+			LastPlayed:      consoleDate(played.LastPlayedAt),
+		})
+	}
+
+	enrichConsoleGames(games)
+
+	return games, nil
+}
+
+// writeConsoleGames writes the games of a console source as JSON lines or notes
+func writeConsoleGames(source string, games []ConsoleGame) {
+	if importJSONOut {
+		if err := writeJSONLines(games); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d games\n", len(games))
+		return
+	}
+
+	if err := writeConsoleGamesToJson(source, games); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err := writeConsoleGamesToMarkdown(source, games)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d games\n", len(games))
+}
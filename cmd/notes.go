@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lepinkainen/hermes/internal/titlematch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Note is a markdown file with YAML frontmatter
+type Note struct {
+	Path        string
+	Frontmatter *Frontmatter
+	Body        string
+	// read is set for notes read from the vault, modTime is the modification time of the file
+	// they were read from, zero for notes that didn't exist yet
+	read    bool
+	modTime time.Time
+	layout  noteLayout
+}
+
+// errNoteChanged is returned when writing a note whose file was changed after it was read
+var errNoteChanged = errors.New("note changed since it was read")
+
+// readNote reads and parses a markdown note
+func readNote(path string) (*Note, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, body, _ := splitFrontmatter(string(content))
+	frontmatter, err := parseFrontmatter(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Note{Path: path, Frontmatter: frontmatter, Body: body, read: true, modTime: info.ModTime(), layout: detectNoteLayout(string(content))}, nil
+}
+
+// noteUpdateAttempts is how many times updateNote reads a note again when it changes during the update
+const noteUpdateAttempts = 3
+
+// updateNote reads a note, or starts a new one with the title and tags if it doesn't exist, and
+// writes it after applying update. If the file is changed in between, by Obsidian Sync or another
+// hermes run, the note is read again and the update applied to the new content.
+func updateNote(path, title string, tags []string, update func(note *Note) error) error {
+	var err error
+	for attempt := 1; attempt <= noteUpdateAttempts; attempt++ {
+		note, readErr := readNote(path)
+		if os.IsNotExist(readErr) {
+			note = &Note{Path: path, Frontmatter: newFrontmatter(), read: true}
+			note.Frontmatter.Set("title", title)
+			note.Frontmatter.Set("tags", tags)
+		} else if readErr != nil {
+			return readErr
+		}
+
+		if err := update(note); err != nil {
+			return err
+		}
+		err = note.Write()
+		if !errors.Is(err, errNoteChanged) {
+			return err
+		}
+		log.WithField("Path", path).Debug("Note changed while updating it, reading it again")
+	}
+	return err
+}
+
+// Title returns the note title from frontmatter, falling back to the filename
+func (n *Note) Title() string {
+	if title := n.Frontmatter.GetString("title"); title != "" {
+		return title
+	}
+	return strings.TrimSuffix(filepath.Base(n.Path), ".md")
+}
+
+// Content renders the note back to markdown
+func (n *Note) Content() (string, error) {
+	frontmatter, err := n.Frontmatter.String()
+	if err != nil {
+		return "", err
+	}
+
+	return n.layout.render(frontmatter, n.Body), nil
+}
+
+// Write writes the note back to its path. Notes read from the vault are only written if the
+// file hasn't changed since, otherwise errNoteChanged is returned.
+func (n *Note) Write() error {
+	content, err := n.Content()
+	if err != nil {
+		return err
+	}
+
+	if n.read {
+		info, statErr := os.Stat(n.Path)
+		existed := !n.modTime.IsZero()
+		if (statErr == nil) != existed || (existed && !info.ModTime().Equal(n.modTime)) {
+			return fmt.Errorf("%s: %w", n.Path, errNoteChanged)
+		}
+	}
+
+	if _, err := writeNoteFile(n.Path, content); err != nil {
+		return err
+	}
+	// The note can be changed and written again
+	if info, err := os.Stat(n.Path); n.read && err == nil {
+		n.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// noteWrites counts the notes written and the notes left untouched during the run
+var noteWrites struct {
+	written   int
+	unchanged int
+}
+
+// volatileFields are frontmatter fields ignored when comparing a note with the existing file,
+// they are set by Obsidian plugins and would otherwise cause a rewrite on every run
+var volatileFields = []string{"modified", "updated", "date modified"}
+
+// writeNoteFile writes the note content unless the existing file has the same content,
+// so unchanged notes keep their modification time. Returns false if the file was left untouched.
+func writeNoteFile(path, content string) (bool, error) {
+	content = applyFieldPrivacy(path, content)
+
+	existingPath := path
+	if importDryRun {
+		existingPath = dryRunExistingPath(path)
+	}
+	existing, readErr := os.ReadFile(existingPath)
+	if readErr == nil && sameNoteContent(string(existing), content) {
+		noteWrites.unchanged++
+		log.WithField("Path", path).Debug("Note unchanged")
+		return false, nil
+	}
+
+	if importDryRun {
+		dryRunNoteWrite(path, readErr == nil)
+		return true, nil
+	}
+
+	lockVault()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, err
+	}
+
+	noteWrites.written++
+	log.WithField("Path", path).Debug("Note written")
+	return true, nil
+}
+
+// sameNoteContent compares two notes, ignoring the volatile frontmatter fields and formatting of the frontmatter
+func sameNoteContent(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	normalize := func(content string) (string, bool) {
+		raw, body, ok := splitFrontmatter(content)
+		if !ok {
+			return content, true
+		}
+		frontmatter, err := parseFrontmatter(raw)
+		if err != nil {
+			return "", false
+		}
+		for _, field := range volatileFields {
+			frontmatter.Delete(field)
+		}
+		normalized, err := frontmatter.String()
+		if err != nil {
+			return "", false
+		}
+		return normalized + "---\n" + body, true
+	}
+
+	normalizedA, okA := normalize(a)
+	normalizedB, okB := normalize(b)
+	return okA && okB && normalizedA == normalizedB
+}
+
+// findNotes returns all markdown files under the given directory, without the conflict copies of sync tools
+func findNotes(directory string) ([]string, error) {
+	var paths []string
+
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden directories like .obsidian and .trash
+		if d.IsDir() && path != directory && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+			// Copies made by sync tools would be processed as duplicates of the note
+			if _, ok := syncConflictOriginal(path); ok {
+				skipSyncConflict(path)
+				return nil
+			}
+			paths = append(paths, path)
+		}
+		return nil
+	})
+
+	return paths, err
+}
+
+// normalizeTitle lowercases a title and strips everything but letters and digits for matching
+func normalizeTitle(title string) string {
+	return titlematch.Normalize(title)
+}
+
+// replaceSection replaces the content between the named hermes markers in body,
+// appending a new marked section if the markers are not present yet
+func replaceSection(body, name, content string) string {
+	start := fmt.Sprintf("<!-- hermes:%s:start -->", name)
+	end := fmt.Sprintf("<!-- hermes:%s:end -->", name)
+	section := start + "\n" + strings.TrimRight(content, "\n") + "\n" + end
+
+	startIndex := strings.Index(body, start)
+	endIndex := strings.Index(body, end)
+	if startIndex == -1 || endIndex < startIndex {
+		if strings.TrimSpace(body) == "" {
+			return "\n" + section + "\n"
+		}
+		return strings.TrimRight(body, "\n") + "\n\n" + section + "\n"
+	}
+
+	return body[:startIndex] + section + body[endIndex+len(end):]
+}
+
+// todoTasks returns task lines for the data enrichment couldn't find when TodoTasks is set,
+// so the notes show up in Obsidian task queries. Returns empty if disabled or nothing is missing.
+func todoTasks(missing ...string) string {
+	if !viper.GetBool("TodoTasks") || len(missing) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n")
+	for _, item := range missing {
+		sb.WriteString("- [ ] #hermes/todo " + item + "\n")
+	}
+	return sb.String()
+}
+
+// noteAliases returns the titles a note can also be linked by, like the title without the year of
+// "The Matrix (1999).md" or the original title. Titles matching the file name are left out.
+func noteAliases(path string, titles ...string) []string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var aliases []string
+	seen := map[string]bool{strings.ToLower(name): true}
+	for _, title := range titles {
+		title = strings.TrimSpace(title)
+		if title == "" || seen[strings.ToLower(title)] {
+			continue
+		}
+		seen[strings.ToLower(title)] = true
+		aliases = append(aliases, title)
+	}
+	return aliases
+}
+
+// wikilink returns an Obsidian wikilink to the note at path
+func wikilink(path string) string {
+	return "[[" + strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "]]"
+}
+
+// isGeneratedNote returns true for the index, collection, genre, stats and upcoming notes hermes generates itself
+func isGeneratedNote(note *Note) bool {
+	for _, tag := range []string{"index", "collection", "rollup", "stats", "upcoming", "picks"} {
+		if hasTag(note.Frontmatter, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxNotifiedErrors caps the error list in a notification so a broken run doesn't send a wall of text
+const maxNotifiedErrors = 20
+
+// runLog collects the summary and problems of the current run for the completion notification
+type runLog struct {
+	summary  []string
+	errors   []string
+	warnings int
+}
+
+var currentRun = &runLog{}
+
+func (r *runLog) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel}
+}
+
+// Fire records errors with their fields, warnings are only counted
+func (r *runLog) Fire(entry *log.Entry) error {
+	if entry.Level == log.WarnLevel {
+		r.warnings++
+		return nil
+	}
+
+	message := strings.TrimSpace(entry.Message)
+	if len(entry.Data) > 0 {
+		var fields []string
+		for key, value := range entry.Data {
+			fields = append(fields, fmt.Sprintf("%s=%v", key, value))
+		}
+		sort.Strings(fields)
+		message += " (" + strings.Join(fields, ", ") + ")"
+	}
+	r.errors = append(r.errors, message)
+
+	return nil
+}
+
+func init() {
+	log.AddHook(currentRun)
+}
+
+// summaryf logs a run summary line and keeps it for the completion notification
+func summaryf(format string, args ...interface{}) {
+	log.Infof(format, args...)
+	currentRun.summary = append(currentRun.summary, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
+
+// notifyRunCompleted sends the run summary to the configured notification service
+func notifyRunCompleted(command string) {
+	url := viper.GetString("Notify.URL")
+	if url == "" {
+		return
+	}
+	if viper.GetBool("Notify.OnlyOnError") && len(currentRun.errors) == 0 {
+		return
+	}
+
+	title := fmt.Sprintf("hermes %s completed", command)
+	if len(currentRun.errors) > 0 {
+		title = fmt.Sprintf("hermes %s completed with %d errors", command, len(currentRun.errors))
+	}
+
+	errors := currentRun.errors
+	if len(errors) > maxNotifiedErrors {
+		errors = append(errors[:maxNotifiedErrors:maxNotifiedErrors], fmt.Sprintf("... and %d more", len(currentRun.errors)-maxNotifiedErrors))
+	}
+
+	var text strings.Builder
+	for _, line := range currentRun.summary {
+		text.WriteString(line + "\n")
+	}
+	if currentRun.warnings > 0 {
+		text.WriteString(fmt.Sprintf("%d warnings\n", currentRun.warnings))
+	}
+	for _, line := range errors {
+		text.WriteString("- " + line + "\n")
+	}
+
+	var req *http.Request
+	var err error
+	switch notifyType := viper.GetString("Notify.Type"); notifyType {
+	case "ntfy":
+		req, err = http.NewRequest(http.MethodPost, url, strings.NewReader(text.String()))
+		if err == nil {
+			req.Header.Set("Title", title)
+			if len(currentRun.errors) > 0 {
+				req.Header.Set("Priority", "high")
+				req.Header.Set("Tags", "warning")
+			}
+		}
+	case "slack":
+		req, err = newJSONRequest(url, map[string]string{"text": "*" + title + "*\n" + text.String()})
+	case "webhook":
+		req, err = newJSONRequest(url, map[string]interface{}{
+			"command":  command,
+			"title":    title,
+			"summary":  currentRun.summary,
+			"errors":   currentRun.errors,
+			"warnings": currentRun.warnings,
+		})
+	default:
+		log.Errorf("Unknown Notify.Type %q, expected ntfy, slack or webhook\n", notifyType)
+		return
+	}
+	if err != nil {
+		log.Errorf("Error creating notification: %v\n", err)
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf("Error sending notification: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Errorf("Error sending notification: unexpected status %s\n", resp.Status)
+	}
+}
+
+// newJSONRequest creates a POST request with a JSON body
+func newJSONRequest(url string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
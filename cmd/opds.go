@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lepinkainen/hermes/internal/titlematch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// opdsMatchScore is the title similarity an OPDS entry needs to count as the same book
+const opdsMatchScore = 0.93
+
+// opdsFormats maps the acquisition link types of OPDS entries to the formats written to owned_format
+var opdsFormats = map[string]string{
+	"application/epub+zip":           "epub",
+	"application/pdf":                "pdf",
+	"application/x-mobipocket-ebook": "mobi",
+	"application/x-mobi8-ebook":      "azw3",
+	"application/vnd.amazon.ebook":   "azw3",
+	"application/x-cbz":              "cbz",
+	"application/vnd.comicbook+zip":  "cbz",
+	"application/x-cbr":              "cbr",
+	"application/vnd.comicbook-rar":  "cbr",
+	"application/fb2+zip":            "fb2",
+}
+
+// opdsFeed is the subset of an OPDS acquisition feed we use
+type opdsFeed struct {
+	Entries []opdsEntry `xml:"entry"`
+}
+
+// opdsEntry is a book in an OPDS feed
+type opdsEntry struct {
+	Title   string `xml:"title"`
+	Authors []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+}
+
+// findOwnedEbooks records the ebook formats of the books found in the library of OPDSSearchURL,
+// e.g. a Calibre-web server, when it's set
+func findOwnedEbooks(books []Book) {
+	if viper.GetString("OPDSSearchURL") == "" {
+		return
+	}
+
+	owned := 0
+	for i := range books {
+		book := &books[i]
+		var formats []string
+		err := withRetry(func() error {
+			var err error
+			formats, err = fetchOwnedFormats(*book)
+			return err
+		})
+		if err != nil {
+			log.WithField("Title", book.Title).Warnf("Error searching the OPDS library, keeping the owned formats: %v\n", err)
+			book.OwnedFormatsUnknown = true
+			continue
+		}
+		book.OwnedFormats = formats
+		if len(formats) > 0 {
+			owned++
+		}
+	}
+	log.Infof("Found %d of %d books in the OPDS library\n", owned, len(books))
+}
+
+// opdsCacheAge is how long OPDS searches are cached, the library changes as books are added so
+// unlike the other responses they expire soon
+const opdsCacheAge = time.Hour
+
+// fetchOwnedFormats searches the OPDS library for a book and returns the formats it has it in
+func fetchOwnedFormats(book Book) ([]string, error) {
+	title := mainTitle(bookTitleWithoutSeries(book.Title))
+	key := title + " - " + authorLastName(book)
+	var formats []string
+	if readFreshCache("opds", key, opdsCacheAge, &formats) {
+		return formats, nil
+	}
+
+	// Spaces as %20 work both in paths and query strings
+	query := strings.ReplaceAll(url.QueryEscape(title), "+", "%20")
+	searchURL := strings.ReplaceAll(viper.GetString("OPDSSearchURL"), "{{query}}", query)
+
+	req, err := http.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if username := viper.GetString("OPDSUsername"); username != "" {
+		req.SetBasicAuth(username, viper.GetString("OPDSPassword"))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var feed opdsFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range feed.Entries {
+		if !opdsEntryMatches(entry, title, authorLastName(book)) {
+			continue
+		}
+		for _, link := range entry.Links {
+			if !strings.HasPrefix(link.Rel, "http://opds-spec.org/acquisition") {
+				continue
+			}
+			// Types can have parameters like "application/epub+zip; charset=utf-8"
+			mediaType, _, _ := strings.Cut(link.Type, ";")
+			if format, ok := opdsFormats[strings.TrimSpace(mediaType)]; ok {
+				formats = appendMissing(formats, format)
+			}
+		}
+	}
+	sort.Strings(formats)
+
+	if err := writeCache("opds", key, formats); err != nil {
+		log.Warnf("Error caching OPDS search %s: %v\n", key, err)
+	}
+	return formats, nil
+}
+
+// opdsEntryMatches returns true if the entry has the title and, when known, the author's last name
+func opdsEntryMatches(entry opdsEntry, title, lastName string) bool {
+	if titlematch.Similarity(mainTitle(entry.Title), title) < opdsMatchScore {
+		return false
+	}
+	if lastName == "" || len(entry.Authors) == 0 {
+		return true
+	}
+	for _, author := range entry.Authors {
+		if strings.Contains(titlematch.Normalize(author.Name), titlematch.Normalize(lastName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mainTitle returns a title without its subtitle, libraries often have one when Goodreads doesn't
+func mainTitle(title string) string {
+	main, _, _ := strings.Cut(title, ":")
+	return strings.TrimSpace(main)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestFindOwnedEbooks(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"goodreads/Dune.md": "---\ntitle: Dune\ngoodreads_id: \"1\"\nowned_format: [epub]\n---\n",
+	})
+
+	available, requests := false, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !available {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<feed><entry><title>Dune</title><author><name>Frank Herbert</name></author>` +
+			`<link rel="http://opds-spec.org/acquisition" type="application/pdf"/></entry></feed>`))
+	}))
+	defer server.Close()
+
+	for key, value := range map[string]string{"OPDSSearchURL": server.URL + "/search/{{query}}", "CacheDir": t.TempDir()} {
+		previous := viper.GetString(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, previous) })
+	}
+
+	books := []Book{{ID: 1, Title: "Dune", Authors: []string{"Frank Herbert"}}}
+	findOwnedEbooks(books)
+	if !books[0].OwnedFormatsUnknown {
+		t.Fatal("failed search didn't mark the formats unknown")
+	}
+	if _, err := writeBookToMarkdown(books[0], newNoteRelocator("goodreads_id")); err != nil {
+		t.Fatal(err)
+	}
+	note, err := readNote(filepath.Join(dir, "goodreads/Dune.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := note.Frontmatter.GetStrings("owned_format"); len(got) != 1 || got[0] != "epub" {
+		t.Errorf("owned_format after a failed search = %v, want [epub]", got)
+	}
+
+	available, requests = true, 0
+	for i := 0; i < 2; i++ {
+		books = []Book{{ID: 1, Title: "Dune", Authors: []string{"Frank Herbert"}}}
+		findOwnedEbooks(books)
+		if got := books[0].OwnedFormats; len(got) != 1 || got[0] != "pdf" {
+			t.Errorf("OwnedFormats = %v, want [pdf]", got)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("searched the library %d times, want 1 with the cache", requests)
+	}
+}
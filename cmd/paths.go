@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPathTemplates are used for sources without a template in the PathTemplates config
+var defaultPathTemplates = map[string]string{
+	"imdb":      "imdb/{{title}} ({{year}}).md",
+	"goodreads": "goodreads/{{title}} ({{year}}).md",
+	"comics":    "comics/{{title}}.md",
+	"steam":     "steam/{{title}}.md",
+	"anime":     "anime/{{title}}.md",
+	"manga":     "manga/{{title}}.md",
+	"tmdb":      "tmdb/{{title}} ({{year}}).md",
+	"trakt":     "trakt/{{title}} ({{year}}).md",
+	"gog":       "gog/{{title}}.md",
+	"nintendo":  "nintendo/{{title}}.md",
+	"psn":       "psn/{{title}}.md",
+	"notion":    "notion/{{title}}.md",
+	"airtable":  "airtable/{{title}}.md",
+}
+
+var placeholderRegex = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// notePath renders the path template of a source, relative to MarkdownOutputDir.
+// Values are sanitized so a title with a slash doesn't create extra directories.
+func notePath(source string, vars map[string]string) (string, error) {
+	template := viper.GetStringMapString("PathTemplates")[source]
+	if template == "" {
+		template = defaultPathTemplates[source]
+	}
+
+	var missing []string
+	path := placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return ""
+		}
+		return sanitizeFilename(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unknown placeholders in %s path template: %s", source, strings.Join(missing, ", "))
+	}
+
+	// Unknown years leave an empty "()" behind in templates like "{{title}} ({{year}})"
+	path = strings.ReplaceAll(path, " ()", "")
+
+	if !strings.HasSuffix(path, ".md") {
+		path += ".md"
+	}
+
+	return filepath.Join(viper.GetString("MarkdownOutputDir"), filepath.FromSlash(path)), nil
+}
+
+// decade returns the decade of a year as "1990s"
+func decade(year int) string {
+	if year <= 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(year/10*10) + "s"
+}
+
+// yearString returns the year as a string, empty for unknown years
+func yearString(year int) string {
+	if year <= 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+// Values of TitlePreference and the title_preference field of a note
+const (
+	titleOriginal  = "original"
+	titleLocalized = "localized"
+)
+
+// relocatorKeptFields are the frontmatter fields the relocator reads from the existing notes, they
+// are set by hand, on the first import or by the covers command and written back by the importers
+var relocatorKeptFields = []string{"title_preference", "date_added", "cover", "owned_format"}
+
+// enrichedFields are the fields other commands add to imported notes. Importers write notes from
+// scratch, so the fields are kept from the existing note unless the importer sets them itself.
+var enrichedFields = []string{
+	"franchise", "franchise_progress",
+	"resolution", "audio_languages", "subtitle_languages", "media_files",
+	"thoughts", "review_rating",
+	"screenings",
+	"moods", "pace", "storygraph_rating",
+}
+
+// enrichedTagPrefixes are the prefixes of the tags other commands add to imported notes. Importers
+// replace the tags, so the existing tags with the prefixes are added back.
+var enrichedTagPrefixes = []string{"mood/", "pace/"}
+
+// linkingIdFields are the id fields of sources whose notes also carry the ids of other sources to
+// link the same title: Trakt notes have the imdb_id and TMDB id of the title. Those notes belong to
+// their own source and aren't indexed by the relocators of the linked ids.
+var linkingIdFields = []string{traktIdField("movie"), traktIdField("show")}
+
+// noteRelocator finds existing notes by a source id so they can be moved when the path template changes
+type noteRelocator struct {
+	idField string
+	paths   map[string]string
+	// kept are the relocatorKeptFields and enrichedFields of the notes by id
+	kept map[string]map[string]*yaml.Node
+	// keptTags are the tags of the notes with one of the enrichedTagPrefixes by id
+	keptTags map[string][]string
+}
+
+// newNoteRelocator indexes the notes in MarkdownOutputDir by the given frontmatter id field
+func newNoteRelocator(idField string) *noteRelocator {
+	r := &noteRelocator{
+		idField:  idField,
+		paths:    make(map[string]string),
+		kept:     make(map[string]map[string]*yaml.Node),
+		keptTags: make(map[string][]string),
+	}
+
+	paths, err := findNotes(viper.GetString("MarkdownOutputDir"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error indexing existing notes: %v\n", err)
+		}
+		return r
+	}
+
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			continue
+		}
+		if linkedNote(note, idField) {
+			continue
+		}
+		if id := noteID(note.Frontmatter, idField); id != "" {
+			r.paths[id] = path
+			r.keep(id, note)
+		}
+	}
+
+	return r
+}
+
+// keep stores the relocatorKeptFields, enrichedFields and enriched tags of the existing note with the id
+func (r *noteRelocator) keep(id string, note *Note) {
+	for _, tag := range note.Frontmatter.GetStrings("tags") {
+		for _, prefix := range enrichedTagPrefixes {
+			if strings.HasPrefix(tag, prefix) {
+				r.keptTags[id] = append(r.keptTags[id], tag)
+				break
+			}
+		}
+	}
+	for _, field := range append(relocatorKeptFields, enrichedFields...) {
+		if value := note.Frontmatter.Get(field); value != nil {
+			if r.kept[id] == nil {
+				r.kept[id] = make(map[string]*yaml.Node)
+			}
+			r.kept[id][field] = value
+		}
+	}
+}
+
+// adopt makes a note without an id at path, like a stub of import screenings, the note of the item
+// with the id, so the fields added to the stub are kept when the importer writes the note
+func (r *noteRelocator) adopt(id, path string) {
+	note, err := readNote(path)
+	if err != nil || noteID(note.Frontmatter, r.idField) != "" || linkedNote(note, r.idField) {
+		return
+	}
+	r.paths[id] = path
+	r.keep(id, note)
+}
+
+// linkedNote returns true if the note has idField only as a link from a note of another source
+func linkedNote(note *Note, idField string) bool {
+	for _, field := range linkingIdFields {
+		if field != idField && noteID(note.Frontmatter, field) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// relocate moves the existing note with the id to newPath if it currently lives elsewhere
+func (r *noteRelocator) relocate(id, newPath string) error {
+	// Refuse to overwrite a note that belongs to another item with the same title, items without
+	// an id included
+	if owner := r.owner(newPath); owner != "" && owner != id {
+		return noteConflictError{Path: newPath, ID: id, Owner: owner}
+	}
+
+	if id == "" {
+		return nil
+	}
+
+	oldPath, ok := r.paths[id]
+	if !ok {
+		r.adopt(id, newPath)
+		return nil
+	}
+	if oldPath == newPath {
+		return nil
+	}
+
+	if importDryRun {
+		log.WithField(r.idField, id).Infof("Would move %s to %s\n", oldPath, newPath)
+		dryRun.moved++
+		dryRun.moves[newPath] = oldPath
+		r.paths[id] = newPath
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	log.WithField(r.idField, id).Infof("Moved %s to %s\n", oldPath, newPath)
+	r.paths[id] = newPath
+
+	// Clean up the old directory if the move left it empty
+	os.Remove(filepath.Dir(oldPath))
+
+	return nil
+}
+
+// existing returns one of the relocatorKeptFields of the existing note with the id, empty if it
+// has none
+func (r *noteRelocator) existing(id, field string) string {
+	value := r.existingNode(id, field)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// existingNode returns one of the relocatorKeptFields of the existing note with the id as it is in
+// the frontmatter, for lists, nil if it has none
+func (r *noteRelocator) existingNode(id, field string) *yaml.Node {
+	return r.kept[id][field]
+}
+
+// restore sets the enrichedFields of the existing note with the id that the importer didn't set,
+// adds back its enriched tags, and the cover if the covers command upgraded it to a larger size.
+// Returns true if any were set.
+func (r *noteRelocator) restore(id string, note *Note) bool {
+	restored := keepUpgradedCover(note, r.existing(id, "cover"))
+	for _, field := range enrichedFields {
+		if value := r.kept[id][field]; value != nil && !note.Frontmatter.Has(field) {
+			note.Frontmatter.Set(field, value)
+			restored = true
+		}
+	}
+	// A rating changed in review wins over the rating in the export
+	if rating := r.kept[id]["review_rating"]; rating != nil && note.Frontmatter.GetString("my_rating") != rating.Value {
+		note.Frontmatter.Set("my_rating", rating)
+		restored = true
+	}
+	if added, err := note.Frontmatter.AddTags(r.keptTags[id]...); err != nil {
+		log.WithField("Path", note.Path).Warnf("Error restoring tags: %v\n", err)
+	} else if added {
+		restored = true
+	}
+	return restored
+}
+
+// restoreContent is restore for importers that render the note content as text
+func (r *noteRelocator) restoreContent(id, path, content string) string {
+	raw, body, ok := splitFrontmatter(content)
+	if !ok || len(r.kept[id]) == 0 && len(r.keptTags[id]) == 0 {
+		return content
+	}
+	frontmatter, err := parseFrontmatter(raw)
+	if err != nil {
+		return content
+	}
+
+	note := &Note{Path: path, Frontmatter: frontmatter, Body: body}
+	if !r.restore(id, note) {
+		return content
+	}
+	restored, err := note.Content()
+	if err != nil {
+		log.WithField("Path", path).Warnf("Error restoring fields: %v\n", err)
+		return content
+	}
+	return restored
+}
+
+// titlePreference returns the title_preference of the existing note with the id, empty if it has none.
+// Importers write it back so the preference survives the note being rewritten.
+func (r *noteRelocator) titlePreference(id string) string {
+	return r.existing(id, "title_preference")
+}
+
+// preferredTitle picks the localized or the original title to name a note by. The title_preference
+// of the note wins over TitlePreference in the config, sourceDefault is used when neither is set.
+// The other title ends up in the aliases.
+func preferredTitle(preference, localized, original, sourceDefault string) string {
+	if preference != titleOriginal && preference != titleLocalized {
+		preference = viper.GetString("TitlePreference")
+	}
+	if preference != titleOriginal && preference != titleLocalized {
+		preference = sourceDefault
+	}
+
+	if preference == titleOriginal && original != "" || localized == "" {
+		return original
+	}
+	return localized
+}
+
+// owner returns the id of the existing note at path, empty if there's no note or it has no id
+func (r *noteRelocator) owner(path string) string {
+	note, err := readNote(path)
+	if err != nil {
+		return ""
+	}
+	return noteID(note.Frontmatter, r.idField)
+}
+
+// noteConflictError is returned when a note would overwrite the note of a different item
+type noteConflictError struct {
+	Path  string
+	ID    string
+	Owner string
+}
+
+func (e noteConflictError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("%s already belongs to %s, not overwriting it with an item without an id", e.Path, e.Owner)
+	}
+	return fmt.Sprintf("%s already belongs to %s, not overwriting it with %s", e.Path, e.Owner, e.ID)
+}
+
+// skipNoteConflict logs a note conflict, returns true if err was one so the caller can skip the item
+func skipNoteConflict(err error) bool {
+	var conflict noteConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	log.WithFields(log.Fields{"Path": conflict.Path, "ID": conflict.ID, "Owner": conflict.Owner}).Warn("Note conflict, skipping")
+	return true
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testVault sets MarkdownOutputDir to a temporary directory with the given notes, by path
+// relative to the vault, and returns the directory
+func testVault(t *testing.T, notes map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	previous := viper.GetString("MarkdownOutputDir")
+	viper.Set("MarkdownOutputDir", dir)
+	t.Cleanup(func() { viper.Set("MarkdownOutputDir", previous) })
+
+	for path, content := range notes {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRelocateConflictWithoutID(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"imdb/Heat (1995).md": "---\ntitle: Heat\nimdb_id: tt0113277\n---\n",
+	})
+	relocator := newNoteRelocator("imdb_id")
+
+	var conflict noteConflictError
+	if err := relocator.relocate("", filepath.Join(dir, "imdb/Heat (1995).md")); !errors.As(err, &conflict) {
+		t.Errorf("item without an id: got %v, want a note conflict", err)
+	}
+	if err := relocator.relocate("tt0113277", filepath.Join(dir, "imdb/Heat (1995).md")); err != nil {
+		t.Errorf("owner of the note: got %v, want nil", err)
+	}
+	if err := relocator.relocate("", filepath.Join(dir, "imdb/Heat (1986).md")); err != nil {
+		t.Errorf("item without an id at a new path: got %v, want nil", err)
+	}
+}
+
+func TestRelocateSkipsLinkedNotes(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"trakt/Heat (1995).md": "---\ntitle: Heat\ntrakt_movie_id: \"1\"\ntmdb_movie_id: \"949\"\nimdb_id: tt0113277\n---\n",
+	})
+
+	for _, idField := range []string{"imdb_id", tmdbIdField("movie")} {
+		relocator := newNoteRelocator(idField)
+		id := map[string]string{"imdb_id": "tt0113277", tmdbIdField("movie"): "949"}[idField]
+		newPath := filepath.Join(dir, "imdb/Heat (1995).md")
+		if err := relocator.relocate(id, newPath); err != nil {
+			t.Fatalf("%s: %v", idField, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "trakt/Heat (1995).md")); err != nil {
+			t.Errorf("%s relocator moved the Trakt note: %v", idField, err)
+		}
+	}
+
+	relocator := newNoteRelocator(traktIdField("movie"))
+	if err := relocator.relocate("1", filepath.Join(dir, "movies/Heat (1995).md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "movies/Heat (1995).md")); err != nil {
+		t.Errorf("Trakt relocator didn't move its own note: %v", err)
+	}
+}
+
+func TestRestoreEnrichedFields(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"imdb/Heat (1995).md": "---\ntitle: Heat\nimdb_id: tt0113277\nfranchise: Heat Collection\nfranchise_progress: 1/1\naudio_languages: [en, fi]\n---\n",
+	})
+	relocator := newNoteRelocator("imdb_id")
+
+	content := "---\ntitle: Heat\nimdb_id: tt0113277\n---\n\nBody\n"
+	want := "---\ntitle: Heat\nimdb_id: tt0113277\nfranchise: Heat Collection\nfranchise_progress: 1/1\naudio_languages: [en, fi]\n---\n\nBody\n"
+	if got := relocator.restoreContent("tt0113277", filepath.Join(dir, "imdb/Heat (1995).md"), content); got != want {
+		t.Errorf("restoreContent() = %q, want %q", got, want)
+	}
+
+	note := &Note{Frontmatter: newFrontmatter()}
+	note.Frontmatter.Set("franchise", "Heat")
+	relocator.restore("tt0113277", note)
+	if got := note.Frontmatter.GetString("franchise"); got != "Heat" {
+		t.Errorf("franchise set by the importer = %q, want Heat", got)
+	}
+	if got := note.Frontmatter.GetString("franchise_progress"); got != "1/1" {
+		t.Errorf("franchise_progress = %q, want 1/1", got)
+	}
+
+	if got := relocator.restoreContent("tt0000001", "", content); got != content {
+		t.Errorf("restoreContent() of another note = %q, want it unchanged", got)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// picksCmd represents the picks command
+var picksCmd = &cobra.Command{
+	Use:   "picks",
+	Short: "Rank the watchlist into a Tonight's picks note",
+	Long: `Rank the unwatched TMDB watchlist titles of the vault and write the best ones to
+"Tonight's picks.md" in MarkdownOutputDir. Each title gets a score from 0 to 1 per factor,
+multiplied by the weight of the factor in the config:
+
+  Picks.RuntimeWeight     short enough for tonight: on weeknights (Sunday to Thursday) titles
+                          longer than Picks.WeeknightMaxRuntime minutes (default 110) score less
+  Picks.AvailableWeight   streamable in WatchRegion, from the available field of the import
+  Picks.RatingWeight      the TMDB user rating
+  Picks.AgeWeight         how long the title has been on the watchlist, full after a year
+
+Picks.Count (default 10) titles are listed. The picks depend on the day, run it daily from cron
+or a scheduled task. Requires TMDBAccessToken in the config, runtimes and ratings are cached.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generatePicks(time.Now())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(picksCmd)
+}
+
+// pick is a watchlist title and its score
+type pick struct {
+	Path      string
+	Runtime   int
+	Rating    float64
+	Available string
+	Added     string
+	Score     float64
+}
+
+// tmdbPickDetails are the runtime and user rating of a title
+type tmdbPickDetails struct {
+	Runtime int     `json:"runtime"`
+	Rating  float64 `json:"rating"`
+}
+
+func generatePicks(now time.Time) {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" {
+		log.Error("TMDBAccessToken must be set in the config")
+		return
+	}
+
+	directory := viper.GetString("MarkdownOutputDir")
+	paths, err := findNotes(directory)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", directory, err)
+		return
+	}
+
+	now = now.In(dateLocation())
+	var picks []pick
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) || !hasTag(note.Frontmatter, "tmdb/watchlist") || note.Frontmatter.GetFloat("my_rating") > 0 {
+			continue
+		}
+
+		mediaType, id, err := noteTMDBID(token, note)
+		if err != nil || id == 0 {
+			continue
+		}
+		details, err := fetchTMDBPickDetails(token, mediaType, id)
+		if err != nil {
+			log.WithField("Title", note.Title()).Warnf("Error fetching TMDB details: %v\n", err)
+			continue
+		}
+
+		p := pick{
+			Path:      path,
+			Runtime:   details.Runtime,
+			Rating:    details.Rating,
+			Available: note.Frontmatter.GetString("available"),
+			Added:     note.Frontmatter.GetString("date_added"),
+		}
+		p.Score = pickScore(p, now)
+		picks = append(picks, p)
+	}
+
+	sort.SliceStable(picks, func(i, j int) bool {
+		return picks[i].Score > picks[j].Score
+	})
+	if count := viper.GetInt("Picks.Count"); count > 0 && len(picks) > count {
+		picks = picks[:count]
+	}
+
+	if err := writePicksNote(filepath.Join(directory, "Tonight's picks.md"), picks, now); err != nil {
+		log.Errorf("Error writing picks note: %v\n", err)
+		return
+	}
+
+	summaryf("Picked %d watchlist titles for %s\n", len(picks), now.Format("Monday"))
+}
+
+// pickScore scores a title for the night of now, higher is better
+func pickScore(p pick, now time.Time) float64 {
+	runtime := 1.0
+	// Tomorrow is a workday
+	weeknight := now.Weekday() != time.Friday && now.Weekday() != time.Saturday
+	if maxRuntime := viper.GetFloat64("Picks.WeeknightMaxRuntime"); weeknight && maxRuntime > 0 && float64(p.Runtime) > maxRuntime {
+		runtime = maxRuntime / float64(p.Runtime)
+	}
+
+	// Titles not checked for availability are between available and not
+	available := 0.5
+	switch p.Available {
+	case "true":
+		available = 1
+	case "false":
+		available = 0
+	}
+
+	age := 0.0
+	if added, err := parseWatchDate(p.Added); err == nil {
+		age = math.Min(now.Sub(added).Hours()/24/365, 1)
+		age = math.Max(age, 0)
+	}
+
+	return viper.GetFloat64("Picks.RuntimeWeight")*runtime +
+		viper.GetFloat64("Picks.AvailableWeight")*available +
+		viper.GetFloat64("Picks.RatingWeight")*p.Rating/10 +
+		viper.GetFloat64("Picks.AgeWeight")*age
+}
+
+// fetchTMDBPickDetails returns the runtime and user rating of a movie or TV show, the runtime of
+// a TV show is the length of an episode
+func fetchTMDBPickDetails(token, mediaType string, id int) (tmdbPickDetails, error) {
+	var details tmdbPickDetails
+	key := mediaType + "-" + strconv.Itoa(id)
+	if readCache("tmdbpicks", key, &details) {
+		return details, nil
+	}
+
+	var response struct {
+		Runtime        int     `json:"runtime"`
+		EpisodeRunTime []int   `json:"episode_run_time"`
+		VoteAverage    float64 `json:"vote_average"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d", mediaType, id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return details, err
+	}
+
+	details.Runtime, details.Rating = response.Runtime, response.VoteAverage
+	if details.Runtime == 0 && len(response.EpisodeRunTime) > 0 {
+		details.Runtime = response.EpisodeRunTime[0]
+	}
+
+	if err := writeCache("tmdbpicks", key, details); err != nil {
+		log.Warnf("Error caching TMDB details %s: %v\n", key, err)
+	}
+	return details, nil
+}
+
+// writePicksNote creates or updates the picks note with a table of the picks, best first
+func writePicksNote(path string, picks []pick, now time.Time) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## %s %s\n\n", now.Format("Monday"), now.Format("2006-01-02")))
+	if len(picks) == 0 {
+		sb.WriteString("Nothing on the watchlist.\n")
+	} else {
+		sb.WriteString("| # | Title | Runtime | Rating | Streaming | On the list since | Score |\n")
+		sb.WriteString("|---|-------|---------|--------|-----------|-------------------|-------|\n")
+	}
+	for i, p := range picks {
+		runtime := ""
+		if p.Runtime > 0 {
+			runtime = fmt.Sprintf("%d min", p.Runtime)
+		}
+		streaming := ""
+		switch p.Available {
+		case "true":
+			streaming = "yes"
+		case "false":
+			streaming = "no"
+		}
+		sb.WriteString(fmt.Sprintf("| %d | %s | %s | %.1f | %s | %s | %.2f |\n",
+			i+1, strings.ReplaceAll(wikilink(p.Path), "|", `\|`), runtime, p.Rating, streaming, p.Added, p.Score))
+	}
+
+	return updateNote(path, "Tonight's picks", []string{"picks"}, func(note *Note) error {
+		note.Frontmatter.Set("date", now.Format("2006-01-02"))
+		note.Frontmatter.Set("count", len(picks))
+
+		note.Body = replaceSection(note.Body, "picks", sb.String())
+
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// applyFieldPrivacy removes the Privacy.ExcludeFields frontmatter fields from note content and
+// replaces the values of Privacy.RedactFields with Privacy.RedactPlaceholder, for vaults synced
+// through services that shouldn't see them. Content without such fields is returned as-is.
+func applyFieldPrivacy(path, content string) string {
+	exclude := viper.GetStringSlice("Privacy.ExcludeFields")
+	redact := viper.GetStringSlice("Privacy.RedactFields")
+	if len(exclude) == 0 && len(redact) == 0 {
+		return content
+	}
+
+	raw, body, ok := splitFrontmatter(content)
+	if !ok {
+		return content
+	}
+	frontmatter, err := parseFrontmatter(raw)
+	if err != nil {
+		log.WithField("Path", path).Warnf("Error parsing frontmatter for privacy fields: %v\n", err)
+		return content
+	}
+
+	changed := false
+	for _, field := range exclude {
+		if frontmatter.Delete(field) {
+			changed = true
+		}
+	}
+	placeholder := viper.GetString("Privacy.RedactPlaceholder")
+	for _, field := range redact {
+		// Notes read back from disk already have the placeholder
+		if frontmatter.Has(field) && frontmatter.GetString(field) != placeholder {
+			frontmatter.Set(field, placeholder)
+			changed = true
+		}
+	}
+	if !changed {
+		return content
+	}
+
+	rendered, err := frontmatter.String()
+	if err != nil {
+		log.WithField("Path", path).Warnf("Error writing frontmatter without privacy fields: %v\n", err)
+		return content
+	}
+	return detectNoteLayout(content).render(rendered, body)
+}
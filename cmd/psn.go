@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// psnTitles is the played titles list of a PlayStation Network account
+type psnTitles struct {
+	Titles []struct {
+		TitleID             string `json:"titleId"`
+		Name                string `json:"name"`
+		Category            string `json:"category"`
+		FirstPlayedDateTime string `json:"firstPlayedDateTime"`
+		LastPlayedDateTime  string `json:"lastPlayedDateTime"`
+		PlayDuration        string `json:"playDuration"`
+	} `json:"titles"`
+}
+
+// psnCmd represents the psn command
+var psnCmd = &cobra.Command{
+	Use:   "psn [file]",
+	Short: "Import PlayStation play activity",
+	Long: `Parse the played titles JSON of a PlayStation Network account and write one note per game
+with the total playtime and the first and last played dates. The default file is psn_titles.json.
+
+Games are enriched with release year, developers, genres and cover from IGDB when
+IGDBClientID and IGDBClientSecret (a Twitch application) are set in the config,
+responses are cached in CacheDir.`,
+	ValidArgsFunction: completeInputFile("json"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing PlayStation play history...")
+		parse_psn(inputFile(args, "psn_titles.json"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(psnCmd)
+}
+
+func parse_psn(filename string) {
+	games, err := readPSNFile(filename)
+	if err != nil {
+		log.Errorf("Error reading %s: %v\n", filename, err)
+		return
+	}
+	writeConsoleGames("psn", games)
+}
+
+// readPSNFile reads the played titles, enriching the games from IGDB
+func readPSNFile(filename string) ([]ConsoleGame, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	if importJSONIn {
+		return readJSONLines[ConsoleGame](input)
+	}
+
+	var titles psnTitles
+	if err := json.NewDecoder(input).Decode(&titles); err != nil {
+		return nil, err
+	}
+
+	var games []ConsoleGame
+	for _, title := range titles.Titles {
+		games = append(games, ConsoleGame{
+			Source:          "psn",
+			TitleID:         title.TitleID,
+			Title:           title.Name,
+			Platform:        psnPlatform(title.Category),
+			PlaytimeMinutes: psnMinutes(title.PlayDuration),
+			FirstPlayed:     consoleDate(title.FirstPlayedDateTime),
+			LastPlayed:      consoleDate(title.LastPlayedDateTime),
+		})
+	}
+
+	enrichConsoleGames(games)
+
+	return games, nil
+}
+
+// psnPlatform returns the console of a title category, e.g. ps5 for ps5_native_game
+func psnPlatform(category string) string {
+	if strings.HasPrefix(category, "ps5") {
+		return "ps5"
+	}
+	return "ps4"
+}
+
+var psnDurationRegex = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// psnMinutes converts an ISO 8601 play duration like PT12H30M5S to minutes
+func psnMinutes(duration string) int {
+	match := psnDurationRegex.FindStringSubmatch(duration)
+	if match == nil {
+		return 0
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	return hours*60 + minutes
+}
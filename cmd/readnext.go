@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// readNextListSize is how many to-read books the What to read next note lists
+	readNextListSize = 25
+	// readNextMinGenreBooks is how many rated books a genre needs before it counts as a preference
+	readNextMinGenreBooks = 2
+)
+
+// bookGenres returns the genres of a book, the Google Books categories and your own shelves
+func bookGenres(book Book) []string {
+	var genres []string
+	for _, genre := range append(append([]string{}, book.Categories...), book.Bookshelves...) {
+		genre = strings.ToLower(strings.TrimSpace(genre))
+		if genre != "" && genre != book.ExclusiveShelf && !containsString(genres, genre) {
+			genres = append(genres, genre)
+		}
+	}
+	return genres
+}
+
+// genrePreferences returns how much better than your average you rate books of each genre,
+// in rating points, from the books you have read and rated
+func genrePreferences(books []Book) map[string]float64 {
+	ratings := make(map[string][]float64)
+	total, count := 0.0, 0
+	for _, book := range books {
+		if book.ExclusiveShelf != "read" || book.MyRating <= 0 {
+			continue
+		}
+		total += book.MyRating
+		count++
+		for _, genre := range bookGenres(book) {
+			ratings[genre] = append(ratings[genre], book.MyRating)
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	average := total / float64(count)
+
+	preferences := make(map[string]float64)
+	for genre, values := range ratings {
+		if len(values) < readNextMinGenreBooks {
+			continue
+		}
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		preferences[genre] = sum/float64(len(values)) - average
+	}
+	return preferences
+}
+
+// readingPriority scores a to-read book as its average rating × popularity × genre preference.
+// Popularity grows with the log of the Google Books ratings count, 1 when it's unknown, and the
+// genre preference is 1 ± a fifth of how much better or worse you rate the book's genres.
+func readingPriority(book Book, preferences map[string]float64) float64 {
+	popularity := 1 + math.Log10(1+float64(book.RatingsCount))/6
+
+	preference, matched := 0.0, 0
+	for _, genre := range bookGenres(book) {
+		if value, ok := preferences[genre]; ok {
+			preference += value
+			matched++
+		}
+	}
+	affinity := 1.0
+	if matched > 0 {
+		affinity += preference / float64(matched) / 5
+	}
+
+	return math.Round(book.AverageRating*popularity*affinity*100) / 100
+}
+
+// setReadingPriorities sets the priority of the books on the to-read shelf
+func setReadingPriorities(books []Book) {
+	preferences := genrePreferences(books)
+	for i := range books {
+		if books[i].ExclusiveShelf == "to-read" {
+			books[i].Priority = readingPriority(books[i], preferences)
+		}
+	}
+}
+
+// writeReadNextNote writes the highest priority to-read books and the distribution of your ratings
+// to "What to read next.md" in StatsOutputDir
+func writeReadNextNote(books []Book, paths map[int]string) error {
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	var toRead []Book
+	distribution := make([]int, 6)
+	for _, book := range books {
+		if book.ExclusiveShelf == "to-read" && paths[book.ID] != "" {
+			toRead = append(toRead, book)
+		}
+		if rating := int(math.Round(book.MyRating)); rating >= 1 && rating <= 5 {
+			distribution[rating]++
+		}
+	}
+	sort.SliceStable(toRead, func(i, j int) bool {
+		return toRead[i].Priority > toRead[j].Priority
+	})
+	if len(toRead) > readNextListSize {
+		toRead = toRead[:readNextListSize]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## What to read next\n\n")
+	if len(toRead) == 0 {
+		sb.WriteString("Nothing on the to-read shelf.\n")
+	} else {
+		// The ebooks column shows what's ready to read when the OPDS library is searched
+		ebooks := viper.GetString("OPDSSearchURL") != ""
+		if ebooks {
+			sb.WriteString("| Book | Author | Average rating | Priority | Ebook |\n|---|---|---|---|---|\n")
+		} else {
+			sb.WriteString("| Book | Author | Average rating | Priority |\n|---|---|---|---|\n")
+		}
+		for _, book := range toRead {
+			author := ""
+			if len(book.Authors) > 0 {
+				author = book.Authors[0]
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %.2f | %.2f |", wikilink(paths[book.ID]), author, book.AverageRating, book.Priority))
+			if ebooks {
+				sb.WriteString(" " + strings.Join(book.OwnedFormats, ", ") + " |")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString("\n## My ratings\n\n| Rating | Books |\n|---|---|\n")
+	for rating := 5; rating >= 1; rating-- {
+		sb.WriteString(fmt.Sprintf("| %s | %d |\n", strings.Repeat("★", rating), distribution[rating]))
+	}
+
+	return writeReportNote(filepath.Join(outputDir, "What to read next.md"), "What to read next", "readnext", sb.String())
+}
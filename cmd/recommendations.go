@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// recommendationsListSize is how many films the recommendations note lists
+const recommendationsListSize = 30
+
+var (
+	recommendationsDir       string
+	recommendationsOut       string
+	recommendationsMinRating float64
+)
+
+// tmdbRecommendation is a film in the TMDB recommendations of a movie
+type tmdbRecommendation struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	ReleaseDate string  `json:"release_date"`
+	PosterPath  string  `json:"poster_path"`
+	VoteAverage float64 `json:"vote_average"`
+}
+
+// recommendedFilm is a recommendation with the favourites it was recommended because of
+type recommendedFilm struct {
+	tmdbRecommendation
+	Because []string
+}
+
+// reportRecommendationsCmd represents the report recommendations command
+var reportRecommendationsCmd = &cobra.Command{
+	Use:   "recommendations",
+	Short: "Recommend films based on your highest rated films",
+	Long: `Collect the TMDB recommendations of the films you have rated 9 or 10 and rank the ones
+not in your notes yet by how many of your favourites recommend them, then by TMDB rating.
+Each film is listed with its poster and the favourites it was recommended because of.
+
+Notes are matched to TMDB by tmdb_movie_id or imdb_id, every movie note counts as in the vault,
+watchlisted ones too. Responses are cached in CacheDir.
+
+The report is written to "Recommended for you.md" in StatsOutputDir (default
+<MarkdownOutputDir>/stats), between hermes markers so anything else in the note is kept.
+
+Requires TMDBAccessToken in the config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reportRecommendations()
+	},
+}
+
+func init() {
+	reportCmd.AddCommand(reportRecommendationsCmd)
+
+	reportRecommendationsCmd.Flags().StringVarP(&recommendationsDir, "dir", "d", "", "Directory with movie notes (default MarkdownOutputDir)")
+	reportRecommendationsCmd.MarkFlagDirname("dir")
+	reportRecommendationsCmd.Flags().StringVarP(&recommendationsOut, "out", "o", "", "Report note to write (default StatsOutputDir/Recommended for you.md)")
+	reportRecommendationsCmd.MarkFlagFilename("out", "md")
+	reportRecommendationsCmd.Flags().Float64Var(&recommendationsMinRating, "min-rating", 9, "Lowest of your ratings that counts as a favourite")
+}
+
+func reportRecommendations() {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" {
+		log.Error("TMDBAccessToken must be set in the config")
+		return
+	}
+	if recommendationsDir == "" {
+		recommendationsDir = viper.GetString("MarkdownOutputDir")
+	}
+	if recommendationsOut == "" {
+		outputDir := viper.GetString("StatsOutputDir")
+		if outputDir == "" {
+			outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+		}
+		recommendationsOut = filepath.Join(outputDir, "Recommended for you.md")
+	}
+
+	paths, err := findNotes(recommendationsDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", recommendationsDir, err)
+		return
+	}
+
+	// Favourite films by TMDB id with their note paths, a film can have notes from several sources
+	favourites := make(map[int]string)
+	seen := make(map[int]bool)
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		mediaType, id, err := noteTMDBID(token, note)
+		if err != nil {
+			log.WithField("Title", note.Title()).Warnf("Error finding TMDB id: %v\n", err)
+			continue
+		}
+		if mediaType != "movie" || id == 0 {
+			continue
+		}
+		seen[id] = true
+		if note.Frontmatter.GetFloat("my_rating") >= recommendationsMinRating && favourites[id] == "" {
+			favourites[id] = path
+		}
+	}
+
+	films := make(map[int]*recommendedFilm)
+	for id, path := range favourites {
+		recommendations, err := fetchTMDBRecommendations(token, id)
+		if err != nil {
+			log.WithField("Path", path).Warnf("Error fetching TMDB recommendations: %v\n", err)
+			continue
+		}
+		for _, recommendation := range recommendations {
+			if seen[recommendation.ID] {
+				continue
+			}
+			if films[recommendation.ID] == nil {
+				films[recommendation.ID] = &recommendedFilm{tmdbRecommendation: recommendation}
+			}
+			if films[recommendation.ID].PosterPath == "" {
+				films[recommendation.ID].PosterPath = recommendation.PosterPath
+			}
+			films[recommendation.ID].Because = append(films[recommendation.ID].Because, wikilink(path))
+		}
+	}
+
+	ranked := make([]*recommendedFilm, 0, len(films))
+	for _, film := range films {
+		sort.Strings(film.Because)
+		ranked = append(ranked, film)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if len(ranked[i].Because) != len(ranked[j].Because) {
+			return len(ranked[i].Because) > len(ranked[j].Because)
+		}
+		if ranked[i].VoteAverage != ranked[j].VoteAverage {
+			return ranked[i].VoteAverage > ranked[j].VoteAverage
+		}
+		return ranked[i].Title < ranked[j].Title
+	})
+	if len(ranked) > recommendationsListSize {
+		ranked = ranked[:recommendationsListSize]
+	}
+
+	var sb strings.Builder
+	if len(ranked) == 0 {
+		sb.WriteString(fmt.Sprintf("No recommendations, rate some films %g or higher.\n", recommendationsMinRating))
+	}
+	for i, film := range ranked {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("## %s", film.Title))
+		if len(film.ReleaseDate) >= 4 {
+			sb.WriteString(fmt.Sprintf(" (%s)", film.ReleaseDate[:4]))
+		}
+		sb.WriteString("\n\n")
+		if film.PosterPath != "" {
+			sb.WriteString(fmt.Sprintf("![](https://image.tmdb.org/t/p/w154%s)\n\n", film.PosterPath))
+		}
+		sb.WriteString(fmt.Sprintf("TMDB %.1f, [themoviedb.org](https://www.themoviedb.org/movie/%d)\n", film.VoteAverage, film.ID))
+		sb.WriteString("Recommended because of " + strings.Join(film.Because, ", ") + "\n")
+	}
+
+	if err := writeReportNote(recommendationsOut, "Recommended for you", "recommendations", sb.String()); err != nil {
+		log.Errorf("Error writing %s: %v\n", recommendationsOut, err)
+		return
+	}
+
+	summaryf("Recommended %d films based on %d favourites\n", len(ranked), len(favourites))
+}
+
+// fetchTMDBRecommendations returns the first page of TMDB recommendations for a movie
+func fetchTMDBRecommendations(token string, id int) ([]tmdbRecommendation, error) {
+	var recommendations []tmdbRecommendation
+	key := strconv.Itoa(id)
+	if readCache("tmdbrecommendations", key, &recommendations) {
+		return recommendations, nil
+	}
+
+	var response struct {
+		Results []tmdbRecommendation `json:"results"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d/recommendations", id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeCache("tmdbrecommendations", key, response.Results); err != nil {
+		log.Warnf("Error caching TMDB recommendations %s: %v\n", key, err)
+	}
+	return response.Results, nil
+}
@@ -0,0 +1,389 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// reportTopPeople is how many directors and actors the report lists
+	reportTopPeople = 20
+	// reportFavouriteDirectors is how many of the top directors get their unseen films listed
+	reportFavouriteDirectors = 5
+	// reportCastSize is how many of the top billed actors of a film are counted
+	reportCastSize = 5
+	// Unseen films need at least this TMDB rating and vote count to be suggested
+	reportUnseenMinRating = 7.5
+	reportUnseenMinVotes  = 500
+)
+
+var (
+	reportDir string
+	reportOut string
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate reports from the notes",
+}
+
+// reportPeopleCmd represents the report people command
+var reportPeopleCmd = &cobra.Command{
+	Use:   "people",
+	Short: "Report the most watched directors and actors",
+	Long: `Count the films of each director and actor in the notes with the average of your ratings,
+and list the highly rated films of your favourite directors you haven't seen yet.
+
+Directors are read from the directors frontmatter. Actors and unseen films are fetched
+from TMDB when TMDBAccessToken is set, notes are matched to TMDB by tmdb_movie_id,
+tmdb_tv_id or imdb_id. Responses are cached in CacheDir.
+
+The report is written to People.md in StatsOutputDir (default <MarkdownOutputDir>/stats),
+between hermes markers so anything else in the note is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reportPeople()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportPeopleCmd)
+
+	reportPeopleCmd.Flags().StringVarP(&reportDir, "dir", "d", "", "Directory with movie notes (default MarkdownOutputDir)")
+	reportPeopleCmd.MarkFlagDirname("dir")
+	reportPeopleCmd.Flags().StringVarP(&reportOut, "out", "o", "", "Report note to write (default StatsOutputDir/People.md)")
+	reportPeopleCmd.MarkFlagFilename("out", "md")
+}
+
+// personStats are the films of a person in the notes
+type personStats struct {
+	Name    string
+	Entries []rollupEntry
+	// films are the films of the entries, a film with notes from several sources counts once
+	films map[string]bool
+}
+
+// tmdbCredits is the subset of the credits of a movie or TV show we use
+type tmdbCredits struct {
+	Cast []struct {
+		Name  string `json:"name"`
+		Order int    `json:"order"`
+	} `json:"cast"`
+	Crew []tmdbCrewMember `json:"crew"`
+}
+
+// tmdbCrewMember is a crew credit like Director or Original Music Composer
+type tmdbCrewMember struct {
+	Name string `json:"name"`
+	Job  string `json:"job"`
+}
+
+// composers returns the names credited as Original Music Composer
+func (c tmdbCredits) composers() []string {
+	var names []string
+	for _, member := range c.Crew {
+		if member.Job == "Original Music Composer" && !containsString(names, member.Name) {
+			names = append(names, member.Name)
+		}
+	}
+	return names
+}
+
+// tmdbPersonFilm is a film in the movie credits of a person
+type tmdbPersonFilm struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	ReleaseDate string  `json:"release_date"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
+	Job         string  `json:"job"`
+}
+
+func reportPeople() {
+	if reportDir == "" {
+		reportDir = viper.GetString("MarkdownOutputDir")
+	}
+	if reportOut == "" {
+		outputDir := viper.GetString("StatsOutputDir")
+		if outputDir == "" {
+			outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+		}
+		reportOut = filepath.Join(outputDir, "People.md")
+	}
+	token := viper.GetString("TMDBAccessToken")
+
+	paths, err := findNotes(reportDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", reportDir, err)
+		return
+	}
+
+	directors := make(map[string]*personStats)
+	actors := make(map[string]*personStats)
+	seen := make(map[int]bool)
+	add := func(people map[string]*personStats, film, name string, entry rollupEntry) {
+		if people[name] == nil {
+			people[name] = &personStats{Name: name, films: make(map[string]bool)}
+		}
+		if people[name].films[film] {
+			return
+		}
+		people[name].films[film] = true
+		people[name].Entries = append(people[name].Entries, entry)
+	}
+
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		entry := rollupEntry{
+			Path:   path,
+			Title:  note.Title(),
+			Year:   note.Frontmatter.GetInt("year"),
+			Rating: note.Frontmatter.GetFloat("my_rating"),
+		}
+
+		// Films are identified by the TMDB id, or the IMDb id without TMDBAccessToken
+		film := path
+		if imdbID := noteID(note.Frontmatter, "imdb_id"); imdbID != "" {
+			film = imdbID
+		}
+		mediaType, id := "", 0
+		if token != "" {
+			mediaType, id, err = noteTMDBID(token, note)
+			if err != nil {
+				log.WithField("Title", entry.Title).Warnf("Error finding TMDB id: %v\n", err)
+			}
+			if id != 0 {
+				film = mediaType + "/" + strconv.Itoa(id)
+			}
+		}
+
+		for _, director := range note.Frontmatter.GetStrings("directors") {
+			add(directors, film, strings.TrimSpace(director), entry)
+		}
+
+		if id == 0 {
+			continue
+		}
+		if mediaType == "movie" {
+			seen[id] = true
+		}
+
+		credits, err := fetchTMDBCredits(token, mediaType, id)
+		if err != nil {
+			log.WithField("Title", entry.Title).Warnf("Error fetching TMDB credits: %v\n", err)
+			continue
+		}
+		for _, cast := range credits.Cast {
+			if cast.Order < reportCastSize {
+				add(actors, film, cast.Name, entry)
+			}
+		}
+	}
+
+	topDirectors := topPeople(directors)
+	topActors := topPeople(actors)
+
+	var sb strings.Builder
+	sb.WriteString("## Directors\n\n")
+	writePeopleTable(&sb, topDirectors)
+	if len(topActors) > 0 {
+		sb.WriteString("\n## Actors\n\n")
+		writePeopleTable(&sb, topActors)
+	}
+
+	if token != "" {
+		favourites := topDirectors
+		if len(favourites) > reportFavouriteDirectors {
+			favourites = favourites[:reportFavouriteDirectors]
+		}
+		var unseen strings.Builder
+		for _, director := range favourites {
+			films, err := unseenFilms(token, director.Name, seen)
+			if err != nil {
+				log.WithField("Director", director.Name).Warnf("Error fetching filmography: %v\n", err)
+				continue
+			}
+			if len(films) == 0 {
+				continue
+			}
+			unseen.WriteString(fmt.Sprintf("\n### %s\n\n", director.Name))
+			for _, film := range films {
+				unseen.WriteString(fmt.Sprintf("- %s", film.Title))
+				if len(film.ReleaseDate) >= 4 {
+					unseen.WriteString(fmt.Sprintf(" (%s)", film.ReleaseDate[:4]))
+				}
+				unseen.WriteString(fmt.Sprintf(" TMDB %.1f\n", film.VoteAverage))
+			}
+		}
+		if unseen.Len() > 0 {
+			sb.WriteString("\n## Unseen films by favourite directors\n")
+			sb.WriteString(unseen.String())
+		}
+	}
+
+	if err := writeReportNote(reportOut, "People", "people", sb.String()); err != nil {
+		log.Errorf("Error writing %s: %v\n", reportOut, err)
+		return
+	}
+
+	summaryf("Reported %d directors and %d actors\n", len(directors), len(actors))
+}
+
+// topPeople returns the people with the most films, then the best average rating
+func topPeople(people map[string]*personStats) []*personStats {
+	var list []*personStats
+	for _, person := range people {
+		// A single film says nothing about a person
+		if len(person.Entries) > 1 {
+			list = append(list, person)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i].Entries) != len(list[j].Entries) {
+			return len(list[i].Entries) > len(list[j].Entries)
+		}
+		a, _ := averageRating(list[i].Entries)
+		b, _ := averageRating(list[j].Entries)
+		if a != b {
+			return a > b
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	if len(list) > reportTopPeople {
+		list = list[:reportTopPeople]
+	}
+	return list
+}
+
+func writePeopleTable(sb *strings.Builder, people []*personStats) {
+	if len(people) == 0 {
+		sb.WriteString("No one with more than one film.\n")
+		return
+	}
+	sb.WriteString("| Name | Films | Average rating |\n|---|---|---|\n")
+	for _, person := range people {
+		average := "-"
+		if value, ok := averageRating(person.Entries); ok {
+			average = fmt.Sprintf("%.1f", value)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %d | %s |\n", person.Name, len(person.Entries), average))
+	}
+}
+
+// noteTMDBID returns the TMDB media type and id of a note, id is 0 if it can't be matched
+func noteTMDBID(token string, note *Note) (string, int, error) {
+	for _, mediaType := range []string{"movie", "tv"} {
+		if id, err := strconv.Atoi(noteID(note.Frontmatter, tmdbIdField(mediaType))); err == nil {
+			return mediaType, id, nil
+		}
+	}
+	if imdbID := noteID(note.Frontmatter, "imdb_id"); imdbID != "" {
+		return findTMDBByImdbID(token, imdbID)
+	}
+	return "", 0, nil
+}
+
+// fetchTMDBCredits returns the cast and crew of a movie or TV show
+func fetchTMDBCredits(token, mediaType string, id int) (tmdbCredits, error) {
+	// Entries cached before the crew was stored have no crew at all, they are fetched again
+	var credits tmdbCredits
+	key := mediaType + "-" + strconv.Itoa(id)
+	if readCache("tmdbcredits", key, &credits) && credits.Crew != nil {
+		return credits, nil
+	}
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/credits", mediaType, id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &credits)
+	})
+	if err != nil {
+		return credits, err
+	}
+
+	if credits.Crew == nil {
+		credits.Crew = []tmdbCrewMember{}
+	}
+	if err := writeCache("tmdbcredits", key, credits); err != nil {
+		log.Warnf("Error caching TMDB credits %s: %v\n", key, err)
+	}
+	return credits, nil
+}
+
+// unseenFilms returns the well rated films directed by a person that aren't in seen, best rated first
+func unseenFilms(token, director string, seen map[int]bool) ([]tmdbPersonFilm, error) {
+	var filmography struct {
+		Crew []tmdbPersonFilm `json:"crew"`
+	}
+	if !readCache("tmdbperson", director, &filmography) {
+		var search struct {
+			Results []struct {
+				ID int `json:"id"`
+			} `json:"results"`
+		}
+		searchURL := "https://api.themoviedb.org/3/search/person?query=" + url.QueryEscape(director)
+		err := withRetry(func() error {
+			return tmdbRequest(http.MethodGet, searchURL, token, nil, &search)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(search.Results) > 0 {
+			creditsURL := fmt.Sprintf("https://api.themoviedb.org/3/person/%d/movie_credits", search.Results[0].ID)
+			err := withRetry(func() error {
+				return tmdbRequest(http.MethodGet, creditsURL, token, nil, &filmography)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := writeCache("tmdbperson", director, filmography); err != nil {
+			log.Warnf("Error caching TMDB filmography of %s: %v\n", director, err)
+		}
+	}
+
+	var films []tmdbPersonFilm
+	for _, film := range filmography.Crew {
+		if film.Job != "Director" || seen[film.ID] || film.VoteAverage < reportUnseenMinRating || film.VoteCount < reportUnseenMinVotes {
+			continue
+		}
+		films = append(films, film)
+	}
+
+	sort.Slice(films, func(i, j int) bool {
+		return films[i].VoteAverage > films[j].VoteAverage
+	})
+	return films, nil
+}
+
+// writeReportNote creates or updates a report note, the report is written between the named markers
+func writeReportNote(path, title, section, content string) error {
+	return updateNote(path, title, []string{"stats"}, func(note *Note) error {
+		note.Body = replaceSection(note.Body, section, content)
+		return nil
+	})
+}
@@ -0,0 +1,213 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// retryAttempts is how many times a transient failure is retried inline before the item is queued
+const retryAttempts = 3
+
+// httpStatusError is returned by the API clients for non-200 responses
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e httpStatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
+// isTransient returns true for errors worth retrying later: timeouts, rate limits and server errors
+func isTransient(err error) bool {
+	var statusErr httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// withRetry calls fn, retrying transient failures with exponential backoff
+func withRetry(fn func() error) error {
+	backoff := time.Second
+	var err error
+	for attempt := 1; attempt <= retryAttempts; attempt++ {
+		err = fn()
+		if err == nil || !isTransient(err) || attempt == retryAttempts {
+			break
+		}
+		log.Debugf("Transient error, retrying in %s: %v\n", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+// RetryItem is an item whose enrichment failed with a transient error
+type RetryItem struct {
+	Source      string          `json:"source"`
+	Key         string          `json:"key"`
+	Item        json.RawMessage `json:"item"`
+	Error       string          `json:"error"`
+	Attempts    int             `json:"attempts"`
+	LastAttempt time.Time       `json:"last_attempt"`
+}
+
+// retryHandlers re-enrich a queued item of a source and rewrite its note
+var retryHandlers = map[string]func(item json.RawMessage) error{
+	"comics":    retryComic,
+	"goodreads": retryBook,
+	"steam":     retryGame,
+	"mal":       retryMalEntry,
+	"console":   retryConsoleGame,
+}
+
+// retryQueue is loaded on first use and saved at the end of the run
+var retryQueue []RetryItem
+var retryQueueLoaded bool
+
+func retryQueuePath() string {
+	return filepath.Join(viper.GetString("CacheDir"), "retry_queue.json")
+}
+
+func loadRetryQueue() {
+	if retryQueueLoaded {
+		return
+	}
+	retryQueueLoaded = true
+
+	data, err := os.ReadFile(retryQueuePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading retry queue: %v\n", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &retryQueue); err != nil {
+		log.Warnf("Error reading retry queue: %v\n", err)
+	}
+}
+
+// saveRetryQueue writes the queue back to disk if it was used during the run
+func saveRetryQueue() {
+	if !retryQueueLoaded || importDryRun {
+		return
+	}
+
+	if len(retryQueue) == 0 {
+		os.Remove(retryQueuePath())
+		return
+	}
+
+	data, err := json.MarshalIndent(retryQueue, "", "  ")
+	if err != nil {
+		log.Errorf("Error saving retry queue: %v\n", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(retryQueuePath()), 0755); err != nil {
+		log.Errorf("Error saving retry queue: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(retryQueuePath(), data, 0644); err != nil {
+		log.Errorf("Error saving retry queue: %v\n", err)
+	}
+}
+
+// queueRetry records the outcome of an enrichment: transient failures are queued, anything else
+// clears a queued entry for the item since retrying won't change the result
+func queueRetry(source, key string, item interface{}, err error) {
+	loadRetryQueue()
+
+	for i, queued := range retryQueue {
+		if queued.Source == source && queued.Key == key {
+			retryQueue = append(retryQueue[:i], retryQueue[i+1:]...)
+			break
+		}
+	}
+
+	if err == nil || !isTransient(err) {
+		return
+	}
+
+	data, marshalErr := json.Marshal(item)
+	if marshalErr != nil {
+		log.Errorf("Error queueing %s %s for retry: %v\n", source, key, marshalErr)
+		return
+	}
+
+	retryQueue = append(retryQueue, RetryItem{
+		Source:      source,
+		Key:         key,
+		Item:        data,
+		Error:       err.Error(),
+		Attempts:    1,
+		LastAttempt: time.Now(),
+	})
+	log.WithFields(log.Fields{"Source": source, "Key": key}).Info("Queued for retry")
+}
+
+// retryCmd represents the retry command
+var retryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry enrichment of items that failed with transient errors",
+	Long: `Importers retry timeouts, rate limits and server errors a few times with backoff.
+Items that still fail are queued in CacheDir/retry_queue.json, this command
+re-attempts only those items and rewrites their notes.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		retryQueued()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retryCmd)
+}
+
+func retryQueued() {
+	loadRetryQueue()
+	queued := retryQueue
+	retryQueue = nil
+
+	var succeeded int
+	for _, item := range queued {
+		itemLogger := log.WithFields(log.Fields{"Source": item.Source, "Key": item.Key})
+
+		handler, ok := retryHandlers[item.Source]
+		if !ok {
+			itemLogger.Error("No retry handler for source")
+			continue
+		}
+
+		err := handler(item.Item)
+		if err == nil {
+			succeeded++
+			continue
+		}
+		if !isTransient(err) {
+			itemLogger.Errorf("Retry failed, dropping from queue: %v\n", err)
+			continue
+		}
+
+		itemLogger.Warnf("Retry failed, keeping in queue: %v\n", err)
+		item.Error = err.Error()
+		item.Attempts++
+		item.LastAttempt = time.Now()
+		retryQueue = append(retryQueue, item)
+	}
+
+	saveRetryQueue()
+	summaryf("Retried %d items: %d succeeded, %d still queued\n", len(queued), succeeded, len(retryQueue))
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	reviewDir  string
+	reviewYear int
+)
+
+// reviewDateFields are the date fields of the notes with what was done on the date, the first
+// one of a note in the year is used
+var reviewDateFields = []struct {
+	field string
+	verb  string
+}{
+	{"date_rated", "watched"},
+	{"date_read", "read"},
+	{"date_finished", "finished"},
+	{"completed_date", "completed"},
+	{"last_played", "played"},
+}
+
+// reviewCmd represents the review command
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Go through the year's items to check ratings and add thoughts",
+	Long: `Walk through the movies, books and games of a year in date order, the notes with a
+date_rated, date_read, date_finished, completed_date or last_played in the year.
+
+For each item enter a new rating or press enter to keep it, then a one-line thought or enter to
+keep the current one. Ratings are on the scale of the source, 1 to 5 for Goodreads books and
+0 to 10 for the rest. Ratings are written to my_rating and review_rating, thoughts to thoughts.
+Importers keep the thoughts and set my_rating to the review_rating when they rewrite the note,
+so a rating changed in review wins over the rating in the export until review_rating is removed.
+Notes without changes are left untouched. Enter q to stop, the items reviewed so far are saved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewNotes(cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+
+	reviewCmd.Flags().StringVarP(&reviewDir, "dir", "d", "", "Vault directory (default MarkdownOutputDir)")
+	reviewCmd.MarkFlagDirname("dir")
+	reviewCmd.Flags().IntVarP(&reviewYear, "year", "y", time.Now().Year(), "Year to review")
+}
+
+// ratingScale is the range of ratings of a source
+type ratingScale struct {
+	min, max float64
+}
+
+// ratingScales are the rating scales of the sources that don't rate from 0 to 10, by id field
+var ratingScales = map[string]ratingScale{
+	"goodreads_id": {1, 5},
+}
+
+// noteRatingScale returns the rating scale of the source of a note
+func noteRatingScale(note *Note) ratingScale {
+	for idField, scale := range ratingScales {
+		if noteID(note.Frontmatter, idField) != "" {
+			return scale
+		}
+	}
+	return ratingScale{0, 10}
+}
+
+// reviewItem is a note to review
+type reviewItem struct {
+	note *Note
+	date string
+	verb string
+}
+
+func reviewNotes(in io.Reader, out io.Writer) {
+	if reviewDir == "" {
+		reviewDir = viper.GetString("MarkdownOutputDir")
+	}
+
+	items, err := findReviewItems(reviewDir, reviewYear)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", reviewDir, err)
+		return
+	}
+	if len(items) == 0 {
+		summaryf("Nothing to review in %d\n", reviewYear)
+		return
+	}
+
+	scanner := bufio.NewScanner(in)
+	prompt := func(label string) (string, bool) {
+		fmt.Fprint(out, label)
+		if !scanner.Scan() {
+			return "", false
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		return answer, answer != "q"
+	}
+
+	reviewed, updated := 0, 0
+review:
+	for i, item := range items {
+		note := item.note
+		rating := note.Frontmatter.GetString("my_rating")
+		fmt.Fprintf(out, "\n[%d/%d] %s, %s %s\n", i+1, len(items), note.Title(), item.verb, item.date)
+		if thoughts := note.Frontmatter.GetString("thoughts"); thoughts != "" {
+			fmt.Fprintf(out, "  %s\n", thoughts)
+		}
+
+		changed := false
+		scale := noteRatingScale(note)
+		for {
+			answer, ok := prompt(fmt.Sprintf("Rating %g-%g [%s]: ", scale.min, scale.max, rating))
+			if !ok {
+				break review
+			}
+			if answer == "" {
+				break
+			}
+			value, err := strconv.ParseFloat(answer, 64)
+			if err != nil || value < scale.min || value > scale.max {
+				fmt.Fprintf(out, "Enter a rating from %g to %g, enter to keep it or q to stop\n", scale.min, scale.max)
+				continue
+			}
+			if answer != rating {
+				var newRating interface{} = value
+				if value == float64(int(value)) {
+					newRating = int(value)
+				}
+				note.Frontmatter.Set("my_rating", newRating)
+				note.Frontmatter.Set("review_rating", newRating)
+				changed = true
+			}
+			break
+		}
+
+		thought, ok := prompt("Thoughts: ")
+		if !ok {
+			// Keep the rating entered for this item
+			if changed {
+				updated += writeReviewedNote(note)
+			}
+			break
+		}
+		if thought != "" {
+			note.Frontmatter.Set("thoughts", thought)
+			changed = true
+		}
+
+		reviewed++
+		if changed {
+			updated += writeReviewedNote(note)
+		}
+	}
+
+	summaryf("Reviewed %d of %d items from %d, updated %d notes\n", reviewed, len(items), reviewYear, updated)
+}
+
+// findReviewItems returns the notes with a date in the year, oldest first
+func findReviewItems(directory string, year int) ([]reviewItem, error) {
+	paths, err := findNotes(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []reviewItem
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		for _, dateField := range reviewDateFields {
+			day, err := parseWatchDate(note.Frontmatter.GetString(dateField.field))
+			if err != nil || day.Year() != year {
+				continue
+			}
+			items = append(items, reviewItem{note: note, date: day.Format("2006-01-02"), verb: dateField.verb})
+			break
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].date < items[j].date
+	})
+	return items, nil
+}
+
+// writeReviewedNote writes a changed note, returns the number of notes written
+func writeReviewedNote(note *Note) int {
+	if err := note.Write(); err != nil {
+		log.WithField("Path", note.Path).Errorf("Error writing note: %v\n", err)
+		return 0
+	}
+	return 1
+}
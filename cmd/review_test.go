@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReviewRatingScale(t *testing.T) {
+	dir := testVault(t, map[string]string{
+		"goodreads/Dune.md":   "---\ntitle: Dune\ngoodreads_id: \"234225\"\nmy_rating: 3\ndate_read: 2024-03-01\n---\n",
+		"imdb/Heat (1995).md": "---\ntitle: Heat\nimdb_id: tt0113277\nmy_rating: 8\ndate_rated: 2024-05-01\n---\n",
+	})
+	previousDir, previousYear := reviewDir, reviewYear
+	reviewDir, reviewYear = "", 2024
+	t.Cleanup(func() { reviewDir, reviewYear = previousDir, previousYear })
+
+	var out bytes.Buffer
+	reviewNotes(strings.NewReader("7\n4\n\n9\n\n"), &out)
+
+	if !strings.Contains(out.String(), "Enter a rating from 1 to 5") {
+		t.Errorf("rating 7 of a Goodreads book wasn't rejected:\n%s", out.String())
+	}
+	for path, want := range map[string]float64{"goodreads/Dune.md": 4, "imdb/Heat (1995).md": 9} {
+		note, err := readNote(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := note.Frontmatter.GetFloat("my_rating"); got != want {
+			t.Errorf("%s my_rating = %g, want %g", path, got, want)
+		}
+	}
+}
+
+func TestReviewRatingSurvivesReimport(t *testing.T) {
+	dir := testVault(t, nil)
+	previousDir, previousYear := reviewDir, reviewYear
+	reviewDir, reviewYear = "", 2024
+	t.Cleanup(func() { reviewDir, reviewYear = previousDir, previousYear })
+
+	movie := MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie", MyRating: 8, DateRated: "2024-05-01"}
+	if err := newMovieNoteWriter().write(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	reviewNotes(strings.NewReader("9\nBetter than I remembered\n"), &out)
+
+	if err := newMovieNoteWriter().write(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readNote(filepath.Join(dir, "imdb/Heat (1995).md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := note.Frontmatter.GetInt("my_rating"); got != 9 {
+		t.Errorf("my_rating after reimport = %d, want the reviewed 9", got)
+	}
+	if got := note.Frontmatter.GetString("thoughts"); got != "Better than I remembered" {
+		t.Errorf("thoughts after reimport = %q", got)
+	}
+}
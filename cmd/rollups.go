@@ -0,0 +1,173 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rollupsDir string
+
+// rollupsCmd represents the rollups command
+var rollupsCmd = &cobra.Command{
+	Use:   "rollups",
+	Short: "Generate genre hub notes",
+	Long: `Generate or update a note per genre, e.g. Genres/Horror.md, linking every note with the genre
+grouped by decade with the average of your ratings. Genres are read from the genres and
+categories frontmatter fields.
+
+The notes are written to RollupsOutputDir (default <MarkdownOutputDir>/Genres).
+Links are written between hermes markers, anything else in the genre note is kept.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateRollups()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollupsCmd)
+
+	rollupsCmd.Flags().StringVarP(&rollupsDir, "dir", "d", "", "Directory with notes to roll up (default MarkdownOutputDir)")
+	rollupsCmd.MarkFlagDirname("dir")
+}
+
+// rollupEntry is a note listed in a genre note
+type rollupEntry struct {
+	Path   string
+	Title  string
+	Year   int
+	Rating float64
+}
+
+func generateRollups() {
+	if rollupsDir == "" {
+		rollupsDir = viper.GetString("MarkdownOutputDir")
+	}
+	outputDir := viper.GetString("RollupsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "Genres")
+	}
+
+	paths, err := findNotes(rollupsDir)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", rollupsDir, err)
+		return
+	}
+
+	genres := make(map[string][]rollupEntry)
+	for _, path := range paths {
+		if isInDir(path, outputDir) {
+			continue
+		}
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		entry := rollupEntry{
+			Path:   path,
+			Title:  note.Title(),
+			Year:   note.Frontmatter.GetInt("year"),
+			Rating: note.Frontmatter.GetFloat("my_rating"),
+		}
+		seen := make(map[string]bool)
+		for _, genre := range append(note.Frontmatter.GetStrings("genres"), note.Frontmatter.GetStrings("categories")...) {
+			genre = strings.TrimSpace(genre)
+			if genre == "" || seen[genre] {
+				continue
+			}
+			seen[genre] = true
+			genres[genre] = append(genres[genre], entry)
+		}
+	}
+
+	for genre, entries := range genres {
+		if err := writeRollup(genre, entries, outputDir); err != nil {
+			log.WithField("Genre", genre).Errorf("Error writing genre note: %v\n", err)
+		}
+	}
+
+	summaryf("Generated %d genre notes\n", len(genres))
+}
+
+// writeRollup creates or updates the note of a genre, grouping the entries by decade
+func writeRollup(genre string, entries []rollupEntry, directory string) error {
+	groups := make(map[string][]rollupEntry)
+	for _, entry := range entries {
+		key := decade(entry.Year)
+		groups[key] = append(groups[key], entry)
+	}
+
+	var keys []string
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Year != group[j].Year {
+				return group[i].Year < group[j].Year
+			}
+			return strings.ToLower(group[i].Title) < strings.ToLower(group[j].Title)
+		})
+
+		heading := key
+		if average, ok := averageRating(group); ok {
+			heading += fmt.Sprintf(" (average rating %.1f)", average)
+		}
+		sb.WriteString(fmt.Sprintf("## %s\n\n", heading))
+		for _, entry := range group {
+			sb.WriteString("- " + wikilink(entry.Path))
+			if entry.Rating > 0 {
+				sb.WriteString(fmt.Sprintf(" (%g)", entry.Rating))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	path := filepath.Join(directory, sanitizeFilename(genre)+".md")
+	return updateNote(path, genre, []string{"rollup"}, func(note *Note) error {
+		note.Frontmatter.Set("count", len(entries))
+		if average, ok := averageRating(entries); ok {
+			note.Frontmatter.Set("average_rating", average)
+		} else {
+			note.Frontmatter.Delete("average_rating")
+		}
+
+		note.Body = replaceSection(note.Body, "rollup", sb.String())
+
+		return nil
+	})
+}
+
+// averageRating returns the average of the rated entries rounded to one decimal,
+// ok is false if none of the entries are rated
+func averageRating(entries []rollupEntry) (average float64, ok bool) {
+	var sum float64
+	var rated int
+	for _, entry := range entries {
+		if entry.Rating > 0 {
+			sum += entry.Rating
+			rated++
+		}
+	}
+	if rated == 0 {
+		return 0, false
+	}
+	return float64(int(sum/float64(rated)*10+0.5)) / 10, true
+}
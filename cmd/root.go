@@ -25,6 +25,13 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		// The conflicts command lists them itself
+		if cmd != conflictsCmd {
+			reportSyncConflicts()
+		}
+		unlockVault()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -41,6 +48,62 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	viper.SetDefault("MarkdownOutputDir", "./markdown/")
+	viper.SetDefault("CacheDir", "./cache/")
+	viper.SetDefault("LogDir", ".hermes/logs")
+	viper.SetDefault("PathTemplates", defaultPathTemplates)
+	viper.SetDefault("TitlePreference", "")
+	viper.SetDefault("Timezone", "")
+	viper.SetDefault("IndexNoteGroupBy", "decade")
+	viper.SetDefault("TodoTasks", false)
+	viper.SetDefault("ComicVineAPIKey", "")
+	viper.SetDefault("SteamAPIKey", "")
+	viper.SetDefault("SteamID", "")
+	viper.SetDefault("SteamAbandonedMonths", 6)
+	viper.SetDefault("SteamCompletionThresholds", []int{50})
+	viper.SetDefault("SteamGridDBAPIKey", "")
+	viper.SetDefault("SteamGridDBArtwork", "grid")
+	viper.SetDefault("SteamRegion", "")
+	viper.SetDefault("SteamFallbackRegion", "us")
+	viper.SetDefault("GoogleBooksAPIKey", "")
+	viper.SetDefault("AgeRecipient", "")
+	viper.SetDefault("AgeIdentityFile", "")
+	viper.SetDefault("IGDBClientID", "")
+	viper.SetDefault("IGDBClientSecret", "")
+	viper.SetDefault("BookPagesPerHour", 40)
+	viper.SetDefault("OPDSSearchURL", "")
+	viper.SetDefault("OPDSUsername", "")
+	viper.SetDefault("OPDSPassword", "")
+	viper.SetDefault("TMDBAccessToken", "")
+	viper.SetDefault("TMDBAccountID", "")
+	viper.SetDefault("TMDBVideoLimit", 3)
+	viper.SetDefault("SoundtrackSearchURL", "")
+	viper.SetDefault("WatchRegion", "")
+	viper.SetDefault("TraktClientID", "")
+	viper.SetDefault("TraktUsername", "")
+	viper.SetDefault("TraktAccessToken", "")
+	viper.SetDefault("ContentWarnings.Provider", "")
+	viper.SetDefault("ContentWarnings.DoesTheDogDieAPIKey", "")
+	viper.SetDefault("ContentWarnings.Keywords", map[string]string{})
+	viper.SetDefault("Privacy.ExcludeFields", []string{})
+	viper.SetDefault("Privacy.RedactFields", []string{})
+	viper.SetDefault("Privacy.RedactPlaceholder", "redacted")
+	viper.SetDefault("Notify.URL", "")
+	viper.SetDefault("Notify.Type", "ntfy")
+	viper.SetDefault("Notify.OnlyOnError", false)
+	viper.SetDefault("Digest.Provider", "smtp")
+	viper.SetDefault("Digest.From", "")
+	viper.SetDefault("Digest.To", "")
+	viper.SetDefault("Digest.SMTPHost", "")
+	viper.SetDefault("Digest.SMTPPort", 587)
+	viper.SetDefault("Digest.SMTPUsername", "")
+	viper.SetDefault("Digest.SMTPPassword", "")
+	viper.SetDefault("Digest.ResendAPIKey", "")
+	viper.SetDefault("Picks.Count", 10)
+	viper.SetDefault("Picks.WeeknightMaxRuntime", 110)
+	viper.SetDefault("Picks.RuntimeWeight", 1.0)
+	viper.SetDefault("Picks.AvailableWeight", 2.0)
+	viper.SetDefault("Picks.RatingWeight", 1.0)
+	viper.SetDefault("Picks.AgeWeight", 1.0)
 
 	viper.SetConfigName("config") // name of config file (without extension)
 	viper.SetConfigType("yaml")   // REQUIRED if the config file does not have the extension in the name
@@ -55,6 +118,8 @@ func init() {
 		}
 	}
 
+	resolveSecrets()
+
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hermes.yaml)")
 
 	// Cobra also supports local flags, which will only run
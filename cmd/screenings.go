@@ -0,0 +1,437 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lepinkainen/hermes/internal/titlematch"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Screening is a single cinema or festival visit from the viewing log
+type Screening struct {
+	Date   string `json:"Date" yaml:"date"`
+	Title  string `json:"Title" yaml:"-"`
+	Year   int    `json:"Year" yaml:"-"`
+	Venue  string `json:"Venue" yaml:"venue,omitempty"`
+	Format string `json:"Format" yaml:"format,omitempty"`
+}
+
+var screeningsNotesDir string
+
+// screeningsCmd represents the screenings command
+var screeningsCmd = &cobra.Command{
+	Use:   "screenings [file]",
+	Short: "Parse a cinema / film festival viewing log",
+	Long: `Parse a viewing log CSV with the columns date, title, venue, format (and optionally year)
+and append each visit to the screenings list in the frontmatter of the matching movie note.
+Importers keep the list when they rewrite the note.
+
+Movies without an existing note get a stub note at the path of the imdb path template, named by
+title and year like the notes of the IMDb importer, which writes the movie to the stub later.
+When TMDBAccessToken is set the stub is filled in from the TMDB search result of the title and
+year, including its imdb_id.
+The file defaults to screenings.csv, "-" reads the log from stdin.`,
+	ValidArgsFunction: completeInputFile("csv"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing viewing log...")
+		parse_screenings(inputFile(args, "screenings.csv"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(screeningsCmd)
+
+	screeningsCmd.Flags().StringVarP(&screeningsNotesDir, "notes-dir", "d", "", "Directory with movie notes (default <MarkdownOutputDir>/imdb)")
+}
+
+func parse_screenings(filename string) {
+	if screeningsNotesDir == "" {
+		screeningsNotesDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "imdb")
+	}
+
+	input, err := openInput(filename)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	var screenings []Screening
+	if importJSONIn {
+		screenings, err = readJSONLines[Screening](input)
+	} else {
+		screenings, err = readScreenings(input)
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(screenings); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d screenings\n", len(screenings))
+		return
+	}
+
+	index, err := indexMovieNotes(screeningsNotesDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error reading notes from %s: %v\n", screeningsNotesDir, err)
+		return
+	}
+
+	var updated, created, unchanged int
+	for _, screening := range screenings {
+		screeningLogger := log.WithFields(log.Fields{
+			"Title": screening.Title,
+			"Date":  screening.Date,
+		})
+
+		path := index.find(screening.Title, screening.Year)
+		var note *Note
+		if path == "" {
+			note, err = newMovieStub(screening.Title, screening.Year)
+			if err != nil {
+				screeningLogger.Errorf("Error creating stub: %v\n", err)
+				continue
+			}
+			index.add(note.Path, screening.Title, note.Frontmatter.GetInt("year"))
+			if note.read {
+				screeningLogger.Infof("Adding to %s\n", note.Path)
+			} else {
+				created++
+				screeningLogger.Info("No matching note, creating stub")
+			}
+		} else {
+			note, err = readNote(path)
+			if err != nil {
+				screeningLogger.Errorf("Error reading %s: %v\n", path, err)
+				continue
+			}
+		}
+
+		added, err := addScreening(note, screening)
+		if err != nil {
+			screeningLogger.Errorf("Error adding screening: %v\n", err)
+			continue
+		}
+		venue := ""
+		if screening.Venue != "" {
+			venue = "at " + screening.Venue
+		}
+		addDailyActivity(screening.Date, "Watched", note.Path, venue)
+		if !added {
+			unchanged++
+			continue
+		}
+
+		if err := note.Write(); err != nil {
+			screeningLogger.Errorf("Error writing %s: %v\n", note.Path, err)
+			continue
+		}
+		if path != "" {
+			updated++
+		}
+	}
+
+	summaryf("Processed %d screenings: %d notes updated, %d stubs created, %d already logged\n", len(screenings), updated, created, unchanged)
+}
+
+// readScreenings reads the viewing log CSV, columns are matched by header name
+func readScreenings(r io.Reader) ([]Screening, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var screenings []Screening
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		screening := Screening{
+			Date:   field(record, "date"),
+			Title:  field(record, "title"),
+			Venue:  field(record, "venue"),
+			Format: field(record, "format"),
+		}
+		if screening.Title == "" {
+			log.Warnf("Skipping row without title: %v\n", record)
+			continue
+		}
+		if year, err := strconv.Atoi(field(record, "year")); err == nil {
+			screening.Year = year
+		}
+
+		screenings = append(screenings, screening)
+	}
+
+	return screenings, nil
+}
+
+// addScreening appends the screening to the note's screenings list, returns false if it was already there
+func addScreening(note *Note, screening Screening) (bool, error) {
+	var screenings []Screening
+	if note.Frontmatter.Has("screenings") {
+		if err := note.Frontmatter.Decode("screenings", &screenings); err != nil {
+			return false, err
+		}
+	}
+
+	for _, existing := range screenings {
+		if existing.Date == screening.Date && existing.Venue == screening.Venue {
+			return false, nil
+		}
+	}
+
+	screenings = append(screenings, screening)
+	return true, note.Frontmatter.Set("screenings", screenings)
+}
+
+// newMovieStub creates an unsaved note for a movie we have no data for, at the path of the imdb
+// path template so the IMDb importer writes the movie to the same note later. A note already at
+// the path, like the stub of an earlier screening outside the notes directory, is read instead.
+func newMovieStub(title string, year int) (*Note, error) {
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", title)
+	if year > 0 {
+		frontmatter.Set("year", year)
+	}
+	frontmatter.Set("tags", []string{"screenings/stub"})
+	note := &Note{Frontmatter: frontmatter, Body: "\n"}
+
+	if err := fillMovieStubFromTMDB(note, title, year); err != nil {
+		log.WithField("Title", title).Warnf("Error searching TMDB: %v\n", err)
+	}
+
+	year = note.Frontmatter.GetInt("year")
+	path, err := notePath("imdb", map[string]string{
+		"title":  title,
+		"year":   yearString(year),
+		"decade": decade(year),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := readNote(path); err == nil {
+		return existing, nil
+	}
+	note.Path = path
+	return note, nil
+}
+
+// fillMovieStubFromTMDB adds the TMDB id, original title, year and cover of the TMDB movie matching
+// the title and year to a stub, the stub is left as is without TMDBAccessToken or a match
+func fillMovieStubFromTMDB(note *Note, title string, year int) error {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" {
+		return nil
+	}
+
+	movie, err := searchTMDBMovie(token, title, year)
+	if err != nil || movie.ID == 0 {
+		return err
+	}
+
+	id := strconv.Itoa(movie.ID)
+	note.Frontmatter.Set(tmdbIdField("movie"), id)
+	// The IMDb importer finds the stub by its imdb_id
+	if imdbID, err := fetchTMDBMovieImdbID(token, movie.ID); err != nil {
+		log.WithField("Title", title).Warnf("Error fetching the IMDb id: %v\n", err)
+	} else if imdbID != "" {
+		note.Frontmatter.Set("imdb_id", imdbID)
+	}
+	setMediaIDs(note.Frontmatter, tmdbIdField("movie"))
+	note.Frontmatter.Set("url", "https://www.themoviedb.org/movie/"+id)
+	if movie.OriginalTitle != "" && movie.OriginalTitle != title {
+		note.Frontmatter.Set("original_title", movie.OriginalTitle)
+	}
+	if year == 0 && len(movie.ReleaseDate) >= 4 {
+		if releaseYear, err := strconv.Atoi(movie.ReleaseDate[:4]); err == nil {
+			note.Frontmatter.Set("year", releaseYear)
+		}
+	}
+	if movie.PosterPath != "" {
+		cover := "https://image.tmdb.org/t/p/w500" + movie.PosterPath
+		note.Frontmatter.Set("cover", cover)
+		note.Body += fmt.Sprintf("![](%s)\n", cover)
+	}
+	if movie.Overview != "" {
+		if note.Body != "\n" {
+			note.Body += "\n"
+		}
+		note.Body += movie.Overview + "\n"
+	}
+	return nil
+}
+
+// fetchTMDBMovieImdbID returns the IMDb id of a TMDB movie, empty if TMDB doesn't know it
+func fetchTMDBMovieImdbID(token string, id int) (string, error) {
+	var ids struct {
+		ImdbID string `json:"imdb_id"`
+	}
+	key := strconv.Itoa(id)
+	if readCache("tmdbimdbid", key, &ids) {
+		return ids.ImdbID, nil
+	}
+
+	url := fmt.Sprintf("https://api.themoviedb.org/3/movie/%d/external_ids", id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &ids)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeCache("tmdbimdbid", key, ids); err != nil {
+		log.Warnf("Error caching TMDB external ids %s: %v\n", key, err)
+	}
+	return ids.ImdbID, nil
+}
+
+// searchTMDBMovie returns the TMDB movie with the title, of the year when it's known. The ID is 0
+// if no result is spelled nearly the same.
+func searchTMDBMovie(token, title string, year int) (tmdbAccountItem, error) {
+	// Search results have the fields of the account list items, without the rating
+	var movie tmdbAccountItem
+	key := fmt.Sprintf("%s (%d)", title, year)
+	if readCache("tmdbsearch", key, &movie) {
+		return movie, nil
+	}
+
+	query := url.Values{}
+	query.Set("query", title)
+	if year > 0 {
+		query.Set("primary_release_year", strconv.Itoa(year))
+	}
+	var response struct {
+		Results []tmdbAccountItem `json:"results"`
+	}
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, "https://api.themoviedb.org/3/search/movie?"+query.Encode(), token, nil, &response)
+	})
+	if err != nil {
+		return movie, err
+	}
+
+	for _, result := range response.Results {
+		if titlematch.Similarity(result.Title, title) >= movieFuzzyMatchScore ||
+			titlematch.Similarity(result.OriginalTitle, title) >= movieFuzzyMatchScore {
+			movie = result
+			break
+		}
+	}
+
+	// Misses are cached too so they aren't searched on every run
+	if err := writeCache("tmdbsearch", key, movie); err != nil {
+		log.Warnf("Error caching TMDB search %s: %v\n", key, err)
+	}
+	return movie, nil
+}
+
+// movieFuzzyMatchScore is the lowest Jaro-Winkler similarity of a fuzzy title match
+const movieFuzzyMatchScore = 0.93
+
+// movieIndex maps normalized titles to note paths
+type movieIndex struct {
+	byTitle     map[string]string
+	byTitleYear map[string]string
+}
+
+// indexMovieNotes indexes the notes in a directory by title and title+year
+func indexMovieNotes(directory string) (*movieIndex, error) {
+	index := &movieIndex{
+		byTitle:     make(map[string]string),
+		byTitleYear: make(map[string]string),
+	}
+
+	paths, err := findNotes(directory)
+	if err != nil {
+		return index, err
+	}
+
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		index.add(path, note.Title(), note.Frontmatter.GetInt("year"))
+		if original := note.Frontmatter.GetString("original_title"); original != "" {
+			index.add(path, original, note.Frontmatter.GetInt("year"))
+		}
+	}
+
+	return index, nil
+}
+
+func (i *movieIndex) add(path, title string, year int) {
+	key := titlematch.Key(title)
+	if _, ok := i.byTitle[key]; !ok {
+		i.byTitle[key] = path
+	}
+	if year > 0 {
+		i.byTitleYear[key+"|"+strconv.Itoa(year)] = path
+	}
+}
+
+// find returns the note path for a title. When the year is known only notes of that year match,
+// so remakes aren't mixed up, and without an exact title match a title of the same year that is
+// spelled nearly the same is used. Without a year the title alone is matched.
+func (i *movieIndex) find(title string, year int) string {
+	key := titlematch.Key(title)
+	if year == 0 {
+		return i.byTitle[key]
+	}
+	if path, ok := i.byTitleYear[key+"|"+strconv.Itoa(year)]; ok {
+		return path
+	}
+
+	best, bestScore := "", movieFuzzyMatchScore
+	suffix := "|" + strconv.Itoa(year)
+	for titleYear, path := range i.byTitleYear {
+		if !strings.HasSuffix(titleYear, suffix) {
+			continue
+		}
+		score := titlematch.JaroWinkler(key, strings.TrimSuffix(titleYear, suffix))
+		if score > bestScore || (score == bestScore && best != "" && path < best) {
+			best, bestScore = path, score
+		}
+	}
+	return best
+}
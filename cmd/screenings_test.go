@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMovieIndexFindYear(t *testing.T) {
+	index := &movieIndex{byTitle: make(map[string]string), byTitleYear: make(map[string]string)}
+	index.add("Heat (1986).md", "Heat", 1986)
+	index.add("Heat (1995).md", "Heat", 1995)
+	index.add("Dune (2021).md", "Dune", 2021)
+
+	tests := []struct {
+		title string
+		year  int
+		want  string
+	}{
+		{"Heat", 1995, "Heat (1995).md"},
+		{"Heat", 1986, "Heat (1986).md"},
+		{"Heat", 0, "Heat (1986).md"},
+		{"Dune", 1984, ""},
+		{"Dune", 0, "Dune (2021).md"},
+	}
+
+	for _, tt := range tests {
+		if got := index.find(tt.title, tt.year); got != tt.want {
+			t.Errorf("find(%q, %d) = %q, want %q", tt.title, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestScreeningsSurviveImdbReimport(t *testing.T) {
+	dir := testVault(t, nil)
+	previousDir := screeningsNotesDir
+	screeningsNotesDir = ""
+	t.Cleanup(func() { screeningsNotesDir = previousDir })
+
+	movie := MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie", MyRating: 9}
+	if err := newMovieNoteWriter().write(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	viewingLog := filepath.Join(t.TempDir(), "screenings.csv")
+	if err := os.WriteFile(viewingLog, []byte("date,title,venue,format,year\n2024-05-01,Heat,Orion,35mm,1995\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parse_screenings(viewingLog)
+
+	movie.MyRating = 10
+	if err := newMovieNoteWriter().write(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readNote(filepath.Join(dir, "imdb/Heat (1995).md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var screenings []Screening
+	if err := note.Frontmatter.Decode("screenings", &screenings); err != nil {
+		t.Fatalf("screenings after reimport: %v", err)
+	}
+	if len(screenings) != 1 || screenings[0].Venue != "Orion" {
+		t.Errorf("screenings after reimport = %+v, want the Orion screening", screenings)
+	}
+	if got := note.Frontmatter.GetInt("my_rating"); got != 10 {
+		t.Errorf("my_rating = %d, want the reimported 10", got)
+	}
+}
+
+func TestScreeningStubsNamedByYear(t *testing.T) {
+	dir := testVault(t, nil)
+	previousDir := screeningsNotesDir
+	screeningsNotesDir = ""
+	t.Cleanup(func() { screeningsNotesDir = previousDir })
+
+	viewingLog := filepath.Join(t.TempDir(), "screenings.csv")
+	csv := "date,title,venue,format,year\n2024-05-01,Heat,Orion,35mm,1986\n2024-06-01,Heat,Orion,70mm,1995\n"
+	if err := os.WriteFile(viewingLog, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parse_screenings(viewingLog)
+
+	for _, name := range []string{"Heat (1986).md", "Heat (1995).md"} {
+		note, err := readNote(filepath.Join(dir, "imdb", name))
+		if err != nil {
+			t.Fatalf("stub %s: %v", name, err)
+		}
+		var screenings []Screening
+		if err := note.Frontmatter.Decode("screenings", &screenings); err != nil || len(screenings) != 1 {
+			t.Errorf("screenings of %s = %+v, %v, want one screening", name, screenings, err)
+		}
+	}
+
+	movie := MovieSeen{ImdbId: "tt0113277", Title: "Heat", Year: 1995, TitleType: "Movie", MyRating: 9}
+	if err := newMovieNoteWriter().write(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "imdb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("imdb has %d notes, want the two stubs", len(entries))
+	}
+	note, err := readNote(filepath.Join(dir, "imdb/Heat (1995).md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := note.Frontmatter.GetString("imdb_id"); got != "tt0113277" {
+		t.Errorf("imdb_id = %q, want the imported movie", got)
+	}
+	var screenings []Screening
+	if err := note.Frontmatter.Decode("screenings", &screenings); err != nil || len(screenings) != 1 || screenings[0].Format != "70mm" {
+		t.Errorf("screenings after import = %+v, %v, want the 70mm screening", screenings, err)
+	}
+}
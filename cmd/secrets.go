@@ -0,0 +1,117 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the keyring service used when a keyring reference only names the secret
+const keyringService = "hermes"
+
+// secretCmd represents the secret command
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage API keys stored in the OS keyring",
+	Long: `Config values can reference secrets instead of containing them:
+
+  SteamAPIKey: keyring://steam          OS keyring, service "hermes", user "steam"
+  SteamAPIKey: keyring://service/user   OS keyring entry with an explicit service
+  SteamAPIKey: env://STEAM_API_KEY      environment variable
+  SteamAPIKey: op://Private/Steam/key   1Password CLI (op read)
+
+References are resolved at startup.`,
+}
+
+// secretSetCmd represents the secret set command
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret in the OS keyring, read from stdin",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service, user := keyringEntry(args[0])
+
+		fmt.Fprintf(os.Stderr, "Enter value for %s: ", args[0])
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			log.Errorf("Error reading secret: %v\n", err)
+			return
+		}
+
+		if err := keyring.Set(service, user, strings.TrimSpace(value)); err != nil {
+			log.Errorf("Error storing secret: %v\n", err)
+			return
+		}
+		log.Infof("Stored secret, use keyring://%s/%s in the config\n", service, user)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+}
+
+// resolveSecrets replaces secret references in the config with the secret values.
+// Resolved values are only kept in memory, never written back to the config file.
+func resolveSecrets() {
+	for _, key := range viper.AllKeys() {
+		value, ok := viper.Get(key).(string)
+		if !ok || !strings.Contains(value, "://") {
+			continue
+		}
+
+		secret, isReference, err := resolveSecret(value)
+		if !isReference {
+			continue
+		}
+		if err != nil {
+			log.Errorf("Error resolving secret for %s: %v\n", key, err)
+			secret = ""
+		}
+		viper.Set(key, secret)
+	}
+}
+
+// resolveSecret resolves a single secret reference, isReference is false for plain values
+func resolveSecret(value string) (secret string, isReference bool, err error) {
+	scheme, ref, _ := strings.Cut(value, "://")
+
+	switch scheme {
+	case "env":
+		secret, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", true, fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return secret, true, nil
+	case "keyring":
+		secret, err := keyring.Get(keyringEntry(ref))
+		return secret, true, err
+	case "op":
+		// The 1Password CLI takes the full op:// reference
+		output, err := exec.Command("op", "read", "--no-newline", value).Output()
+		if err != nil {
+			return "", true, fmt.Errorf("op read: %w", err)
+		}
+		return string(output), true, nil
+	}
+
+	return "", false, nil
+}
+
+// keyringEntry splits a "service/user" keyring reference, a bare name uses the hermes service
+func keyringEntry(ref string) (service, user string) {
+	if service, user, ok := strings.Cut(ref, "/"); ok {
+		return service, user
+	}
+	return keyringService, ref
+}
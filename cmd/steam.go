@@ -0,0 +1,820 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Game represents an owned Steam game
+type Game struct {
+	AppID           int      `json:"AppId"`
+	Name            string   `json:"Name"`
+	PlaytimeMinutes int      `json:"Playtime (mins)"`
+	LastPlayed      int64    `json:"Last Played"`
+	ReleaseDate     string   `json:"Release Date"`
+	Year            int      `json:"Year"`
+	Developers      []string `json:"Developers"`
+	Publishers      []string `json:"Publishers"`
+	Genres          []string `json:"Genres"`
+	HeaderImage     string   `json:"Header Image"`
+	Description     string   `json:"Description"`
+	// ControllerSupport is "full", "partial" or empty for no controller support
+	ControllerSupport string `json:"Controller Support"`
+	// DeckCompatibility is "verified", "playable", "unsupported" or "unknown"
+	DeckCompatibility string `json:"Deck Compatibility"`
+	// Collections are the Steam client collections (categories) of the game
+	Collections []string `json:"Collections"`
+	// PlayModes are "vr", "coop" and "multiplayer" from the store categories
+	PlayModes []string `json:"Play Modes"`
+	// Artwork is the SteamGridDB image used instead of the header image, credited to ArtworkAuthor
+	Artwork       string `json:"Artwork"`
+	ArtworkAuthor string `json:"Artwork Author"`
+	ArtworkPage   string `json:"Artwork Page"`
+	// Achievements is the number of achievements in the game, AchievementsUnlocked how many you have
+	Achievements         int `json:"Achievements"`
+	AchievementsUnlocked int `json:"Achievements Unlocked"`
+	// CompletedAt is the unix time the last achievement was unlocked, 0 until all of them are
+	CompletedAt int64 `json:"Completed At"`
+	// Purchased is set for games in the purchases ledger, PricePaid is 0 for bundled and free games
+	Purchased bool    `json:"Purchased"`
+	PricePaid float64 `json:"Price Paid"`
+	Acquired  string  `json:"Acquired"`
+	Bundle    string  `json:"Bundle"`
+	// StoreStatus is "delisted" for games removed from the store and "region-locked" for games not
+	// sold in your region
+	StoreStatus string `json:"Store Status"`
+	// Mods are the subscribed Workshop items
+	Mods []steamMod `json:"Mods"`
+}
+
+// deckCompatibility maps the resolved_category of the Deck compatibility report to a name
+var deckCompatibility = map[int]string{
+	0: "unknown",
+	1: "unsupported",
+	2: "playable",
+	3: "verified",
+}
+
+// steamOwnedGame is a game in the GetOwnedGames response
+type steamOwnedGame struct {
+	AppID           int    `json:"appid"`
+	Name            string `json:"name"`
+	PlaytimeForever int    `json:"playtime_forever"`
+	RtimeLastPlayed int64  `json:"rtime_last_played"`
+}
+
+// steamAppDetails is the subset of the store appdetails response we use
+type steamAppDetails struct {
+	Name        string `json:"name"`
+	ReleaseDate struct {
+		ComingSoon bool   `json:"coming_soon"`
+		Date       string `json:"date"`
+	} `json:"release_date"`
+	Developers []string `json:"developers"`
+	Publishers []string `json:"publishers"`
+	Genres     []struct {
+		Description string `json:"description"`
+	} `json:"genres"`
+	HeaderImage       string          `json:"header_image"`
+	ShortDescription  string          `json:"short_description"`
+	ControllerSupport string          `json:"controller_support"`
+	Categories        []steamCategory `json:"categories"`
+	// StoreStatus isn't in the store response, it's cached with the details: empty for games in the
+	// store, steamDelisted or steamRegionLocked
+	StoreStatus string `json:"hermes_store_status,omitempty"`
+}
+
+// steamCategory is a store category like Single-player or Steam Achievements
+type steamCategory struct {
+	ID int `json:"id"`
+}
+
+// steamPlayModes maps the store category ids to the play modes tagged as play/<mode>
+var steamPlayModes = map[int]string{
+	1:  "multiplayer", // Multi-player
+	20: "multiplayer", // MMO
+	27: "multiplayer", // Cross-Platform Multiplayer
+	36: "multiplayer", // Online PvP
+	37: "multiplayer", // Shared/Split Screen PvP
+	47: "multiplayer", // LAN PvP
+	49: "multiplayer", // PvP
+	9:  "coop",        // Co-op
+	38: "coop",        // Online Co-op
+	39: "coop",        // Shared/Split Screen Co-op
+	48: "coop",        // LAN Co-op
+	31: "vr",          // VR Support
+	53: "vr",          // VR Supported
+	54: "vr",          // VR Only
+}
+
+// SteamOverrides is the manual overrides file applied during import
+type SteamOverrides struct {
+	// Skip lists appids that are never imported
+	Skip []int `yaml:"skip"`
+	// Games holds per-appid corrections
+	Games map[int]SteamOverride `yaml:"games"`
+}
+
+// SteamOverride corrects the data of a single game
+type SteamOverride struct {
+	Title string `yaml:"title"`
+	Year  int    `yaml:"year"`
+	// Frontmatter fields are written as-is, replacing any generated value
+	Frontmatter map[string]interface{} `yaml:"frontmatter"`
+}
+
+var (
+	steamOverridesFile   string
+	steamCollectionsFile string
+	steamPurchasesFile   string
+	steamCheckDelisted   bool
+)
+
+// steamCmd represents the steam command
+var steamCmd = &cobra.Command{
+	Use:   "steam [file]",
+	Short: "Import owned games from the Steam API",
+	Long: `Fetch the list of owned games with the Steam Web API and write one note per game.
+
+Requires SteamAPIKey and SteamID in the config. Game details are fetched from the
+Steam store API and cached in CacheDir.
+
+Collections from the Steam client are added as collection/<name> tags with --collections,
+pointing to either userdata/<id>/7/remote/sharedconfig.vdf (older clients) or
+userdata/<id>/config/cloudstorage/cloud-storage-namespace-1.json. Dynamic collections are skipped.
+
+Games played for over two hours but not in the last SteamAbandonedMonths months
+(default 6, 0 disables) are tagged backlog/abandoned.
+
+Achievement progress of played games is written as achievements: 12/40. Games with every
+achievement unlocked are tagged completion/100 and listed by the year of the last unlock in
+"Completed games.md" in StatsOutputDir, games past one of the SteamCompletionThresholds
+(default [50]) are tagged completion/<threshold>-plus. Achievements need the game details of
+the profile to be public, games of a private profile have none.
+
+The subscribed Workshop mods of played games are listed with a link and the date they were last
+updated between hermes:steam-mods markers in the note, their number is written as mods.
+
+Games the store API no longer knows are tagged steam/delisted and keep the details cached
+before they were removed, games only sold in other regions are tagged steam/region-locked. Both
+are listed in "Delisted games.md" in StatsOutputDir. Cached games are only checked again with
+--check-delisted. The store is asked for SteamRegion, a country code like fi (default the region
+of your IP address), and then for SteamFallbackRegion (default us, de if SteamRegion is us).
+
+The price, date and bundle of purchases are read from a ledger CSV kept by hand, --purchases
+(default purchases.csv, a missing file is fine):
+
+  appid,price,date,bundle
+  620,9.99,2021-05-02,
+  1145360,0,2022-11-20,Humble Choice November 2022
+
+They are written as price_paid, acquired and bundle, and the yearly totals to "Game spending.md"
+in StatsOutputDir.
+
+With --json-out the games are written to stdout as JSON lines after fetching their details,
+--json-in reads them back from the file argument ("-" or none for stdin) instead of the Steam API.
+
+Games can be skipped or corrected with an overrides file:
+
+  skip:
+    - 1234
+  games:
+    620:
+      title: Portal 2
+      year: 2011
+      frontmatter:
+        completed: true`,
+	ValidArgsFunction: completeInputFile("json"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing Steam library...")
+		parse_steam(inputFile(args, "-"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(steamCmd)
+
+	steamCmd.Flags().StringVarP(&steamOverridesFile, "overrides", "o", "steam_overrides.yaml", "Steam overrides file")
+	steamCmd.Flags().StringVarP(&steamCollectionsFile, "collections", "c", "", "Steam client sharedconfig.vdf or cloud-storage-namespace-1.json to read collections from")
+	steamCmd.Flags().BoolVar(&steamCheckDelisted, "check-delisted", false, "Fetch the store details of cached games again to find delisted ones")
+	steamCmd.Flags().StringVarP(&steamPurchasesFile, "purchases", "p", "purchases.csv", "Purchases ledger CSV (appid, price, date, bundle)")
+	steamCmd.MarkFlagFilename("overrides", "yaml", "yml")
+	steamCmd.MarkFlagFilename("collections", "vdf", "json")
+	steamCmd.MarkFlagFilename("purchases", "csv")
+}
+
+func parse_steam(filename string) {
+	overrides, err := readSteamOverrides(steamOverridesFile)
+	if err != nil {
+		log.Errorf("Error reading overrides %s: %v\n", steamOverridesFile, err)
+		return
+	}
+
+	purchases, err := readSteamPurchases(steamPurchasesFile)
+	if err != nil {
+		log.Errorf("Error reading purchases %s: %v\n", steamPurchasesFile, err)
+		return
+	}
+	purchasesByApp := steamPurchasesByApp(purchases)
+
+	var games []Game
+	skipped := 0
+	if importJSONIn {
+		games, err = readSteamJSONLines(filename)
+		if err != nil {
+			log.Errorf("Error reading %s: %v\n", filename, err)
+			return
+		}
+		for i := range games {
+			applySteamOverride(&games[i], overrides.Games[games[i].AppID])
+			applySteamPurchase(&games[i], purchasesByApp)
+		}
+	} else {
+		games, skipped, err = fetchSteamGames(overrides, purchasesByApp)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(games); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d games, skipped %d\n", len(games), skipped)
+		return
+	}
+
+	if err := writeGamesToJson(games); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err = writeGamesToMarkdown(games, overrides)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	if len(purchases) > 0 {
+		if err := writeGameSpendingNote(purchases); err != nil {
+			log.Errorf("Error writing game spending: %v\n", err)
+		}
+	}
+
+	summaryf("Processed %d games, skipped %d\n", len(games), skipped)
+}
+
+// readSteamJSONLines reads the games written by --json-out
+func readSteamJSONLines(filename string) ([]Game, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	return readJSONLines[Game](input)
+}
+
+// fetchSteamGames fetches the owned games with their details, returns the number of games skipped
+// by the overrides
+func fetchSteamGames(overrides SteamOverrides, purchasesByApp map[int]SteamPurchase) ([]Game, int, error) {
+	apiKey := viper.GetString("SteamAPIKey")
+	steamID := viper.GetString("SteamID")
+	if apiKey == "" || steamID == "" {
+		return nil, 0, errors.New("SteamAPIKey and SteamID must be set in the config")
+	}
+
+	owned, err := fetchOwnedGames(apiKey, steamID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching owned games: %w", err)
+	}
+
+	var collections map[int][]string
+	if steamCollectionsFile != "" {
+		collections, err = readSteamCollections(steamCollectionsFile)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading collections %s: %w", steamCollectionsFile, err)
+		}
+	}
+
+	skip := make(map[int]bool)
+	for _, appID := range overrides.Skip {
+		skip[appID] = true
+	}
+
+	var games []Game
+	for _, ownedGame := range owned {
+		if skip[ownedGame.AppID] {
+			log.WithField("AppId", ownedGame.AppID).Debug("Skipping game listed in overrides")
+			continue
+		}
+
+		game := Game{
+			AppID:           ownedGame.AppID,
+			Name:            ownedGame.Name,
+			PlaytimeMinutes: ownedGame.PlaytimeForever,
+			LastPlayed:      ownedGame.RtimeLastPlayed,
+			Collections:     collections[ownedGame.AppID],
+		}
+
+		err := withRetry(func() error { return enrichGame(&game) })
+		if err != nil {
+			log.WithField("AppId", game.AppID).Warnf("Error fetching store details: %v\n", err)
+		}
+		queueRetry("steam", strconv.Itoa(game.AppID), game, err)
+
+		applySteamOverride(&game, overrides.Games[game.AppID])
+		applySteamPurchase(&game, purchasesByApp)
+
+		games = append(games, game)
+	}
+
+	return games, len(owned) - len(games), nil
+}
+
+// readSteamOverrides reads the overrides file, a missing file means no overrides
+func readSteamOverrides(filename string) (SteamOverrides, error) {
+	var overrides SteamOverrides
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	if err != nil {
+		return overrides, err
+	}
+
+	err = yaml.Unmarshal(data, &overrides)
+	return overrides, err
+}
+
+// fetchOwnedGames returns the games owned by the Steam user
+func fetchOwnedGames(apiKey, steamID string) ([]steamOwnedGame, error) {
+	params := url.Values{}
+	params.Set("key", apiKey)
+	params.Set("steamid", steamID)
+	params.Set("include_appinfo", "1")
+	params.Set("include_played_free_games", "1")
+	params.Set("format", "json")
+
+	var response struct {
+		Response struct {
+			GameCount int              `json:"game_count"`
+			Games     []steamOwnedGame `json:"games"`
+		} `json:"response"`
+	}
+	err := getSteamJSON("https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?"+params.Encode(), &response)
+
+	return response.Response.Games, err
+}
+
+// applySteamOverride replaces the fetched title and year with the manual corrections
+func applySteamOverride(game *Game, override SteamOverride) {
+	if override.Title != "" {
+		game.Name = override.Title
+	}
+	if override.Year > 0 {
+		game.Year = override.Year
+	}
+}
+
+// retryGame re-enriches a queued game and rewrites its note
+func retryGame(data json.RawMessage) error {
+	var game Game
+	if err := json.Unmarshal(data, &game); err != nil {
+		return err
+	}
+
+	if err := withRetry(func() error { return enrichGame(&game) }); err != nil {
+		return err
+	}
+
+	overrides, err := readSteamOverrides(steamOverridesFile)
+	if err != nil {
+		return err
+	}
+	override := overrides.Games[game.AppID]
+	applySteamOverride(&game, override)
+
+	purchases, err := readSteamPurchases(steamPurchasesFile)
+	if err != nil {
+		return err
+	}
+	applySteamPurchase(&game, steamPurchasesByApp(purchases))
+
+	_, err = writeGameToMarkdown(game, override, newNoteRelocator("steam_appid"))
+	return err
+}
+
+// enrichGame fills in store details and Steam Deck compatibility for a game. Only a failed store
+// details lookup is an error, the game is written without the optional details that fail.
+func enrichGame(game *Game) error {
+	gameLogger := log.WithField("AppId", game.AppID)
+
+	deckCompatibility, err := fetchDeckCompatibility(game.AppID)
+	if err != nil {
+		gameLogger.Warnf("Error fetching Steam Deck compatibility: %v\n", err)
+	} else {
+		game.DeckCompatibility = deckCompatibility
+	}
+
+	details, err := fetchAppDetails(game.AppID)
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		// Never cached and not in the store, all that's left is the name from the library
+		game.StoreStatus = steamDelisted
+		return nil
+	}
+	game.StoreStatus = details.StoreStatus
+
+	game.ReleaseDate = details.ReleaseDate.Date
+	game.Developers = details.Developers
+	game.Publishers = details.Publishers
+	game.HeaderImage = details.HeaderImage
+	game.Description = details.ShortDescription
+	game.ControllerSupport = details.ControllerSupport
+	game.PlayModes = nil
+	for _, category := range details.Categories {
+		if mode, ok := steamPlayModes[category.ID]; ok && !containsString(game.PlayModes, mode) {
+			game.PlayModes = append(game.PlayModes, mode)
+		}
+	}
+	sort.Strings(game.PlayModes)
+	for _, genre := range details.Genres {
+		game.Genres = append(game.Genres, genre.Description)
+	}
+
+	// Release dates are localized strings like "18 Apr, 2011", the year is always last
+	fields := strings.Fields(details.ReleaseDate.Date)
+	if len(fields) > 0 {
+		if year, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			game.Year = year
+		}
+	}
+
+	if viper.GetString("SteamGridDBAPIKey") != "" {
+		art, err := fetchSteamGridDBArt(game.AppID)
+		if err != nil {
+			gameLogger.Warnf("Error fetching SteamGridDB artwork: %v\n", err)
+		} else if art != nil {
+			game.Artwork, game.ArtworkAuthor, game.ArtworkPage = art.URL, art.Author, art.Page
+		}
+	}
+
+	// Unplayed games have nothing unlocked
+	if game.PlaytimeMinutes > 0 {
+		achievements, err := fetchSteamAchievements(viper.GetString("SteamAPIKey"), viper.GetString("SteamID"), game.AppID, game.LastPlayed)
+		if err != nil {
+			gameLogger.Warnf("Error fetching Steam achievements: %v\n", err)
+		} else {
+			game.Achievements, game.AchievementsUnlocked = achievements.Total, achievements.Unlocked
+			game.CompletedAt = 0
+			if achievements.Total > 0 && achievements.Unlocked == achievements.Total {
+				game.CompletedAt = achievements.LastUnlock
+			}
+		}
+
+		mods, err := fetchSteamMods(viper.GetString("SteamAPIKey"), viper.GetString("SteamID"), game.AppID, game.LastPlayed)
+		if err != nil {
+			gameLogger.Warnf("Error fetching Workshop mods: %v\n", err)
+		} else {
+			game.Mods = mods
+		}
+	}
+
+	return nil
+}
+
+// fetchAppDetails fetches the store details of an app, returns nil if the store doesn't know the app.
+// Apps the store no longer resolves keep their last cached details with StoreStatus set to delisted.
+func fetchAppDetails(appID int) (*steamAppDetails, error) {
+	key := strconv.Itoa(appID)
+
+	// Entries cached before categories were stored have none at all, they are fetched again
+	var cached steamAppDetails
+	isCached := readCache("steam", key, &cached) && cached.Categories != nil
+	if isCached && !steamCheckDelisted {
+		return &cached, nil
+	}
+
+	// Without SteamRegion the store API answers for the region of the caller's IP address
+	details, err := fetchStoreAppDetails(key, strings.ToLower(viper.GetString("SteamRegion")))
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		// Games sold in another region are region locked
+		details, err = fetchStoreAppDetails(key, steamFallbackRegion())
+		if err != nil {
+			return nil, err
+		}
+		if details != nil {
+			details.StoreStatus = steamRegionLocked
+		}
+	}
+	if details == nil {
+		if !isCached {
+			return nil, nil
+		}
+		log.WithField("AppId", appID).Info("Game no longer in the Steam store, keeping cached details")
+		details = &cached
+		details.StoreStatus = steamDelisted
+	}
+
+	if details.Categories == nil {
+		details.Categories = []steamCategory{}
+	}
+	if err := writeCache("steam", key, details); err != nil {
+		log.Warnf("Error caching Steam app %d: %v\n", appID, err)
+	}
+
+	return details, nil
+}
+
+// fetchStoreAppDetails calls the store appdetails API, for the given country code if set.
+// Returns nil if the store doesn't know the app.
+func fetchStoreAppDetails(key, region string) (*steamAppDetails, error) {
+	url := "https://store.steampowered.com/api/appdetails?appids=" + key
+	if region != "" {
+		url += "&cc=" + region
+	}
+
+	var response map[string]struct {
+		Success bool            `json:"success"`
+		Data    steamAppDetails `json:"data"`
+	}
+	if err := getSteamJSON(url, &response); err != nil {
+		return nil, err
+	}
+
+	app, ok := response[key]
+	if !ok || !app.Success {
+		return nil, nil
+	}
+	return &app.Data, nil
+}
+
+// fetchDeckCompatibility returns the Steam Deck compatibility category of an app
+func fetchDeckCompatibility(appID int) (string, error) {
+	key := strconv.Itoa(appID)
+
+	var report struct {
+		ResolvedCategory int `json:"resolved_category"`
+	}
+	if !readCache("steamdeck", key, &report) {
+		var response struct {
+			Success int             `json:"success"`
+			Results json.RawMessage `json:"results"`
+		}
+		err := getSteamJSON("https://store.steampowered.com/saleaction/ajaxgetdeckappcompatibilityreport?nAppID="+key, &response)
+		if err != nil {
+			return "", err
+		}
+		// Apps that were never reviewed for the Deck have an empty list instead of a report
+		if strings.HasPrefix(string(response.Results), "{") {
+			if err := json.Unmarshal(response.Results, &report); err != nil {
+				return "", err
+			}
+		}
+
+		if err := writeCache("steamdeck", key, report); err != nil {
+			log.Warnf("Error caching Steam Deck compatibility %d: %v\n", appID, err)
+		}
+	}
+
+	category, ok := deckCompatibility[report.ResolvedCategory]
+	if !ok {
+		return "unknown", nil
+	}
+	return category, nil
+}
+
+// getSteamJSON performs a GET request against a Steam API and decodes the JSON response
+func getSteamJSON(url string, v interface{}) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func writeGamesToJson(games []Game) error {
+	jsonData, err := json.Marshal(games)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("steam.json", jsonData)
+}
+
+// writeGameToMarkdown writes game info to a markdown file
+func writeGameToMarkdown(game Game, override SteamOverride, relocator *noteRelocator) (string, error) {
+	developer := ""
+	if len(game.Developers) > 0 {
+		developer = game.Developers[0]
+	}
+
+	filePath, err := notePath("steam", map[string]string{
+		"title":     game.Name,
+		"year":      yearString(game.Year),
+		"decade":    decade(game.Year),
+		"developer": developer,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	appID := strconv.Itoa(game.AppID)
+	if err := relocator.relocate(appID, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{"steam/game"}
+	if game.PlaytimeMinutes == 0 {
+		tags = append(tags, "steam/unplayed")
+	}
+	if game.DeckCompatibility != "" && game.DeckCompatibility != "unknown" {
+		tags = append(tags, "deck/"+game.DeckCompatibility)
+	}
+	if game.ControllerSupport != "" {
+		tags = append(tags, "controller/"+game.ControllerSupport)
+	}
+	if isAbandoned(game, time.Now()) {
+		tags = append(tags, "backlog/abandoned")
+	}
+	for _, collection := range game.Collections {
+		if slug := slugify(collection); slug != "" {
+			tags = append(tags, "collection/"+slug)
+		}
+	}
+	for _, mode := range game.PlayModes {
+		tags = append(tags, "play/"+mode)
+	}
+	if game.StoreStatus != "" {
+		tags = append(tags, "steam/"+game.StoreStatus)
+	}
+	tags = append(tags, completionTags(game)...)
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", game.Name)
+	if aliases := noteAliases(filePath, game.Name); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set("steam_appid", appID)
+	frontmatter.Set("url", "https://store.steampowered.com/app/"+appID)
+	if game.Year > 0 {
+		frontmatter.Set("year", game.Year)
+	}
+	frontmatter.Set("playtime_hours", float64(game.PlaytimeMinutes/6)/10)
+	if game.LastPlayed > 0 {
+		frontmatter.Set("last_played", localDate(time.Unix(game.LastPlayed, 0)))
+	}
+	if game.Achievements > 0 {
+		frontmatter.Set("achievements", fmt.Sprintf("%d/%d", game.AchievementsUnlocked, game.Achievements))
+		frontmatter.Set("achievement_percent", achievementPercent(game))
+	}
+	if game.CompletedAt > 0 {
+		frontmatter.Set("completed_date", localDate(time.Unix(game.CompletedAt, 0)))
+	}
+	if len(game.Mods) > 0 {
+		frontmatter.Set("mods", len(game.Mods))
+	}
+	if game.Purchased {
+		frontmatter.Set("price_paid", game.PricePaid)
+		if game.Acquired != "" {
+			frontmatter.Set("acquired", game.Acquired)
+		}
+		if game.Bundle != "" {
+			frontmatter.Set("bundle", game.Bundle)
+		}
+	}
+	if len(game.Developers) > 0 {
+		frontmatter.Set("developers", game.Developers)
+	}
+	if len(game.Publishers) > 0 {
+		frontmatter.Set("publishers", game.Publishers)
+	}
+	if len(game.Genres) > 0 {
+		frontmatter.Set("genres", game.Genres)
+	}
+	if game.Artwork != "" {
+		frontmatter.Set("cover", game.Artwork)
+		frontmatter.Set("cover_author", game.ArtworkAuthor)
+		frontmatter.Set("cover_source", game.ArtworkPage)
+	} else if game.HeaderImage != "" {
+		frontmatter.Set("cover", game.HeaderImage)
+	}
+	if game.DeckCompatibility != "" {
+		frontmatter.Set("deck_verified", game.DeckCompatibility)
+	}
+	if game.ControllerSupport != "" {
+		frontmatter.Set("controller_support", game.ControllerSupport)
+	}
+	// Only set for games with store details, unknown isn't the same as no support
+	if game.ReleaseDate != "" {
+		frontmatter.Set("vr", containsString(game.PlayModes, "vr"))
+		frontmatter.Set("coop", containsString(game.PlayModes, "coop"))
+		frontmatter.Set("multiplayer", containsString(game.PlayModes, "multiplayer"))
+	}
+	frontmatter.Set("tags", tags)
+
+	// Sorted so the override keys are added in a stable order
+	keys := make([]string, 0, len(override.Frontmatter))
+	for key := range override.Frontmatter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := frontmatter.Set(key, override.Frontmatter[key]); err != nil {
+			return "", err
+		}
+	}
+
+	body := "\n"
+	if game.Artwork != "" {
+		body += fmt.Sprintf("![](%s)\n\n", game.Artwork)
+	} else if game.HeaderImage != "" {
+		body += fmt.Sprintf("![](%s)\n\n", game.HeaderImage)
+	}
+	if game.Description != "" {
+		body += game.Description + "\n"
+	}
+	if len(game.Mods) > 0 {
+		body = replaceSection(body, "steam-mods", steamModsSection(game.Mods))
+	}
+	if game.Artwork == "" && game.HeaderImage == "" {
+		body += todoTasks("find cover")
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(appID, note)
+	return filePath, note.Write()
+}
+
+// abandonedMinPlaytime is how long a game must have been played to count as abandoned rather than untried
+const abandonedMinPlaytime = 2 * 60
+
+// isAbandoned returns true for games that were played for a while but not in SteamAbandonedMonths
+func isAbandoned(game Game, now time.Time) bool {
+	months := viper.GetInt("SteamAbandonedMonths")
+	if months <= 0 || game.LastPlayed == 0 || game.PlaytimeMinutes <= abandonedMinPlaytime {
+		return false
+	}
+	return time.Unix(game.LastPlayed, 0).Before(now.AddDate(0, -months, 0))
+}
+
+// writeGamesToMarkdown writes a list of games to markdown files
+func writeGamesToMarkdown(games []Game, overrides SteamOverrides) error {
+	relocator := newNoteRelocator("steam_appid")
+	var entries, completed, delisted, regionLocked []indexEntry
+	for _, game := range games {
+		path, err := writeGameToMarkdown(game, overrides.Games[game.AppID], relocator)
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: game.Name, Year: game.Year})
+		if year := completionYear(game); year > 0 {
+			completed = append(completed, indexEntry{Path: path, Title: game.Name, Year: year})
+		}
+		switch game.StoreStatus {
+		case steamDelisted:
+			delisted = append(delisted, indexEntry{Path: path, Title: game.Name, Year: game.Year})
+		case steamRegionLocked:
+			regionLocked = append(regionLocked, indexEntry{Path: path, Title: game.Name, Year: game.Year})
+		}
+	}
+	if err := writeCompletedGamesNote(completed); err != nil {
+		return err
+	}
+	if err := writeDelistedGamesNote(delisted, regionLocked); err != nil {
+		return err
+	}
+	return writeIndexNote("steam", entries)
+}
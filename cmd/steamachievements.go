@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// steamAchievements is the achievement progress of a player in a game, Total is 0 for games without achievements
+type steamAchievements struct {
+	Total    int `json:"total"`
+	Unlocked int `json:"unlocked"`
+	// LastUnlock is the unix time of the latest unlocked achievement
+	LastUnlock int64 `json:"last_unlock"`
+}
+
+// fetchSteamAchievements returns the achievement progress of a game. Progress only changes by playing,
+// so it's cached by the last played time and fetched again after the game has been played.
+func fetchSteamAchievements(apiKey, steamID string, appID int, lastPlayed int64) (steamAchievements, error) {
+	var achievements steamAchievements
+	key := fmt.Sprintf("%d-%d", appID, lastPlayed)
+	if readCache("steamachievements", key, &achievements) {
+		return achievements, nil
+	}
+
+	params := url.Values{}
+	params.Set("key", apiKey)
+	params.Set("steamid", steamID)
+	params.Set("appid", strconv.Itoa(appID))
+
+	var response struct {
+		PlayerStats struct {
+			Achievements []struct {
+				Achieved   int   `json:"achieved"`
+				UnlockTime int64 `json:"unlocktime"`
+			} `json:"achievements"`
+		} `json:"playerstats"`
+	}
+	err := getSteamJSON("https://api.steampowered.com/ISteamUserStats/GetPlayerAchievements/v1/?"+params.Encode(), &response)
+	// Games without stats are a bad request and every game of a private profile is forbidden,
+	// they are cached as having no achievements until the game is played again
+	var statusErr httpStatusError
+	if err != nil && !(errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusBadRequest || statusErr.StatusCode == http.StatusForbidden)) {
+		return achievements, err
+	}
+
+	for _, achievement := range response.PlayerStats.Achievements {
+		achievements.Total++
+		if achievement.Achieved == 1 {
+			achievements.Unlocked++
+			if achievement.UnlockTime > achievements.LastUnlock {
+				achievements.LastUnlock = achievement.UnlockTime
+			}
+		}
+	}
+
+	if err := writeCache("steamachievements", key, achievements); err != nil {
+		log.Warnf("Error caching Steam achievements %d: %v\n", appID, err)
+	}
+	return achievements, nil
+}
+
+// achievementPercent returns the share of unlocked achievements, rounded down so only all of them is 100
+func achievementPercent(game Game) int {
+	if game.Achievements == 0 {
+		return 0
+	}
+	return game.AchievementsUnlocked * 100 / game.Achievements
+}
+
+// completionTags returns completion/100 for games with every achievement unlocked and
+// completion/<threshold>-plus for each of the SteamCompletionThresholds reached
+func completionTags(game Game) []string {
+	if game.Achievements == 0 {
+		return nil
+	}
+	percent := achievementPercent(game)
+
+	var tags []string
+	if percent == 100 {
+		tags = append(tags, "completion/100")
+	}
+	thresholds := viper.GetIntSlice("SteamCompletionThresholds")
+	sort.Ints(thresholds)
+	for _, threshold := range thresholds {
+		if threshold > 0 && threshold < 100 && percent >= threshold {
+			tags = append(tags, fmt.Sprintf("completion/%d-plus", threshold))
+		}
+	}
+	return tags
+}
+
+// writeCompletedGamesNote lists the games with every achievement unlocked by the year they were completed,
+// the entry year is the completion year
+func writeCompletedGamesNote(entries []indexEntry) error {
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	years := make(map[int][]indexEntry)
+	for _, entry := range entries {
+		years[entry.Year] = append(years[entry.Year], entry)
+	}
+	var keys []int
+	for year := range years {
+		keys = append(keys, year)
+	}
+	// Latest completions first
+	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+
+	var sb strings.Builder
+	if len(keys) == 0 {
+		sb.WriteString("No games with every achievement unlocked.\n")
+	}
+	for i, year := range keys {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		group := years[year]
+		sort.Slice(group, func(i, j int) bool {
+			return strings.ToLower(group[i].Title) < strings.ToLower(group[j].Title)
+		})
+		sb.WriteString(fmt.Sprintf("## %d (%d)\n\n", year, len(group)))
+		for _, entry := range group {
+			sb.WriteString("- " + wikilink(entry.Path) + "\n")
+		}
+	}
+
+	return writeReportNote(filepath.Join(outputDir, "Completed games.md"), "Completed games", "completed", sb.String())
+}
+
+// completionYear returns the year a game was completed in, 0 if it isn't
+func completionYear(game Game) int {
+	if game.CompletedAt == 0 {
+		return 0
+	}
+	return time.Unix(game.CompletedAt, 0).In(dateLocation()).Year()
+}
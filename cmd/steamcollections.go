@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// readSteamCollections returns the collections of each appid from the Steam client's local config.
+// The client used to keep collections in sharedconfig.vdf, newer clients sync them to
+// cloud-storage-namespace-1.json, both are supported.
+func readSteamCollections(filename string) (map[int][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(strings.ToLower(filename), ".json") {
+		return readSteamCloudCollections(file)
+	}
+	return readSteamSharedConfig(file)
+}
+
+// steamCloudCollection is a user collection in the cloud storage namespace
+type steamCloudCollection struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Added []int  `json:"added"`
+}
+
+// steamBuiltinCollections name the collections of the Steam client that have no name of their own
+var steamBuiltinCollections = map[string]string{
+	"favorite": "Favorites",
+	"hidden":   "Hidden",
+}
+
+// readSteamCloudCollections reads the user collections from cloud-storage-namespace-1.json
+func readSteamCloudCollections(r io.Reader) (map[int][]string, error) {
+	// The file is a list of [key, entry] pairs, the collection itself is JSON encoded in the entry value
+	var entries [][]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	collections := make(map[int][]string)
+	for _, pair := range entries {
+		if len(pair) != 2 {
+			continue
+		}
+		var key string
+		if json.Unmarshal(pair[0], &key) != nil || !strings.HasPrefix(key, "user-collections.") {
+			continue
+		}
+		var entry struct {
+			Value     string `json:"value"`
+			IsDeleted bool   `json:"is_deleted"`
+		}
+		if err := json.Unmarshal(pair[1], &entry); err != nil {
+			return nil, err
+		}
+		if entry.IsDeleted || entry.Value == "" {
+			continue
+		}
+
+		// Dynamic collections only have a filter, their games can't be resolved from the file
+		var collection steamCloudCollection
+		if err := json.Unmarshal([]byte(entry.Value), &collection); err != nil {
+			return nil, fmt.Errorf("collection %s: %w", key, err)
+		}
+		name := collection.Name
+		if name == "" {
+			name = steamBuiltinCollections[collection.ID]
+		}
+		if name == "" {
+			continue
+		}
+		for _, appID := range collection.Added {
+			collections[appID] = append(collections[appID], name)
+		}
+	}
+
+	for appID := range collections {
+		sort.Strings(collections[appID])
+	}
+	return collections, nil
+}
+
+// readSteamSharedConfig reads the categories of the apps from sharedconfig.vdf
+func readSteamSharedConfig(r io.Reader) (map[int][]string, error) {
+	root, err := parseVDF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := vdfPath(root, "UserRoamingConfigStore", "Software", "Valve", "Steam", "apps")
+	if apps == nil {
+		return nil, fmt.Errorf("no apps in sharedconfig.vdf")
+	}
+
+	collections := make(map[int][]string)
+	for key, value := range apps {
+		appID, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		app, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tags := vdfPath(app, "tags")
+		for _, tag := range tags {
+			if name, ok := tag.(string); ok {
+				collections[appID] = append(collections[appID], name)
+			}
+		}
+		sort.Strings(collections[appID])
+	}
+
+	return collections, nil
+}
+
+// vdfPath returns the nested section at path, keys are matched case-insensitively like Steam does
+func vdfPath(section map[string]interface{}, path ...string) map[string]interface{} {
+	for _, name := range path {
+		var next map[string]interface{}
+		for key, value := range section {
+			if strings.EqualFold(key, name) {
+				next, _ = value.(map[string]interface{})
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		section = next
+	}
+	return section
+}
+
+// parseVDF parses Valve's KeyValues text format into nested maps of strings
+func parseVDF(r io.Reader) (map[string]interface{}, error) {
+	tokens, err := vdfTokens(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var parse func(i int) (map[string]interface{}, int, error)
+	parse = func(i int) (map[string]interface{}, int, error) {
+		section := make(map[string]interface{})
+		for i < len(tokens) {
+			key := tokens[i]
+			if key == "}" {
+				return section, i + 1, nil
+			}
+			if i+1 >= len(tokens) {
+				return nil, i, fmt.Errorf("missing value for %q", key)
+			}
+			if tokens[i+1] == "{" {
+				child, next, err := parse(i + 2)
+				if err != nil {
+					return nil, next, err
+				}
+				section[key] = child
+				i = next
+				continue
+			}
+			section[key] = tokens[i+1]
+			i += 2
+		}
+		return section, i, nil
+	}
+
+	root, _, err := parse(0)
+	return root, err
+}
+
+// vdfTokens splits VDF text into quoted strings and braces, skipping comments
+func vdfTokens(r io.Reader) ([]string, error) {
+	var tokens []string
+	reader := bufio.NewReader(r)
+	for {
+		c, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case c == '{' || c == '}':
+			tokens = append(tokens, string(c))
+		case c == '"':
+			var sb strings.Builder
+			for {
+				c, _, err := reader.ReadRune()
+				if err != nil {
+					return nil, fmt.Errorf("unterminated string: %w", err)
+				}
+				if c == '"' {
+					break
+				}
+				if c == '\\' {
+					escaped, _, err := reader.ReadRune()
+					if err != nil {
+						return nil, err
+					}
+					switch escaped {
+					case 'n':
+						c = '\n'
+					case 't':
+						c = '\t'
+					default:
+						c = escaped
+					}
+				}
+				sb.WriteRune(c)
+			}
+			tokens = append(tokens, sb.String())
+		case c == '/':
+			// Comments run to the end of the line
+			if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+		}
+	}
+}
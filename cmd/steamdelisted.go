@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Store statuses of games that aren't sold in the store
+const (
+	steamDelisted     = "delisted"
+	steamRegionLocked = "region-locked"
+)
+
+// steamFallbackRegion returns the store region games missing from your own region are looked up
+// in, SteamFallbackRegion unless it's your SteamRegion, then the US or Germany for US accounts
+func steamFallbackRegion() string {
+	own := strings.ToLower(viper.GetString("SteamRegion"))
+	fallback := strings.ToLower(viper.GetString("SteamFallbackRegion"))
+	if fallback != "" && fallback != own {
+		return fallback
+	}
+	if own == "us" {
+		return "de"
+	}
+	return "us"
+}
+
+// writeDelistedGamesNote lists the delisted and region locked games in "Delisted games.md" in StatsOutputDir
+func writeDelistedGamesNote(delisted, regionLocked []indexEntry) error {
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	var sb strings.Builder
+	writeGroup := func(heading, empty string, entries []indexEntry) {
+		sb.WriteString("## " + heading + "\n\n")
+		if len(entries) == 0 {
+			sb.WriteString(empty + "\n")
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+		})
+		for _, entry := range entries {
+			sb.WriteString("- " + wikilink(entry.Path) + "\n")
+		}
+	}
+	writeGroup("Delisted", "No delisted games.", delisted)
+	sb.WriteString("\n")
+	writeGroup("Region locked", "No region locked games.", regionLocked)
+
+	return writeReportNote(filepath.Join(outputDir, "Delisted games.md"), "Delisted games", "delisted", sb.String())
+}
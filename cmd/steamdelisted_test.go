@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSteamFallbackRegion(t *testing.T) {
+	tests := []struct {
+		region   string
+		fallback string
+		want     string
+	}{
+		{"", "us", "us"},
+		{"fi", "us", "us"},
+		{"us", "us", "de"},
+		{"US", "", "de"},
+		{"fi", "gb", "gb"},
+		{"gb", "gb", "us"},
+	}
+
+	previousRegion, previousFallback := viper.Get("SteamRegion"), viper.Get("SteamFallbackRegion")
+	t.Cleanup(func() {
+		viper.Set("SteamRegion", previousRegion)
+		viper.Set("SteamFallbackRegion", previousFallback)
+	})
+
+	for _, tt := range tests {
+		viper.Set("SteamRegion", tt.region)
+		viper.Set("SteamFallbackRegion", tt.fallback)
+		if got := steamFallbackRegion(); got != tt.want {
+			t.Errorf("steamFallbackRegion() with SteamRegion %q and SteamFallbackRegion %q = %q, want %q", tt.region, tt.fallback, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// steamGridDBEndpoints maps the SteamGridDBArtwork config values to API endpoints and their query
+var steamGridDBEndpoints = map[string]string{
+	"grid": "grids/steam/%d?dimensions=600x900",
+	"hero": "heroes/steam/%d",
+}
+
+// steamGridDBArt is the artwork chosen for a game on SteamGridDB
+type steamGridDBArt struct {
+	URL    string `json:"url"`
+	Author string `json:"author"`
+	// Page is the artwork's page on SteamGridDB, linked for attribution
+	Page string `json:"page"`
+}
+
+// fetchSteamGridDBArt returns the top voted SteamGridDB artwork of a Steam app, nil if there's none
+func fetchSteamGridDBArt(appID int) (*steamGridDBArt, error) {
+	kind := viper.GetString("SteamGridDBArtwork")
+	endpoint, ok := steamGridDBEndpoints[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown SteamGridDBArtwork %q, use grid or hero", kind)
+	}
+
+	key := kind + "-" + strconv.Itoa(appID)
+	var art steamGridDBArt
+	if !readCache("steamgriddb", key, &art) {
+		req, err := http.NewRequest(http.MethodGet, "https://www.steamgriddb.com/api/v2/"+fmt.Sprintf(endpoint, appID), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+viper.GetString("SteamGridDBAPIKey"))
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		// Games SteamGridDB doesn't know are a 404, cached as a miss like an empty result
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return nil, httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+		if resp.StatusCode == http.StatusOK {
+			var response struct {
+				Data []struct {
+					ID     int    `json:"id"`
+					URL    string `json:"url"`
+					Author struct {
+						Name string `json:"name"`
+					} `json:"author"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				return nil, err
+			}
+			// Results are sorted by score, the first one is the community favourite
+			if len(response.Data) > 0 {
+				top := response.Data[0]
+				art = steamGridDBArt{
+					URL:    top.URL,
+					Author: top.Author.Name,
+					Page:   fmt.Sprintf("https://www.steamgriddb.com/%s/%d", kind, top.ID),
+				}
+			}
+		}
+
+		if err := writeCache("steamgriddb", key, art); err != nil {
+			log.Warnf("Error caching SteamGridDB artwork %s: %v\n", key, err)
+		}
+	}
+
+	if art.URL == "" {
+		return nil, nil
+	}
+	return &art, nil
+}
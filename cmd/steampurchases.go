@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// SteamPurchase is a row of the purchases ledger
+type SteamPurchase struct {
+	AppID int
+	Price float64
+	Date  string
+	// Bundle is the bundle or store the game came from, games of a bundle can share its price on one row
+	Bundle string
+}
+
+// readSteamPurchases reads the purchases CSV (appid, price, date, bundle), columns are matched by
+// header name. A missing file means no purchases.
+func readSteamPurchases(filename string) ([]SteamPurchase, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var purchases []SteamPurchase
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		appID, err := strconv.Atoi(field(record, "appid"))
+		if err != nil {
+			log.Warnf("Skipping purchase without appid: %v\n", record)
+			continue
+		}
+
+		purchase := SteamPurchase{
+			AppID:  appID,
+			Date:   field(record, "date"),
+			Bundle: field(record, "bundle"),
+		}
+		if price := field(record, "price"); price != "" {
+			purchase.Price, err = parsePrice(price)
+			if err != nil {
+				log.WithField("AppId", appID).Warnf("Error parsing price %s: %v\n", price, err)
+			}
+		}
+		if purchase.Date != "" {
+			if _, err := parseWatchDate(purchase.Date); err != nil {
+				log.WithField("AppId", appID).Warnf("Error parsing purchase date: %v\n", err)
+				purchase.Date = ""
+			}
+		}
+
+		purchases = append(purchases, purchase)
+	}
+
+	return purchases, nil
+}
+
+// parsePrice parses prices like "12.99", "12,99", "€12.99" or "1,299.00". The last separator is
+// the decimal point, the ones before it and spaces group thousands.
+func parsePrice(price string) (float64, error) {
+	price = strings.TrimFunc(price, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != ','
+	})
+	price = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, price)
+	if i := strings.LastIndexAny(price, ".,"); i >= 0 {
+		price = strings.NewReplacer(".", "", ",", "").Replace(price[:i]) + "." + price[i+1:]
+	}
+	return strconv.ParseFloat(price, 64)
+}
+
+// steamPurchasesByApp maps the purchases to their games, the latest row wins for games bought twice
+func steamPurchasesByApp(purchases []SteamPurchase) map[int]SteamPurchase {
+	byApp := make(map[int]SteamPurchase)
+	for _, purchase := range purchases {
+		byApp[purchase.AppID] = purchase
+	}
+	return byApp
+}
+
+// applySteamPurchase copies the price, date and bundle of the game's purchase to it
+func applySteamPurchase(game *Game, purchases map[int]SteamPurchase) {
+	purchase, ok := purchases[game.AppID]
+	if !ok {
+		return
+	}
+	game.Purchased = true
+	game.PricePaid = purchase.Price
+	game.Acquired = purchase.Date
+	game.Bundle = purchase.Bundle
+}
+
+// writeGameSpendingNote writes the yearly totals of the purchases ledger to "Game spending.md" in StatsOutputDir
+func writeGameSpendingNote(purchases []SteamPurchase) error {
+	outputDir := viper.GetString("StatsOutputDir")
+	if outputDir == "" {
+		outputDir = filepath.Join(viper.GetString("MarkdownOutputDir"), "stats")
+	}
+
+	type yearTotal struct {
+		games   int
+		bundles map[string]bool
+		spent   float64
+	}
+	years := make(map[int]*yearTotal)
+	var total float64
+	undated := 0
+	for _, purchase := range purchases {
+		total += purchase.Price
+		day, err := parseWatchDate(purchase.Date)
+		if err != nil {
+			undated++
+			continue
+		}
+		year := years[day.Year()]
+		if year == nil {
+			year = &yearTotal{bundles: make(map[string]bool)}
+			years[day.Year()] = year
+		}
+		year.games++
+		year.spent += purchase.Price
+		if purchase.Bundle != "" {
+			year.bundles[purchase.Bundle] = true
+		}
+	}
+	var keys []int
+	for year := range years {
+		keys = append(keys, year)
+	}
+	// Latest purchases first
+	sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+
+	var sb strings.Builder
+	sb.WriteString("| Year | Games | Bundles | Spent | Per game |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, key := range keys {
+		year := years[key]
+		sb.WriteString(fmt.Sprintf("| %d | %d | %d | %.2f | %.2f |\n", key, year.games, len(year.bundles), year.spent, year.spent/float64(year.games)))
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal spent %.2f on %d games", total, len(purchases)))
+	if undated > 0 {
+		sb.WriteString(fmt.Sprintf(", %d without a purchase date", undated))
+	}
+	sb.WriteString(".\n")
+
+	return writeReportNote(filepath.Join(outputDir, "Game spending.md"), "Game spending", "spending", sb.String())
+}
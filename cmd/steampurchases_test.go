@@ -0,0 +1,37 @@
+package cmd
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		price string
+		want  float64
+	}{
+		{"12.99", 12.99},
+		{"12,99", 12.99},
+		{"€12.99", 12.99},
+		{"12,99 €", 12.99},
+		{"$1,299.00", 1299},
+		{"1.299,00 €", 1299},
+		{"1 299,00 €", 1299},
+		{"1 299,00", 1299},
+		{"1,234,567.89", 1234567.89},
+		{"5", 5},
+		{"0", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePrice(tt.price)
+		if err != nil {
+			t.Errorf("parsePrice(%q): %v", tt.price, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePrice(%q) = %g, want %g", tt.price, got, tt.want)
+		}
+	}
+
+	if _, err := parsePrice("free"); err == nil {
+		t.Error("parsePrice(\"free\") didn't fail")
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// steamWorkshopPageSize is the most files GetUserFiles returns per page
+const steamWorkshopPageSize = 100
+
+// steamMod is a subscribed Workshop item
+type steamMod struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Updated is the unix time the item was last updated
+	Updated int64 `json:"updated"`
+}
+
+// fetchSteamMods returns the Workshop items subscribed to in a game. Subscriptions are usually
+// changed around playing, so like achievements they are cached by the last played time.
+func fetchSteamMods(apiKey, steamID string, appID int, lastPlayed int64) ([]steamMod, error) {
+	var mods []steamMod
+	key := fmt.Sprintf("%d-%d", appID, lastPlayed)
+	if readCache("steamworkshop", key, &mods) {
+		return mods, nil
+	}
+
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("key", apiKey)
+		params.Set("steamid", steamID)
+		params.Set("appid", strconv.Itoa(appID))
+		params.Set("type", "mysubscriptions")
+		params.Set("return_details", "true")
+		params.Set("numperpage", strconv.Itoa(steamWorkshopPageSize))
+		params.Set("page", strconv.Itoa(page))
+
+		var response struct {
+			Response struct {
+				Total int `json:"total"`
+				Files []struct {
+					PublishedFileID string `json:"publishedfileid"`
+					Title           string `json:"title"`
+					TimeUpdated     int64  `json:"time_updated"`
+				} `json:"publishedfiledetails"`
+			} `json:"response"`
+		}
+		if err := getSteamJSON("https://api.steampowered.com/IPublishedFileService/GetUserFiles/v1/?"+params.Encode(), &response); err != nil {
+			return nil, err
+		}
+
+		for _, file := range response.Response.Files {
+			mods = append(mods, steamMod{ID: file.PublishedFileID, Title: file.Title, Updated: file.TimeUpdated})
+		}
+		if len(response.Response.Files) < steamWorkshopPageSize || len(mods) >= response.Response.Total {
+			break
+		}
+	}
+
+	sort.Slice(mods, func(i, j int) bool {
+		return strings.ToLower(mods[i].Title) < strings.ToLower(mods[j].Title)
+	})
+
+	if err := writeCache("steamworkshop", key, mods); err != nil {
+		log.Warnf("Error caching Steam Workshop subscriptions %d: %v\n", appID, err)
+	}
+	return mods, nil
+}
+
+// steamModsSection returns the list of subscribed mods written between the steam-mods markers
+func steamModsSection(mods []steamMod) string {
+	var sb strings.Builder
+	sb.WriteString("## Mods\n\n")
+	for _, mod := range mods {
+		title := mod.Title
+		if title == "" {
+			title = mod.ID
+		}
+		sb.WriteString(fmt.Sprintf("- [%s](https://steamcommunity.com/sharedfiles/filedetails/?id=%s)", escapeLinkText(title), mod.ID))
+		if mod.Updated > 0 {
+			sb.WriteString(" updated " + localDate(time.Unix(mod.Updated, 0)))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// escapeLinkText escapes the brackets that would end the text of a markdown link
+func escapeLinkText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
@@ -0,0 +1,355 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// StoryGraphBook is a book from a StoryGraph export
+type StoryGraphBook struct {
+	Title      string   `json:"Title"`
+	Authors    []string `json:"Authors"`
+	ISBN       string   `json:"ISBN"`
+	ReadStatus string   `json:"Read Status"`
+	DateRead   string   `json:"Last Date Read"`
+	Moods      []string `json:"Moods"`
+	Pace       string   `json:"Pace"`
+	Rating     float64  `json:"Star Rating"`
+	Review     string   `json:"Review"`
+}
+
+var storygraphNotesDir string
+
+// storygraphCmd represents the storygraph command
+var storygraphCmd = &cobra.Command{
+	Use:   "storygraph [file]",
+	Short: "Parse StoryGraph export",
+	Long: `Parse the StoryGraph export CSV and add the moods and pace of each book to its note
+as mood/ and pace/ tags. Books are matched to the notes created by the Goodreads importer
+by ISBN, falling back to the title, books without a note get a new one. The Goodreads
+importer keeps the moods, pace and tags when it rewrites the note.
+
+The file defaults to storygraph_export.csv.`,
+	ValidArgsFunction: completeInputFile("csv"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing StoryGraph export...")
+		parse_storygraph(inputFile(args, "storygraph_export.csv"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(storygraphCmd)
+
+	storygraphCmd.Flags().StringVarP(&storygraphNotesDir, "notes-dir", "d", "", "Directory with book notes (default the Goodreads notes directory)")
+}
+
+func parse_storygraph(filename string) {
+	if storygraphNotesDir == "" {
+		storygraphNotesDir = sourceRootDir("goodreads")
+	}
+
+	input, err := openInput(filename)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer input.Close()
+
+	var books []StoryGraphBook
+	if importJSONIn {
+		books, err = readJSONLines[StoryGraphBook](input)
+	} else {
+		books, err = readStoryGraphCSV(input)
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(books); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d StoryGraph books\n", len(books))
+		return
+	}
+
+	index, err := indexBookNotes(storygraphNotesDir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error reading notes from %s: %v\n", storygraphNotesDir, err)
+		return
+	}
+
+	var updated, created, unchanged int
+	for _, book := range books {
+		bookLogger := log.WithField("Title", book.Title)
+
+		path := index.find(book.ISBN, book.Title)
+		var note *Note
+		if path == "" {
+			note, err = newStoryGraphNote(book)
+			if err != nil {
+				bookLogger.Errorf("Error creating note: %v\n", err)
+				continue
+			}
+		} else {
+			note, err = readNote(path)
+			if err != nil {
+				bookLogger.Errorf("Error reading %s: %v\n", path, err)
+				continue
+			}
+		}
+
+		changed, err := mergeStoryGraphBook(note, book)
+		if err != nil {
+			bookLogger.Errorf("Error merging StoryGraph data: %v\n", err)
+			continue
+		}
+		if path != "" && !changed {
+			unchanged++
+			continue
+		}
+
+		if err := note.Write(); err != nil {
+			bookLogger.Errorf("Error writing %s: %v\n", note.Path, err)
+			continue
+		}
+		if path == "" {
+			index.add(note.Path, book.Title, book.ISBN)
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	summaryf("Processed %d StoryGraph books: %d notes updated, %d created, %d unchanged\n", len(books), updated, created, unchanged)
+}
+
+// readStoryGraphCSV reads the StoryGraph export, columns are matched by header name
+func readStoryGraphCSV(r io.Reader) ([]StoryGraphBook, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var books []StoryGraphBook
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		rating, err := strconv.ParseFloat(field(record, "star rating"), 64)
+		if err != nil {
+			rating = 0.0
+		}
+
+		book := StoryGraphBook{
+			Title:      field(record, "title"),
+			Authors:    splitList(field(record, "authors")),
+			ISBN:       field(record, "isbn/uid"),
+			ReadStatus: field(record, "read status"),
+			DateRead:   isoDate(field(record, "last date read")),
+			Moods:      splitList(field(record, "moods")),
+			Pace:       field(record, "pace"),
+			Rating:     rating,
+			Review:     field(record, "review"),
+		}
+		if book.Title == "" {
+			log.Warnf("Skipping row without title: %v\n", record)
+			continue
+		}
+
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(str string) []string {
+	var items []string
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mergeStoryGraphBook adds the moods and pace of a book to a note, returns true if the note changed
+func mergeStoryGraphBook(note *Note, book StoryGraphBook) (bool, error) {
+	before, err := note.Content()
+	if err != nil {
+		return false, err
+	}
+
+	var tags []string
+	if len(book.Moods) > 0 {
+		if err := note.Frontmatter.Set("moods", book.Moods); err != nil {
+			return false, err
+		}
+		for _, mood := range book.Moods {
+			tags = append(tags, "mood/"+slugify(mood))
+		}
+	}
+	if book.Pace != "" {
+		if err := note.Frontmatter.Set("pace", book.Pace); err != nil {
+			return false, err
+		}
+		tags = append(tags, "pace/"+slugify(book.Pace))
+	}
+	if book.Rating > 0 {
+		if err := note.Frontmatter.Set("storygraph_rating", book.Rating); err != nil {
+			return false, err
+		}
+	}
+	if _, err := note.Frontmatter.AddTags(tags...); err != nil {
+		return false, err
+	}
+
+	after, err := note.Content()
+	if err != nil {
+		return false, err
+	}
+	return before != after, nil
+}
+
+// newStoryGraphNote creates an unsaved note for a book the Goodreads importer hasn't seen
+func newStoryGraphNote(book StoryGraphBook) (*Note, error) {
+	author := ""
+	authorLast := ""
+	if len(book.Authors) > 0 {
+		author = book.Authors[0]
+		names := strings.Fields(author)
+		authorLast = names[len(names)-1]
+	}
+
+	filePath, err := notePath("goodreads", map[string]string{
+		"title":       book.Title,
+		"year":        yearString(0),
+		"decade":      decade(0),
+		"author":      author,
+		"author_last": authorLast,
+		"shelf":       book.ReadStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", book.Title)
+	if aliases := noteAliases(filePath, book.Title, bookTitleWithoutSeries(book.Title)); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	if len(book.Authors) > 0 {
+		frontmatter.Set("authors", book.Authors)
+		frontmatter.Set("author_sort", sortName(author))
+	}
+	if book.ISBN != "" {
+		frontmatter.Set("isbn", book.ISBN)
+	}
+	if book.DateRead != "" {
+		frontmatter.Set("date_read", book.DateRead)
+	}
+	tags := []string{}
+	if book.ReadStatus != "" {
+		tags = append(tags, "storygraph/"+slugify(book.ReadStatus))
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if book.Review != "" {
+		body += "## Review\n\n" + book.Review + "\n"
+	}
+
+	return &Note{Path: filePath, Frontmatter: frontmatter, Body: body}, nil
+}
+
+// bookIndex maps ISBNs and normalized titles to note paths
+type bookIndex struct {
+	byISBN  map[string]string
+	byTitle map[string]string
+}
+
+// indexBookNotes indexes the notes in a directory by ISBN and title
+func indexBookNotes(directory string) (*bookIndex, error) {
+	index := &bookIndex{
+		byISBN:  make(map[string]string),
+		byTitle: make(map[string]string),
+	}
+
+	paths, err := findNotes(directory)
+	if err != nil {
+		return index, err
+	}
+
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+		index.add(path, note.Title(), note.Frontmatter.GetString("isbn"))
+		if isbn13 := note.Frontmatter.GetString("isbn13"); isbn13 != "" {
+			index.byISBN[isbn13] = path
+		}
+	}
+
+	return index, nil
+}
+
+func (i *bookIndex) add(path, title, isbn string) {
+	if isbn != "" {
+		i.byISBN[isbn] = path
+	}
+	keys := []string{normalizeTitle(title)}
+	// Goodreads titles end with the series, "The Way of Kings (The Stormlight Archive, #1)"
+	if start := strings.LastIndex(title, " ("); start != -1 && strings.HasSuffix(title, ")") {
+		keys = append(keys, normalizeTitle(title[:start]))
+	}
+	for _, key := range keys {
+		if _, ok := i.byTitle[key]; !ok {
+			i.byTitle[key] = path
+		}
+	}
+}
+
+// find returns the note path for a book, preferring an ISBN match
+func (i *bookIndex) find(isbn, title string) string {
+	if path, ok := i.byISBN[isbn]; ok && isbn != "" {
+		return path
+	}
+	return i.byTitle[normalizeTitle(title)]
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStoryGraphSurvivesGoodreadsReimport(t *testing.T) {
+	dir := testVault(t, nil)
+
+	book := Book{ID: 1, Title: "Dune", Authors: []string{"Frank Herbert"}, OriginalPublicationYear: 1965, ExclusiveShelf: "read", MyRating: 4}
+	path, err := writeBookToMarkdown(book, newNoteRelocator("goodreads_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readNote(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeStoryGraphBook(note, StoryGraphBook{Title: "Dune", Moods: []string{"adventurous", "dark"}, Pace: "slow", Rating: 4.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := note.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	book.MyRating = 5
+	if _, err := writeBookToMarkdown(book, newNoteRelocator("goodreads_id")); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err = readNote(filepath.Join(dir, "goodreads/Dune (1965).md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := note.Frontmatter.GetStrings("moods"); len(got) != 2 {
+		t.Errorf("moods = %v, want the StoryGraph moods", got)
+	}
+	if got := note.Frontmatter.GetString("pace"); got != "slow" {
+		t.Errorf("pace = %q, want slow", got)
+	}
+	if got := note.Frontmatter.GetString("storygraph_rating"); got != "4.5" {
+		t.Errorf("storygraph_rating = %q, want 4.5", got)
+	}
+	tags := make(map[string]bool)
+	for _, tag := range note.Frontmatter.GetStrings("tags") {
+		tags[tag] = true
+	}
+	for _, tag := range []string{"goodreads/read", "mood/adventurous", "mood/dark", "pace/slow"} {
+		if !tags[tag] {
+			t.Errorf("tags = %v, missing %s", note.Frontmatter.GetStrings("tags"), tag)
+		}
+	}
+	if got := note.Frontmatter.GetString("my_rating"); got != "5" {
+		t.Errorf("my_rating = %q, want the reimported 5", got)
+	}
+}
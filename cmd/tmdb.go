@@ -0,0 +1,767 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// TMDBTitle is a rated or watchlisted movie or TV show from a TMDB account
+type TMDBTitle struct {
+	TmdbId        int     `json:"TmdbId"`
+	Type          string  `json:"Type"` // movie or tv
+	Title         string  `json:"Title"`
+	OriginalTitle string  `json:"Original Title"`
+	Year          int     `json:"Year"`
+	Overview      string  `json:"Overview"`
+	PosterURL     string  `json:"Poster URL"`
+	MyRating      float64 `json:"My Rating"`
+	DateRated     string  `json:"Date Rated"`
+	Watchlist     bool    `json:"Watchlist"`
+	// Countries are ISO 3166-1 codes of the production (movies) or origin (TV) countries
+	Countries []string `json:"Countries"`
+	Language  string   `json:"Language"`
+	// Videos are the official YouTube trailers and teasers, at most TMDBVideoLimit
+	Videos    []tmdbVideo `json:"Videos"`
+	Composers []string    `json:"Composers"`
+	// Providers are where a watchlisted title can be watched in WatchRegion, nil if not checked
+	Providers *tmdbProviders `json:"Providers,omitempty"`
+	// ContentWarnings are the topics of ContentWarnings.Provider
+	ContentWarnings []string `json:"Content Warnings"`
+}
+
+// tmdbProviders are the streaming, rental and purchase services of a title in a region
+type tmdbProviders struct {
+	Region string   `json:"region"`
+	Link   string   `json:"link"`
+	Stream []string `json:"stream"`
+	Rent   []string `json:"rent"`
+	Buy    []string `json:"buy"`
+}
+
+// tmdbVideo is a trailer or teaser of a title
+type tmdbVideo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// tmdbAccountItem is an item of the v4 account rated and watchlist lists
+type tmdbAccountItem struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	Name          string `json:"name"`
+	OriginalTitle string `json:"original_title"`
+	OriginalName  string `json:"original_name"`
+	ReleaseDate   string `json:"release_date"`
+	FirstAirDate  string `json:"first_air_date"`
+	Overview      string `json:"overview"`
+	PosterPath    string `json:"poster_path"`
+	AccountRating struct {
+		Value     float64 `json:"value"`
+		CreatedAt string  `json:"created_at"`
+	} `json:"account_rating"`
+}
+
+var tmdbPushRatings bool
+
+// tmdbCmd represents the tmdb command
+var tmdbCmd = &cobra.Command{
+	Use:   "tmdb [file]",
+	Short: "Import rated and watchlisted titles from a TMDB account",
+	Long: `Fetch the rated movies and TV shows and the watchlist of a TMDB account with the v4 API
+and write one note per title.
+
+Requires TMDBAccessToken (a v4 user access token with write approval) and
+TMDBAccountID (the v4 account_object_id) in the config.
+
+With --push-ratings, ratings of the IMDb movie notes that differ from the TMDB
+account are sent to TMDB. Notes are matched to TMDB by imdb_id.
+
+With --json-out the titles are written to stdout as JSON lines after fetching their details,
+--json-in reads them back from the file argument ("-" or none for stdin) instead of the account.`,
+	ValidArgsFunction: completeInputFile("json"),
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing TMDB account...")
+		parse_tmdb(inputFile(args, "-"))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(tmdbCmd)
+
+	tmdbCmd.Flags().BoolVar(&tmdbPushRatings, "push-ratings", false, "Send the ratings of the IMDb movie notes to TMDB")
+}
+
+func parse_tmdb(filename string) {
+	var all []TMDBTitle
+	var err error
+	if importJSONIn {
+		all, err = readTMDBJSONLines(filename)
+	} else {
+		all, err = fetchTMDBTitles()
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(all); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d TMDB titles\n", len(all))
+		return
+	}
+
+	if err := writeTMDBTitlesToJson(all); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	err = writeTMDBTitlesToMarkdown(all)
+	if err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d TMDB titles\n", len(all))
+
+	if tmdbPushRatings {
+		token := viper.GetString("TMDBAccessToken")
+		if token == "" {
+			log.Error("TMDBAccessToken must be set in the config to push ratings")
+			return
+		}
+		titles := make(map[string]*TMDBTitle)
+		for i := range all {
+			titles[all[i].Type+"/"+strconv.Itoa(all[i].TmdbId)] = &all[i]
+		}
+		pushTMDBRatings(token, titles)
+	}
+}
+
+// readTMDBJSONLines reads the titles written by --json-out
+func readTMDBJSONLines(filename string) ([]TMDBTitle, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	defer input.Close()
+
+	titles, err := readJSONLines[TMDBTitle](input)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	return titles, nil
+}
+
+// fetchTMDBTitles fetches the rated and watchlisted titles of the account with their details
+func fetchTMDBTitles() ([]TMDBTitle, error) {
+	token := viper.GetString("TMDBAccessToken")
+	accountID := viper.GetString("TMDBAccountID")
+	if token == "" || accountID == "" {
+		return nil, errors.New("TMDBAccessToken and TMDBAccountID must be set in the config")
+	}
+
+	titles := make(map[string]*TMDBTitle)
+	var order []string
+	for _, list := range []string{"rated", "watchlist"} {
+		for _, mediaType := range []string{"movie", "tv"} {
+			var items []tmdbAccountItem
+			err := withRetry(func() error {
+				var err error
+				items, err = fetchTMDBAccountList(token, accountID, mediaType, list)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error fetching TMDB %s %s list: %w", mediaType, list, err)
+			}
+
+			for _, item := range items {
+				key := mediaType + "/" + strconv.Itoa(item.ID)
+				title, ok := titles[key]
+				if !ok {
+					title = newTMDBTitle(mediaType, item)
+					titles[key] = title
+					order = append(order, key)
+				}
+				if list == "watchlist" {
+					title.Watchlist = true
+				}
+			}
+		}
+	}
+
+	var all []TMDBTitle
+	for _, key := range order {
+		title := titles[key]
+		origin, err := fetchTMDBOrigin(token, title.Type, title.TmdbId)
+		if err != nil {
+			log.WithField("Title", title.Title).Warnf("Error fetching TMDB details: %v\n", err)
+		}
+		title.Countries, title.Language = origin.Countries, origin.Language
+
+		credits, err := fetchTMDBCredits(token, title.Type, title.TmdbId)
+		if err != nil {
+			log.WithField("Title", title.Title).Warnf("Error fetching TMDB credits: %v\n", err)
+		}
+		title.Composers = credits.composers()
+
+		if limit := viper.GetInt("TMDBVideoLimit"); limit > 0 {
+			videos, err := fetchTMDBVideos(token, title.Type, title.TmdbId)
+			if err != nil {
+				log.WithField("Title", title.Title).Warnf("Error fetching TMDB videos: %v\n", err)
+			}
+			if len(videos) > limit {
+				videos = videos[:limit]
+			}
+			title.Videos = videos
+		}
+
+		if contentWarningsEnabled() {
+			warnings, err := fetchContentWarnings(title.Type, title.TmdbId, "", title.Title, title.Year)
+			if err != nil {
+				log.WithField("Title", title.Title).Warnf("Error fetching content warnings: %v\n", err)
+			}
+			title.ContentWarnings = warnings
+		}
+
+		if region := viper.GetString("WatchRegion"); region != "" && title.Watchlist {
+			providers, err := fetchTMDBProviders(token, title.Type, title.TmdbId, region)
+			if err != nil {
+				log.WithField("Title", title.Title).Warnf("Error fetching TMDB watch providers: %v\n", err)
+			} else {
+				title.Providers = providers
+			}
+		}
+
+		all = append(all, *title)
+	}
+
+	return all, nil
+}
+
+// newTMDBTitle converts an account list item, movies and TV shows name their fields differently
+func newTMDBTitle(mediaType string, item tmdbAccountItem) *TMDBTitle {
+	title := &TMDBTitle{
+		TmdbId:        item.ID,
+		Type:          mediaType,
+		Title:         item.Title,
+		OriginalTitle: item.OriginalTitle,
+		Overview:      item.Overview,
+		MyRating:      item.AccountRating.Value,
+	}
+	date := item.ReleaseDate
+	if mediaType == "tv" {
+		title.Title = item.Name
+		title.OriginalTitle = item.OriginalName
+		date = item.FirstAirDate
+	}
+	if len(date) >= 4 {
+		title.Year, _ = strconv.Atoi(date[:4])
+	}
+	// Ratings are UTC timestamps
+	title.DateRated = isoDate(item.AccountRating.CreatedAt)
+	if item.PosterPath != "" {
+		title.PosterURL = "https://image.tmdb.org/t/p/w500" + item.PosterPath
+	}
+	return title
+}
+
+// fetchTMDBAccountList fetches all pages of a v4 account list, list is rated or watchlist
+func fetchTMDBAccountList(token, accountID, mediaType, list string) ([]tmdbAccountItem, error) {
+	var items []tmdbAccountItem
+	for page := 1; ; page++ {
+		var response struct {
+			Page       int               `json:"page"`
+			TotalPages int               `json:"total_pages"`
+			Results    []tmdbAccountItem `json:"results"`
+		}
+		url := fmt.Sprintf("https://api.themoviedb.org/4/account/%s/%s/%s?page=%d", accountID, mediaType, list, page)
+		if err := tmdbRequest(http.MethodGet, url, token, nil, &response); err != nil {
+			return nil, err
+		}
+
+		items = append(items, response.Results...)
+		if page >= response.TotalPages {
+			return items, nil
+		}
+	}
+}
+
+// tmdbRequest performs an authenticated TMDB API request, decoding the JSON response into v
+func tmdbRequest(method, url, token string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// pushTMDBRatings sends the ratings of the IMDb movie notes that differ from the TMDB account
+func pushTMDBRatings(token string, titles map[string]*TMDBTitle) {
+	directory := sourceRootDir("imdb")
+	paths, err := findNotes(directory)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", directory, err)
+		return
+	}
+
+	var pushed, unchanged int
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil || isGeneratedNote(note) {
+			continue
+		}
+		imdbID := noteID(note.Frontmatter, "imdb_id")
+		rating := note.Frontmatter.GetFloat("my_rating")
+		if imdbID == "" || rating <= 0 {
+			continue
+		}
+		noteLogger := log.WithFields(log.Fields{"ImdbId": imdbID, "Title": note.Title()})
+
+		mediaType, tmdbID, err := findTMDBByImdbID(token, imdbID)
+		if err != nil {
+			noteLogger.Warnf("Error looking up TMDB id: %v\n", err)
+			continue
+		}
+		if tmdbID == 0 {
+			noteLogger.Debug("Not found on TMDB")
+			continue
+		}
+
+		if title, ok := titles[mediaType+"/"+strconv.Itoa(tmdbID)]; ok && title.MyRating == rating {
+			unchanged++
+			continue
+		}
+
+		url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/rating", mediaType, tmdbID)
+		err = withRetry(func() error {
+			return tmdbRequest(http.MethodPost, url, token, map[string]float64{"value": rating}, nil)
+		})
+		if err != nil {
+			noteLogger.Errorf("Error pushing rating: %v\n", err)
+			continue
+		}
+		pushed++
+	}
+
+	summaryf("Pushed %d ratings to TMDB, %d already up to date\n", pushed, unchanged)
+}
+
+// findTMDBByImdbID returns the TMDB media type and id of an IMDb title, id is 0 if TMDB doesn't know it
+func findTMDBByImdbID(token, imdbID string) (string, int, error) {
+	var found struct {
+		MediaType string `json:"media_type"`
+		ID        int    `json:"id"`
+	}
+	if readCache("tmdbfind", imdbID, &found) {
+		return found.MediaType, found.ID, nil
+	}
+
+	var response struct {
+		MovieResults []struct {
+			ID int `json:"id"`
+		} `json:"movie_results"`
+		TVResults []struct {
+			ID int `json:"id"`
+		} `json:"tv_results"`
+	}
+	url := "https://api.themoviedb.org/3/find/" + imdbID + "?external_source=imdb_id"
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch {
+	case len(response.MovieResults) > 0:
+		found.MediaType, found.ID = "movie", response.MovieResults[0].ID
+	case len(response.TVResults) > 0:
+		found.MediaType, found.ID = "tv", response.TVResults[0].ID
+	}
+
+	// Misses are cached too so they aren't looked up on every run
+	if err := writeCache("tmdbfind", imdbID, found); err != nil {
+		log.Warnf("Error caching TMDB id of %s: %v\n", imdbID, err)
+	}
+
+	return found.MediaType, found.ID, nil
+}
+
+func writeTMDBTitlesToJson(titles []TMDBTitle) error {
+	jsonData, err := json.Marshal(titles)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("tmdb.json", jsonData)
+}
+
+// tmdbIdField is the frontmatter field with the TMDB id of a media type, movie and TV ids overlap
+func tmdbIdField(mediaType string) string {
+	return "tmdb_" + mediaType + "_id"
+}
+
+// writeTMDBTitleToMarkdown writes title info to a markdown file
+func writeTMDBTitleToMarkdown(title TMDBTitle, relocator *noteRelocator) (string, error) {
+	id := strconv.Itoa(title.TmdbId)
+	preference := relocator.titlePreference(id)
+	name := preferredTitle(preference, title.Title, title.OriginalTitle, titleLocalized)
+
+	filePath, err := notePath("tmdb", map[string]string{
+		"title":          name,
+		"original_title": title.OriginalTitle,
+		"year":           yearString(title.Year),
+		"decade":         decade(title.Year),
+		"type":           title.Type,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(id, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{"tmdb/" + title.Type}
+	if title.MyRating > 0 {
+		tags = append(tags, "tmdb/rated")
+	}
+	if title.Watchlist {
+		tags = append(tags, "tmdb/watchlist")
+	}
+	tags = append(tags, contentWarningTags(title.ContentWarnings)...)
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", name)
+	if title.OriginalTitle != "" && title.OriginalTitle != title.Title {
+		frontmatter.Set("original_title", title.OriginalTitle)
+	}
+	if preference != "" {
+		frontmatter.Set("title_preference", preference)
+	}
+	if aliases := noteAliases(filePath, title.Title, title.OriginalTitle); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set(tmdbIdField(title.Type), id)
+	frontmatter.Set("url", fmt.Sprintf("https://www.themoviedb.org/%s/%s", title.Type, id))
+	if title.Year > 0 {
+		frontmatter.Set("year", title.Year)
+	}
+	if title.MyRating > 0 {
+		frontmatter.Set("my_rating", title.MyRating)
+	}
+	if title.DateRated != "" {
+		frontmatter.Set("date_rated", title.DateRated)
+	}
+	// The watchlist doesn't tell when a title was added, it's the date of the first import that saw it
+	if added := relocator.existing(id, "date_added"); added != "" {
+		frontmatter.Set("date_added", added)
+	} else if title.Watchlist {
+		frontmatter.Set("date_added", localDate(time.Now()))
+	}
+	if len(title.Countries) > 0 {
+		frontmatter.Set("country", title.Countries)
+	}
+	if title.Language != "" {
+		frontmatter.Set("language", title.Language)
+	}
+	if len(title.Composers) > 0 {
+		frontmatter.Set("composers", title.Composers)
+	}
+	if title.Providers != nil {
+		frontmatter.Set("available", len(title.Providers.Stream) > 0)
+	}
+	if title.PosterURL != "" {
+		frontmatter.Set("cover", title.PosterURL)
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if title.PosterURL != "" {
+		body += fmt.Sprintf("![](%s)\n\n", title.PosterURL)
+	}
+	if title.Overview != "" {
+		body += title.Overview + "\n"
+	}
+	if callout := contentWarningCallout(title.ContentWarnings); callout != "" {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += callout
+	}
+	if link := soundtrackLink(title.Title); link != "" {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += fmt.Sprintf("[Soundtrack](%s)\n", link)
+	}
+	if title.Providers != nil {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += fmt.Sprintf("## Where to watch (%s)\n\n", title.Providers.Region)
+		body += providerLine("Stream", title.Providers.Stream)
+		body += providerLine("Rent", title.Providers.Rent)
+		body += providerLine("Buy", title.Providers.Buy)
+		if len(title.Providers.Stream)+len(title.Providers.Rent)+len(title.Providers.Buy) == 0 {
+			body += "Not available.\n"
+		}
+		// TMDB's terms require crediting JustWatch for the provider data
+		body += fmt.Sprintf("\n[Availability by JustWatch](%s)\n", title.Providers.Link)
+	}
+	if len(title.Videos) > 0 {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += "## Videos\n\n"
+		for _, video := range title.Videos {
+			body += fmt.Sprintf("- [%s](%s) (%s)\n", video.Name, video.URL, strings.ToLower(video.Type))
+		}
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(id, note)
+	return filePath, note.Write()
+}
+
+// writeTMDBTitlesToMarkdown writes a list of titles to markdown files
+func writeTMDBTitlesToMarkdown(titles []TMDBTitle) error {
+	relocators := map[string]*noteRelocator{
+		"movie": newNoteRelocator(tmdbIdField("movie")),
+		"tv":    newNoteRelocator(tmdbIdField("tv")),
+	}
+	var entries []indexEntry
+	for _, title := range titles {
+		path, err := writeTMDBTitleToMarkdown(title, relocators[title.Type])
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: title.Title, Year: title.Year, Rating: title.MyRating})
+		addDailyActivity(title.DateRated, "Watched", path, ratingStars(title.MyRating, 10))
+	}
+	return writeIndexNote("tmdb", entries)
+}
+
+// tmdbEpisode is an episode of a TV show
+type tmdbEpisode struct {
+	Name          string `json:"name"`
+	AirDate       string `json:"air_date"`
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+}
+
+// fetchTMDBNextEpisode returns the next episode of a TV show to air, nil if none is scheduled
+func fetchTMDBNextEpisode(token, tvID string) (*tmdbEpisode, error) {
+	var response struct {
+		NextEpisodeToAir *tmdbEpisode `json:"next_episode_to_air"`
+	}
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, "https://api.themoviedb.org/3/tv/"+tvID, token, nil, &response)
+	})
+	return response.NextEpisodeToAir, err
+}
+
+// tmdbOrigin is the origin of a movie or TV show
+type tmdbOrigin struct {
+	Countries []string `json:"countries"`
+	Language  string   `json:"language"`
+}
+
+// fetchTMDBOrigin returns the countries and original language of a movie or TV show
+func fetchTMDBOrigin(token, mediaType string, id int) (tmdbOrigin, error) {
+	var origin tmdbOrigin
+	key := mediaType + "-" + strconv.Itoa(id)
+	if readCache("tmdborigin", key, &origin) {
+		return origin, nil
+	}
+
+	var response struct {
+		OriginalLanguage    string   `json:"original_language"`
+		OriginCountry       []string `json:"origin_country"`
+		ProductionCountries []struct {
+			Code string `json:"iso_3166_1"`
+		} `json:"production_countries"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d", mediaType, id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return origin, err
+	}
+
+	origin.Language = response.OriginalLanguage
+	// TV shows have an origin country, movies only list the production countries
+	origin.Countries = response.OriginCountry
+	if len(origin.Countries) == 0 {
+		for _, country := range response.ProductionCountries {
+			origin.Countries = append(origin.Countries, country.Code)
+		}
+	}
+
+	if err := writeCache("tmdborigin", key, origin); err != nil {
+		log.Warnf("Error caching TMDB details %s: %v\n", key, err)
+	}
+
+	return origin, nil
+}
+
+// fetchTMDBVideos returns the official YouTube trailers and teasers of a title, trailers first
+func fetchTMDBVideos(token, mediaType string, id int) ([]tmdbVideo, error) {
+	var videos []tmdbVideo
+	key := mediaType + "-" + strconv.Itoa(id)
+	if readCache("tmdbvideos", key, &videos) {
+		return videos, nil
+	}
+
+	var response struct {
+		Results []struct {
+			Name        string `json:"name"`
+			Key         string `json:"key"`
+			Site        string `json:"site"`
+			Type        string `json:"type"`
+			Official    bool   `json:"official"`
+			PublishedAt string `json:"published_at"`
+		} `json:"results"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/videos", mediaType, id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := response.Results
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Type != results[j].Type {
+			return results[i].Type == "Trailer"
+		}
+		return results[i].PublishedAt < results[j].PublishedAt
+	})
+
+	videos = []tmdbVideo{}
+	for _, result := range results {
+		if result.Site != "YouTube" || !result.Official || (result.Type != "Trailer" && result.Type != "Teaser") {
+			continue
+		}
+		videos = append(videos, tmdbVideo{Name: result.Name, Type: result.Type, URL: "https://www.youtube.com/watch?v=" + result.Key})
+	}
+
+	if err := writeCache("tmdbvideos", key, videos); err != nil {
+		log.Warnf("Error caching TMDB videos %s: %v\n", key, err)
+	}
+
+	return videos, nil
+}
+
+// soundtrackLink returns a soundtrack search for a title on the service in SoundtrackSearchURL,
+// empty if it isn't set. The {{query}} placeholder is replaced with the escaped search.
+func soundtrackLink(title string) string {
+	template := viper.GetString("SoundtrackSearchURL")
+	if template == "" {
+		return ""
+	}
+	// Spaces as %20 work both in paths and query strings
+	query := strings.ReplaceAll(url.QueryEscape(title+" soundtrack"), "+", "%20")
+	return strings.ReplaceAll(template, "{{query}}", query)
+}
+
+// fetchTMDBProviders returns where a title can be watched in a region. Availability changes
+// daily, so unlike the other TMDB responses it isn't cached.
+func fetchTMDBProviders(token, mediaType string, id int, region string) (*tmdbProviders, error) {
+	type provider struct {
+		Name string `json:"provider_name"`
+	}
+	var response struct {
+		Results map[string]struct {
+			Link     string     `json:"link"`
+			Flatrate []provider `json:"flatrate"`
+			Free     []provider `json:"free"`
+			Ads      []provider `json:"ads"`
+			Rent     []provider `json:"rent"`
+			Buy      []provider `json:"buy"`
+		} `json:"results"`
+	}
+	url := fmt.Sprintf("https://api.themoviedb.org/3/%s/%d/watch/providers", mediaType, id)
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, url, token, nil, &response)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	region = strings.ToUpper(region)
+	result := response.Results[region]
+	providers := &tmdbProviders{Region: region, Link: result.Link}
+	if providers.Link == "" {
+		providers.Link = fmt.Sprintf("https://www.themoviedb.org/%s/%d/watch?locale=%s", mediaType, id, region)
+	}
+	names := func(list []provider) []string {
+		var names []string
+		for _, p := range list {
+			names = appendMissing(names, p.Name)
+		}
+		return names
+	}
+	providers.Stream = names(append(append(result.Flatrate, result.Free...), result.Ads...))
+	providers.Rent = names(result.Rent)
+	providers.Buy = names(result.Buy)
+
+	return providers, nil
+}
+
+// providerLine renders a list of providers as a line of the where to watch section
+func providerLine(label string, providers []string) string {
+	if len(providers) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("- %s: %s\n", label, strings.Join(providers, ", "))
+}
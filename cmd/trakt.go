@@ -0,0 +1,451 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// TraktTitle is a watched or rated movie or TV show from Trakt
+type TraktTitle struct {
+	TraktId int    `json:"TraktId"`
+	Type    string `json:"Type"` // movie or show
+	Title   string `json:"Title"`
+	Year    int    `json:"Year"`
+	Slug    string `json:"Slug"`
+	ImdbId  string `json:"ImdbId"`
+	TmdbId  int    `json:"TmdbId"`
+	// Plays of a show count every watched episode
+	Plays           int     `json:"Plays"`
+	EpisodesWatched int     `json:"Episodes Watched"`
+	LastWatched     string  `json:"Last Watched"`
+	MyRating        float64 `json:"My Rating"`
+	DateRated       string  `json:"Date Rated"`
+	// Countries, Language, Composers, Videos and ContentWarnings are from TMDB
+	Countries       []string    `json:"Countries"`
+	Language        string      `json:"Language"`
+	Composers       []string    `json:"Composers"`
+	Videos          []tmdbVideo `json:"Videos"`
+	ContentWarnings []string    `json:"Content Warnings"`
+}
+
+// tmdbType is the TMDB media type of the title
+func (t TraktTitle) tmdbType() string {
+	if t.Type == "show" {
+		return "tv"
+	}
+	return t.Type
+}
+
+// traktMedia is a movie or show in the Trakt API and export files
+type traktMedia struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	IDs   struct {
+		Trakt int    `json:"trakt"`
+		Slug  string `json:"slug"`
+		Imdb  string `json:"imdb"`
+		Tmdb  int    `json:"tmdb"`
+	} `json:"ids"`
+}
+
+// traktWatched is an item of the watched movies and shows lists
+type traktWatched struct {
+	Plays         int         `json:"plays"`
+	LastWatchedAt string      `json:"last_watched_at"`
+	Movie         *traktMedia `json:"movie"`
+	Show          *traktMedia `json:"show"`
+	Seasons       []struct {
+		Episodes []struct {
+			Plays int `json:"plays"`
+		} `json:"episodes"`
+	} `json:"seasons"`
+}
+
+// traktRating is an item of the movie and show ratings lists
+type traktRating struct {
+	RatedAt string      `json:"rated_at"`
+	Rating  float64     `json:"rating"`
+	Movie   *traktMedia `json:"movie"`
+	Show    *traktMedia `json:"show"`
+}
+
+// traktLists are the lists read for each type, named as in the API paths and export files
+var traktLists = []string{"watched", "ratings"}
+
+// traktCmd represents the trakt command
+var traktCmd = &cobra.Command{
+	Use:   "trakt [export-dir]",
+	Short: "Import watched and rated movies and TV shows from Trakt",
+	Long: `Fetch the watched and rated movies and TV shows of a Trakt profile and write one note per title.
+
+Without an argument the Trakt API is used, it requires TraktClientID (the client id of an API app)
+and TraktUsername in the config. TraktAccessToken is needed for private profiles.
+
+With an argument the titles are read from an unzipped Trakt data export instead, the directory
+with watched-movies.json, watched-shows.json, ratings-movies.json and ratings-shows.json.
+
+Trakt knows the TMDB and IMDb ids of the titles, the notes get tmdb_movie_id or tmdb_tv_id and
+imdb_id so the TMDB commands like franchises and picks work on them. When TMDBAccessToken is set
+the titles are enriched from TMDB like the TMDB import.`,
+	ValidArgsFunction: completeDirs,
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("Processing Trakt history...")
+		parse_trakt(inputFile(args, ""))
+	},
+}
+
+func init() {
+	importCmd.AddCommand(traktCmd)
+}
+
+func parse_trakt(source string) {
+	var titles []TraktTitle
+	var err error
+	switch {
+	case importJSONIn:
+		titles, err = readTraktJSONLines(source)
+	case source != "":
+		titles, err = readTraktTitles(func(list, traktType string, v interface{}) error {
+			return readTraktExportFile(filepath.Join(source, list+"-"+traktType+".json"), v)
+		})
+	default:
+		if viper.GetString("TraktClientID") == "" || viper.GetString("TraktUsername") == "" {
+			log.Error("TraktClientID and TraktUsername must be set in the config, or give the export directory")
+			return
+		}
+		titles, err = readTraktTitles(func(list, traktType string, v interface{}) error {
+			url := fmt.Sprintf("https://api.trakt.tv/users/%s/%s/%s", viper.GetString("TraktUsername"), list, traktType)
+			return withRetry(func() error {
+				return getTraktJSON(url, v)
+			})
+		})
+	}
+	if err != nil {
+		log.Errorf("Error reading Trakt history: %v\n", err)
+		return
+	}
+
+	for i := range titles {
+		enrichTraktTitle(&titles[i])
+	}
+
+	if importJSONOut {
+		if err := writeJSONLines(titles); err != nil {
+			log.Errorf("Error writing JSON stream: %v\n", err)
+		}
+		summaryf("Processed %d Trakt titles\n", len(titles))
+		return
+	}
+
+	if err := writeTraktTitlesToJson(titles); err != nil {
+		log.Errorf("Error writing JSON: %v\n", err)
+	}
+
+	if err := writeTraktTitlesToMarkdown(titles); err != nil {
+		log.Errorf("Error writing markdown: %v\n", err)
+	}
+
+	summaryf("Processed %d Trakt titles\n", len(titles))
+}
+
+// readTraktJSONLines reads the titles written by --json-out
+func readTraktJSONLines(filename string) ([]TraktTitle, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	return readJSONLines[TraktTitle](input)
+}
+
+// readTraktTitles merges the watched and rated movies and shows into titles, read decodes a list
+// of a type (movies or shows) into v
+func readTraktTitles(read func(list, traktType string, v interface{}) error) ([]TraktTitle, error) {
+	titles := make(map[string]*TraktTitle)
+	var order []string
+	title := func(traktType string, media *traktMedia) *TraktTitle {
+		key := traktType + "/" + strconv.Itoa(media.IDs.Trakt)
+		if t, ok := titles[key]; ok {
+			return t
+		}
+		t := &TraktTitle{
+			TraktId: media.IDs.Trakt,
+			Type:    traktType,
+			Title:   media.Title,
+			Year:    media.Year,
+			Slug:    media.IDs.Slug,
+			ImdbId:  media.IDs.Imdb,
+			TmdbId:  media.IDs.Tmdb,
+		}
+		titles[key] = t
+		order = append(order, key)
+		return t
+	}
+
+	for _, traktType := range []string{"movie", "show"} {
+		var watched []traktWatched
+		if err := read("watched", traktType+"s", &watched); err != nil {
+			return nil, fmt.Errorf("watched %ss: %w", traktType, err)
+		}
+		for _, item := range watched {
+			media := item.Movie
+			if traktType == "show" {
+				media = item.Show
+			}
+			if media == nil {
+				continue
+			}
+			t := title(traktType, media)
+			t.Plays = item.Plays
+			// Trakt times are UTC
+			t.LastWatched = isoDate(item.LastWatchedAt)
+			for _, season := range item.Seasons {
+				t.EpisodesWatched += len(season.Episodes)
+			}
+		}
+
+		var ratings []traktRating
+		if err := read("ratings", traktType+"s", &ratings); err != nil {
+			return nil, fmt.Errorf("%s ratings: %w", traktType, err)
+		}
+		for _, item := range ratings {
+			media := item.Movie
+			if traktType == "show" {
+				media = item.Show
+			}
+			if media == nil {
+				continue
+			}
+			t := title(traktType, media)
+			t.MyRating = item.Rating
+			t.DateRated = isoDate(item.RatedAt)
+		}
+	}
+
+	var all []TraktTitle
+	for _, key := range order {
+		all = append(all, *titles[key])
+	}
+	return all, nil
+}
+
+// readTraktExportFile decodes a file of a Trakt data export, missing files are empty lists
+func readTraktExportFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Debugf("No %s in the export\n", filepath.Base(path))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// getTraktJSON performs a GET request against the Trakt API
+func getTraktJSON(url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("trakt-api-version", "2")
+	req.Header.Set("trakt-api-key", viper.GetString("TraktClientID"))
+	if token := viper.GetString("TraktAccessToken"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// enrichTraktTitle fills in the TMDB details of a title when TMDBAccessToken is set
+func enrichTraktTitle(title *TraktTitle) {
+	token := viper.GetString("TMDBAccessToken")
+	logger := log.WithField("Title", title.Title)
+
+	// Titles read with --json-in may already have them
+	if token != "" && title.TmdbId != 0 && len(title.Countries) == 0 {
+		origin, err := fetchTMDBOrigin(token, title.tmdbType(), title.TmdbId)
+		if err != nil {
+			logger.Warnf("Error fetching TMDB details: %v\n", err)
+		}
+		title.Countries, title.Language = origin.Countries, origin.Language
+
+		credits, err := fetchTMDBCredits(token, title.tmdbType(), title.TmdbId)
+		if err != nil {
+			logger.Warnf("Error fetching TMDB credits: %v\n", err)
+		}
+		title.Composers = credits.composers()
+
+		if limit := viper.GetInt("TMDBVideoLimit"); limit > 0 {
+			videos, err := fetchTMDBVideos(token, title.tmdbType(), title.TmdbId)
+			if err != nil {
+				logger.Warnf("Error fetching TMDB videos: %v\n", err)
+			}
+			if len(videos) > limit {
+				videos = videos[:limit]
+			}
+			title.Videos = videos
+		}
+	}
+
+	if contentWarningsEnabled() && title.ContentWarnings == nil {
+		warnings, err := fetchContentWarnings(title.tmdbType(), title.TmdbId, title.ImdbId, title.Title, title.Year)
+		if err != nil {
+			logger.Warnf("Error fetching content warnings: %v\n", err)
+		}
+		title.ContentWarnings = warnings
+	}
+}
+
+func writeTraktTitlesToJson(titles []TraktTitle) error {
+	jsonData, err := json.Marshal(titles)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile("trakt.json", jsonData)
+}
+
+// traktIdField is the frontmatter field with the Trakt id of a type, movie and show ids overlap
+func traktIdField(traktType string) string {
+	return "trakt_" + traktType + "_id"
+}
+
+// writeTraktTitleToMarkdown writes title info to a markdown file
+func writeTraktTitleToMarkdown(title TraktTitle, relocator *noteRelocator) (string, error) {
+	id := strconv.Itoa(title.TraktId)
+	filePath, err := notePath("trakt", map[string]string{
+		"title":  title.Title,
+		"year":   yearString(title.Year),
+		"decade": decade(title.Year),
+		"type":   title.Type,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := relocator.relocate(id, filePath); err != nil {
+		return "", err
+	}
+
+	tags := []string{"trakt/" + title.Type}
+	if title.MyRating > 0 {
+		tags = append(tags, "trakt/rated")
+	}
+	tags = append(tags, contentWarningTags(title.ContentWarnings)...)
+
+	frontmatter := newFrontmatter()
+	frontmatter.Set("title", title.Title)
+	if aliases := noteAliases(filePath, title.Title); len(aliases) > 0 {
+		frontmatter.Set("aliases", aliases)
+	}
+	frontmatter.Set(traktIdField(title.Type), id)
+	if title.TmdbId != 0 {
+		frontmatter.Set(tmdbIdField(title.tmdbType()), strconv.Itoa(title.TmdbId))
+	}
+	if title.ImdbId != "" {
+		frontmatter.Set("imdb_id", title.ImdbId)
+	}
+	frontmatter.Set("url", fmt.Sprintf("https://trakt.tv/%ss/%s", title.Type, title.Slug))
+	if title.Year > 0 {
+		frontmatter.Set("year", title.Year)
+	}
+	if title.MyRating > 0 {
+		frontmatter.Set("my_rating", title.MyRating)
+	}
+	if title.DateRated != "" {
+		frontmatter.Set("date_rated", title.DateRated)
+	}
+	if title.LastWatched != "" {
+		frontmatter.Set("last_watched", title.LastWatched)
+	}
+	if title.Plays > 0 {
+		frontmatter.Set("plays", title.Plays)
+	}
+	if title.EpisodesWatched > 0 {
+		frontmatter.Set("episodes_watched", title.EpisodesWatched)
+	}
+	if len(title.Countries) > 0 {
+		frontmatter.Set("country", title.Countries)
+	}
+	if title.Language != "" {
+		frontmatter.Set("language", title.Language)
+	}
+	if len(title.Composers) > 0 {
+		frontmatter.Set("composers", title.Composers)
+	}
+	frontmatter.Set("tags", tags)
+
+	body := "\n"
+	if callout := contentWarningCallout(title.ContentWarnings); callout != "" {
+		body += callout
+	}
+	if link := soundtrackLink(title.Title); link != "" {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += fmt.Sprintf("[Soundtrack](%s)\n", link)
+	}
+	if len(title.Videos) > 0 {
+		if body != "\n" {
+			body += "\n"
+		}
+		body += "## Videos\n\n"
+		for _, video := range title.Videos {
+			body += fmt.Sprintf("- [%s](%s) (%s)\n", video.Name, video.URL, strings.ToLower(video.Type))
+		}
+	}
+
+	setMediaIDs(frontmatter, relocator.idField)
+	note := &Note{Path: filePath, Frontmatter: frontmatter, Body: body}
+	relocator.restore(id, note)
+	return filePath, note.Write()
+}
+
+// writeTraktTitlesToMarkdown writes a list of titles to markdown files
+func writeTraktTitlesToMarkdown(titles []TraktTitle) error {
+	relocators := map[string]*noteRelocator{
+		"movie": newNoteRelocator(traktIdField("movie")),
+		"show":  newNoteRelocator(traktIdField("show")),
+	}
+	var entries []indexEntry
+	for _, title := range titles {
+		path, err := writeTraktTitleToMarkdown(title, relocators[title.Type])
+		if skipNoteConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries = append(entries, indexEntry{Path: path, Title: title.Title, Year: title.Year, Rating: title.MyRating})
+		addDailyActivity(title.LastWatched, "Watched", path, ratingStars(title.MyRating, 10))
+	}
+	return writeIndexNote("trakt", entries)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var upcomingICS string
+
+// upcomingCmd represents the upcoming command
+var upcomingCmd = &cobra.Command{
+	Use:   "upcoming",
+	Short: "Generate a calendar note of upcoming episodes and movie releases",
+	Long: `Fetch the next episode air dates of the TV show notes and the release dates of the
+watchlisted movies from TMDB and write them to Upcoming.md in MarkdownOutputDir.
+
+TV shows are the notes with a tmdb_tv_id, shows marked finished: true are skipped.
+Movies are the notes with a tmdb_movie_id tagged tmdb/watchlist. Requires TMDBAccessToken in the config.
+
+The calendar is written between hermes markers, anything else in the note is kept.
+With --ics the releases are also written to an iCalendar file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		generateUpcoming()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upcomingCmd)
+
+	upcomingCmd.Flags().StringVar(&upcomingICS, "ics", "", "Also write the releases to this iCalendar file")
+	upcomingCmd.MarkFlagFilename("ics", "ics")
+}
+
+// upcomingRelease is an upcoming episode or movie release
+type upcomingRelease struct {
+	Date  string
+	Path  string
+	Title string
+	// Detail is the episode, empty for movies
+	Detail string
+	UID    string
+}
+
+func generateUpcoming() {
+	token := viper.GetString("TMDBAccessToken")
+	if token == "" {
+		log.Error("TMDBAccessToken must be set in the config")
+		return
+	}
+
+	directory := viper.GetString("MarkdownOutputDir")
+	paths, err := findNotes(directory)
+	if err != nil {
+		log.Errorf("Error reading notes from %s: %v\n", directory, err)
+		return
+	}
+
+	today := localDate(time.Now())
+	var releases []upcomingRelease
+	for _, path := range paths {
+		note, err := readNote(path)
+		if err != nil {
+			log.Warnf("Skipping %s: %v\n", path, err)
+			continue
+		}
+		if isGeneratedNote(note) {
+			continue
+		}
+
+		if id := noteID(note.Frontmatter, tmdbIdField("tv")); id != "" && note.Frontmatter.GetString("finished") != "true" {
+			episode, err := fetchTMDBNextEpisode(token, id)
+			if err != nil {
+				log.WithField("Show", note.Title()).Warnf("Error fetching next episode: %v\n", err)
+				continue
+			}
+			if episode == nil || episode.AirDate < today {
+				continue
+			}
+			detail := fmt.Sprintf("S%02dE%02d", episode.SeasonNumber, episode.EpisodeNumber)
+			if episode.Name != "" {
+				detail += " " + episode.Name
+			}
+			releases = append(releases, upcomingRelease{
+				Date:   episode.AirDate,
+				Path:   path,
+				Title:  note.Title(),
+				Detail: detail,
+				UID:    fmt.Sprintf("tmdb-tv-%s-s%de%d@hermes", id, episode.SeasonNumber, episode.EpisodeNumber),
+			})
+		}
+
+		if id := noteID(note.Frontmatter, tmdbIdField("movie")); id != "" && hasTag(note.Frontmatter, "tmdb/watchlist") {
+			releaseDate, err := fetchTMDBReleaseDate(token, id)
+			if err != nil {
+				log.WithField("Movie", note.Title()).Warnf("Error fetching release date: %v\n", err)
+				continue
+			}
+			if releaseDate == "" || releaseDate < today {
+				continue
+			}
+			releases = append(releases, upcomingRelease{
+				Date:  releaseDate,
+				Path:  path,
+				Title: note.Title(),
+				UID:   fmt.Sprintf("tmdb-movie-%s@hermes", id),
+			})
+		}
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Date != releases[j].Date {
+			return releases[i].Date < releases[j].Date
+		}
+		return strings.ToLower(releases[i].Title) < strings.ToLower(releases[j].Title)
+	})
+
+	if err := writeUpcomingNote(filepath.Join(directory, "Upcoming.md"), releases); err != nil {
+		log.Errorf("Error writing upcoming note: %v\n", err)
+	}
+
+	if upcomingICS != "" {
+		if err := writeICS(upcomingICS, upcomingEvents(releases)); err != nil {
+			log.Errorf("Error writing %s: %v\n", upcomingICS, err)
+		}
+	}
+
+	summaryf("Found %d upcoming releases\n", len(releases))
+}
+
+// fetchTMDBReleaseDate returns the release date of a movie, empty if it's unknown
+func fetchTMDBReleaseDate(token, movieID string) (string, error) {
+	var response struct {
+		ReleaseDate string `json:"release_date"`
+	}
+	err := withRetry(func() error {
+		return tmdbRequest(http.MethodGet, "https://api.themoviedb.org/3/movie/"+movieID, token, nil, &response)
+	})
+	return response.ReleaseDate, err
+}
+
+// writeUpcomingNote creates or updates the calendar note, grouping the releases by date
+func writeUpcomingNote(path string, releases []upcomingRelease) error {
+	var sb strings.Builder
+	if len(releases) == 0 {
+		sb.WriteString("Nothing upcoming.\n")
+	}
+	var date string
+	for _, release := range releases {
+		if release.Date != date {
+			if date != "" {
+				sb.WriteString("\n")
+			}
+			date = release.Date
+			sb.WriteString("## " + date + "\n\n")
+		}
+		sb.WriteString("- " + wikilink(release.Path))
+		if release.Detail != "" {
+			sb.WriteString(" " + release.Detail)
+		} else {
+			sb.WriteString(" (release)")
+		}
+		sb.WriteString("\n")
+	}
+
+	return updateNote(path, "Upcoming", []string{"upcoming"}, func(note *Note) error {
+		note.Frontmatter.Set("count", len(releases))
+
+		note.Body = replaceSection(note.Body, "upcoming", sb.String())
+
+		return nil
+	})
+}
+
+// upcomingEvents converts the releases to calendar events
+func upcomingEvents(releases []upcomingRelease) []icsEvent {
+	var events []icsEvent
+	for _, release := range releases {
+		date, err := time.Parse("2006-01-02", release.Date)
+		if err != nil {
+			continue
+		}
+		summary := release.Title
+		if release.Detail != "" {
+			summary += " " + release.Detail
+		}
+		events = append(events, icsEvent{UID: release.UID, Date: date, Summary: summary})
+	}
+	return events
+}
@@ -0,0 +1,187 @@
+// Package titlematch matches titles from different sources: the same film or book is written
+// "The Matrix", "Matrix, The" or "The.Matrix.1999.1080p" depending on where it comes from.
+package titlematch
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// articles are the leading English articles ignored when matching, other languages' articles are
+// also common words ("Die Hard") so they are kept
+var articles = []string{"the", "a", "an"}
+
+// yearRegex splits names like "The.Matrix.1999.1080p" or "Blade Runner 2049 (2017)" into title and
+// year, the last year wins
+var yearRegex = regexp.MustCompile(`^(.*)[\s._(\[-]+((?:19|20)\d{2})(?:[\s._)\]-]|$)`)
+
+// foldings are the letters that don't decompose into a base letter and an accent
+var foldings = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ł': "l", 'þ': "th",
+}
+
+// romanNumerals are the numbers of sequels like "Rocky II", "V" is left out as it's also a title
+var romanNumerals = map[string]string{
+	"ii": "2", "iii": "3", "iv": "4", "vi": "6", "vii": "7", "viii": "8", "ix": "9", "x": "10",
+}
+
+// Normalize lowercases a title and strips everything but letters and digits, accents included,
+// so "Amélie" and "Amelie!" are the same
+func Normalize(title string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			r = unicode.ToLower(r)
+			if folded, ok := foldings[r]; ok {
+				sb.WriteString(folded)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// numbers returns the numbers in a title, roman numerals included, which tell sequels apart
+func numbers(title string) []string {
+	var found []string
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if number, ok := romanNumerals[word]; ok {
+			found = append(found, number)
+			continue
+		}
+		if _, err := strconv.Atoi(word); err == nil {
+			found = append(found, strings.TrimLeft(word, "0"))
+		}
+	}
+	return found
+}
+
+// StripArticle removes a leading article, or a trailing one of library sorted titles like "Matrix, The"
+func StripArticle(title string) string {
+	title = strings.TrimSpace(title)
+	lower := strings.ToLower(title)
+	for _, article := range articles {
+		if strings.HasPrefix(lower, article+" ") && len(title) > len(article)+1 {
+			return strings.TrimSpace(title[len(article)+1:])
+		}
+		if strings.HasSuffix(lower, ", "+article) {
+			return strings.TrimSpace(title[:len(title)-len(article)-2])
+		}
+	}
+	return title
+}
+
+// Key returns the key titles are matched by, normalized without the article
+func Key(title string) string {
+	if key := Normalize(StripArticle(title)); key != "" {
+		return key
+	}
+	// Titles that are only an article, like "A"
+	return Normalize(title)
+}
+
+// SplitYear returns the title and year of a name with a year, false if there's no year in it
+func SplitYear(name string) (string, int, bool) {
+	match := yearRegex.FindStringSubmatch(name)
+	if match == nil {
+		return name, 0, false
+	}
+	year, _ := strconv.Atoi(match[2])
+	return match[1], year, true
+}
+
+// Levenshtein returns the number of single character edits between two strings
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings, 1 for equal strings and 0 for
+// nothing in common. Common prefixes weigh more, which suits titles with different subtitles.
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		for j := max(0, i-window); j < min(len(rb), i+window+1); j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// Similarity scores how alike two titles are from 0 to 1, comparing their keys. Titles with
+// different numbers are different films or books, "Toy Story 2" isn't "Toy Story 3", and score 0.
+func Similarity(a, b string) float64 {
+	if !slices.Equal(numbers(a), numbers(b)) {
+		return 0
+	}
+	return JaroWinkler(Key(a), Key(b))
+}
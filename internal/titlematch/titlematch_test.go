@@ -0,0 +1,80 @@
+package titlematch
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Matrix", "thematrix"},
+		{"Amélie", "amelie"},
+		{"Amelie!", "amelie"},
+		{"Léon: The Professional", "leontheprofessional"},
+		{"Die Straße", "diestrasse"},
+		{"Ærø", "aero"},
+		{"Smörgåsbord", "smorgasbord"},
+		{"Седьмая печать", "седьмаяпечать"},
+		{"七人の侍", "七人の侍"},
+		{"2001: A Space Odyssey", "2001aspaceodyssey"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.title); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Matrix", "matrix"},
+		{"Matrix, The", "matrix"},
+		{"A Beautiful Mind", "beautifulmind"},
+		{"Beautiful Mind, A", "beautifulmind"},
+		{"An American Werewolf in London", "americanwerewolfinlondon"},
+		{"Theodore Rex", "theodorerex"},
+		{"Die Hard", "diehard"},
+		{"A", "a"},
+		{"The", "the"},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.title); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"The Matrix", "Matrix, The", 1, 1},
+		{"Amélie", "Amelie", 1, 1},
+		{"Die Straße", "Die Strasse", 1, 1},
+		{"Седьмая печать", "Седьмая печать", 1, 1},
+		{"Седьмая печать", "Земляничная поляна", 0, 0.7},
+		{"七人の侍", "七人の侍", 1, 1},
+		{"Star Wars: Episode IV", "Star Wars: Episode 4", 0.9, 1},
+		{"Toy Story 2", "Toy Story 3", 0, 0},
+		{"Rocky", "Rocky II", 0, 0},
+		{"Blade Runner", "Blade Runner 2049", 0, 0},
+		{"Terminator 2: Judgment Day", "Terminator 2 - Judgement Day", 0.9, 1},
+		{"Lord of the Rings: The Fellowship of the Ring", "The Lord of the Rings - The Fellowship of the Ring", 0.9, 1},
+		{"Heat", "Casino", 0, 0.6},
+	}
+
+	for _, tt := range tests {
+		got := Similarity(tt.a, tt.b)
+		if got < tt.min || got > tt.max {
+			t.Errorf("Similarity(%q, %q) = %.3f, want between %.2f and %.2f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}